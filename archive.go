@@ -0,0 +1,244 @@
+package marcopoller
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexandre-normand/slackscot/store"
+	"github.com/alexandre-normand/slackscot/store/datastoredb"
+	"github.com/pkg/errors"
+	otel "go.opentelemetry.io/otel/metric/global"
+	"google.golang.org/api/option"
+)
+
+// Archive persistence and history
+const (
+	archiveKindName = "marcoPollerArchive"
+
+	// historyPageSize is the number of archived polls shown on each page of the history
+	historyPageSize = 10
+)
+
+// ArchivedPoll represents the final results of a poll kept after voting closes. Archived polls aren't removed when polls
+// expire. Like exported results, voters are left out for anonymous polls
+type ArchivedPoll struct {
+	PollResults
+
+	// ChannelID is the channel of the poll message. It's empty for polls whose message isn't known (i.e. polls posted
+	// with a response url)
+	ChannelID    string `json:"channelID,omitempty"`
+	Participants int    `json:"participants"`
+	Created      int64  `json:"created"`
+	ClosedAt     int64  `json:"closedAt"`
+
+	// Rounds and Winner hold the instant-runoff tally of ranked choice polls. Winner is empty when the tally ends
+	// without one
+	Rounds []RunoffRound `json:"rounds,omitempty"`
+	Winner string        `json:"winner,omitempty"`
+
+	// WeightedScores holds the weighted score of each option of weighted polls, in the order of Options
+	WeightedScores []float64 `json:"weightedScores,omitempty"`
+}
+
+// OptionArchiveStorer sets the storer of archived polls. It should be dedicated to the archive since polls are found by
+// scanning all silos of the poll storer. Closed polls aren't archived when no archive storer is set
+func OptionArchiveStorer(storer store.GlobalSiloStringStorer) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.archiveStorer = storer
+		return nil
+	}
+}
+
+// OptionDatastoreArchiveStorer sets a datastoredb storer as the storer of archived polls
+func OptionDatastoreArchiveStorer(datastoreProjectID string, gcloudClientOpts ...option.ClientOption) Option {
+	return func(mp *MarcoPoller) (err error) {
+		meter := otel.GetMeterProvider().Meter("github.com/alexandre-normand/marcopoller")
+
+		mp.archiveStorer, err = datastoredb.NewWithTelemetry(appName, meter, archiveKindName, datastoreProjectID, gcloudClientOpts...)
+		if err != nil {
+			return errors.Wrapf(err, "Error initializing datastore archive storer on project [%s]", datastoreProjectID)
+		}
+
+		return nil
+	}
+}
+
+// newArchivedPoll returns the archive of a poll given all of its stored values (poll info and votes) and the weights of
+// its voters
+func newArchivedPoll(poll Poll, values map[string]string, weights map[string]float64, closedAt time.Time) (archived ArchivedPoll) {
+	archived = ArchivedPoll{PollResults: newPollResults(poll, values), Participants: countParticipants(values), Created: getPollCreationTime(poll.ID).Unix(), ClosedAt: closedAt.Unix()}
+	archived.Closed = true
+
+	// Like their results, the outcome of ranked choice polls is their instant-runoff tally rather than their weighted scores
+	if poll.Features.RankedChoice {
+		archived.Rounds, archived.Winner = runoffFromValues(poll, values)
+	} else if len(poll.Features.Weights) > 0 {
+		archived.WeightedScores = weightedScores(poll, values, weights)
+	}
+
+	if poll.MsgID != nil {
+		archived.ChannelID = poll.MsgID.ChannelID
+	}
+
+	return archived
+}
+
+// archiveSilo returns the silo of a poll's archive: the channel of the poll message or, when it isn't known, the poll's creator
+func archiveSilo(poll Poll) (silo string) {
+	if poll.MsgID != nil && poll.MsgID.ChannelID != "" {
+		return poll.MsgID.ChannelID
+	}
+
+	return poll.Creator
+}
+
+// archivePoll saves the final results of a poll given all of its stored values. Nothing is archived when the archive isn't enabled
+func (mp *MarcoPoller) archivePoll(poll Poll, values map[string]string, closedAt time.Time) (err error) {
+	if mp.archiveStorer == nil {
+		return nil
+	}
+
+	var weights map[string]float64
+	if len(poll.Features.Weights) > 0 {
+		weights = mp.voterWeights(poll)
+	}
+
+	encoded, err := json.Marshal(newArchivedPoll(poll, values, weights, closedAt))
+	if err != nil {
+		return err
+	}
+
+	return mp.archiveStorer.PutSiloString(archiveSilo(poll), poll.ID, string(encoded))
+}
+
+// archiveExpiredPoll archives an expired poll given all of its stored values unless it was archived when it was closed
+func (mp *MarcoPoller) archiveExpiredPoll(pollID string, values map[string]string, expiredAt time.Time) (err error) {
+	encodedPoll, ok := values[pollInfoKey]
+	if !ok {
+		return nil
+	}
+
+	poll, err := decodePoll(encodedPoll)
+	if err != nil {
+		// Polls that can't be decoded can't be archived but they're still deleted
+		log.Printf("Error parsing existing poll [%s] for id [%s]: %v", encodedPoll, pollID, err)
+		return nil
+	}
+
+	if poll.Closed {
+		return nil
+	}
+
+	return errors.Wrapf(mp.archivePoll(poll, values, expiredAt), "Error archiving poll [%s]", pollID)
+}
+
+// listArchivedPolls returns the archived polls of the silos, the most recently closed first
+func (mp *MarcoPoller) listArchivedPolls(silos ...string) (polls []ArchivedPoll, err error) {
+	polls = make([]ArchivedPoll, 0)
+	for _, silo := range silos {
+		entries, err := mp.archiveStorer.ScanSilo(silo)
+		if err != nil {
+			return nil, err
+		}
+
+		for pollID, encoded := range entries {
+			var archived ArchivedPoll
+			err := json.Unmarshal([]byte(encoded), &archived)
+			if err != nil {
+				log.Printf("Error parsing archived poll [%s] for id [%s]: %v", encoded, pollID, err)
+				continue
+			}
+
+			polls = append(polls, archived)
+		}
+	}
+
+	sort.Slice(polls, func(i, j int) bool {
+		if polls[i].ClosedAt != polls[j].ClosedAt {
+			return polls[i].ClosedAt > polls[j].ClosedAt
+		}
+
+		return polls[i].ID > polls[j].ID
+	})
+
+	return polls, nil
+}
+
+// showHistory shows a user a page of the results of the polls closed in a channel. The user's own polls whose channel
+// isn't known are included
+func (mp *MarcoPoller) showHistory(args []string, userID string, channelID string, responseURL string) {
+	page := 1
+	if len(args) > 0 {
+		var err error
+		page, err = strconv.Atoi(args[0])
+		if err != nil || len(args) > 1 || page < 1 {
+			showErrorToUser(responseURL, ":warning: Wrong usage. `/poll history [page]`")
+			return
+		}
+	}
+
+	polls, err := mp.listArchivedPolls(channelID, userID)
+	if err != nil {
+		log.Printf("Error listing archived polls of channel [%s]: %v", channelID, err)
+		showErrorToUser(responseURL, ":warning: Error listing past polls. Please try again.")
+		return
+	}
+
+	showMessageToUser(responseURL, formatHistory(polls, page))
+}
+
+// formatHistory formats a page of archived polls, pages starting at 1
+func formatHistory(polls []ArchivedPoll, page int) (formatted string) {
+	if len(polls) == 0 {
+		return "There are no closed polls in this channel yet"
+	}
+
+	pageCount := (len(polls) + historyPageSize - 1) / historyPageSize
+	if page > pageCount {
+		return fmt.Sprintf(":warning: There's no page %d. The history has %d page(s)", page, pageCount)
+	}
+
+	lines := []string{fmt.Sprintf("*Poll history* (page %d of %d)", page, pageCount)}
+
+	end := page * historyPageSize
+	if end > len(polls) {
+		end = len(polls)
+	}
+
+	for _, poll := range polls[(page-1)*historyPageSize : end] {
+		lines = append(lines, fmt.Sprintf("• *%s* by <@%s>, closed %s", poll.Question, poll.Creator, formatSlackDate(time.Unix(poll.ClosedAt, 0))))
+
+		if len(poll.Rounds) > 0 {
+			for i, round := range poll.Rounds {
+				lines = append(lines, fmt.Sprintf("      %s", formatRunoffRound(i+1, round)))
+			}
+
+			lines = append(lines, fmt.Sprintf("      %s · %s", formatRunoffWinner(poll.Winner), formatParticipantCount(poll.Participants)))
+			continue
+		}
+
+		results := make([]string, 0, len(poll.Options)+1)
+		for i, opt := range poll.Options {
+			result := fmt.Sprintf("%s `%d`", opt.Option, opt.Votes)
+			if i < len(poll.WeightedScores) {
+				result = fmt.Sprintf("%s (weighted `%s`)", result, formatWeight(poll.WeightedScores[i]))
+			}
+
+			results = append(results, result)
+		}
+
+		results = append(results, formatParticipantCount(poll.Participants))
+		lines = append(lines, fmt.Sprintf("      %s", strings.Join(results, " · ")))
+	}
+
+	if page < pageCount {
+		lines = append(lines, fmt.Sprintf("Use `/poll history %d` to see older polls", page+1))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,84 @@
+package marcopoller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveSilo(t *testing.T) {
+	assert.Equal(t, "CID", archiveSilo(Poll{Creator: "marco", MsgID: &MsgID{ChannelID: "CID", Timestamp: "1566576557.354007"}}))
+	assert.Equal(t, "marco", archiveSilo(Poll{Creator: "marco"}))
+}
+
+func TestNewArchivedPoll(t *testing.T) {
+	poll := Poll{ID: "1566576557-un", Question: "Lunch?", Options: []PollOption{{Text: "Pizza", Emoji: ":pizza:"}, {Text: "Tacos"}}, Creator: "marco", Features: PollFeatures{Anonymous: true}}
+	archived := newArchivedPoll(poll, map[string]string{pollInfoKey: "{}", "U1": "0", "U2": "0", "U3": ""}, nil, time.Unix(1566580158, 0))
+
+	assert.Equal(t, ArchivedPoll{PollResults: PollResults{ID: "1566576557-un", Question: "Lunch?", Creator: "marco", Closed: true, Options: []OptionResult{{Option: "Pizza", Votes: 2}, {Option: "Tacos", Votes: 0}}}, Participants: 2, Created: 1566576557, ClosedAt: 1566580158}, archived)
+}
+
+func TestNewArchivedRankedPoll(t *testing.T) {
+	poll := Poll{ID: "1566576557-un", Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos"}, {Text: "Sushi"}}, Creator: "marco", Features: PollFeatures{RankedChoice: true}}
+	archived := newArchivedPoll(poll, map[string]string{pollInfoKey: "{}", "U1": "0,2", "U2": "1", "U3": "2,0"}, nil, time.Unix(1566580158, 0))
+
+	assert.Equal(t, []RunoffRound{{Counts: []OptionResult{{Option: "Pizza", Votes: 1}, {Option: "Tacos", Votes: 1}, {Option: "Sushi", Votes: 1}}, Eliminated: []string{"Pizza", "Tacos", "Sushi"}}}, archived.Rounds)
+	assert.Equal(t, "", archived.Winner)
+
+	archived = newArchivedPoll(poll, map[string]string{pollInfoKey: "{}", "U1": "0,2", "U2": "1", "U3": "2,0", "U4": "0"}, nil, time.Unix(1566580158, 0))
+
+	assert.Equal(t, []RunoffRound{{Counts: []OptionResult{{Option: "Pizza", Votes: 2}, {Option: "Tacos", Votes: 1}, {Option: "Sushi", Votes: 1}}, Eliminated: []string{"Tacos", "Sushi"}}, {Counts: []OptionResult{{Option: "Pizza", Votes: 3}}, Eliminated: []string{}}}, archived.Rounds)
+	assert.Equal(t, "Pizza", archived.Winner)
+	assert.Nil(t, archived.WeightedScores)
+}
+
+func TestNewArchivedWeightedPoll(t *testing.T) {
+	poll := Poll{ID: "1566576557-un", Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos"}}, Creator: "marco", Features: PollFeatures{Weights: map[string]float64{"U1": 2.5}}}
+	archived := newArchivedPoll(poll, map[string]string{pollInfoKey: "{}", "U1": "0", "U2": "1"}, map[string]float64{"U1": 2.5}, time.Unix(1566580158, 0))
+
+	assert.Equal(t, []float64{2.5, 1}, archived.WeightedScores)
+	assert.Nil(t, archived.Rounds)
+}
+
+func TestFormatHistoryOfRankedAndWeightedPolls(t *testing.T) {
+	polls := []ArchivedPoll{
+		ArchivedPoll{PollResults: PollResults{Question: "Lunch?", Creator: "marco", Options: []OptionResult{{Option: "Pizza", Votes: 3}, {Option: "Tacos", Votes: 1}}}, Participants: 3, ClosedAt: 1566580158, Rounds: []RunoffRound{{Counts: []OptionResult{{Option: "Pizza", Votes: 2}, {Option: "Tacos", Votes: 1}}, Eliminated: []string{"Tacos"}}, {Counts: []OptionResult{{Option: "Pizza", Votes: 3}}}}, Winner: "Pizza"},
+		ArchivedPoll{PollResults: PollResults{Question: "Dinner?", Creator: "marco", Options: []OptionResult{{Option: "Pasta", Votes: 1}, {Option: "Curry", Votes: 2}}}, Participants: 3, ClosedAt: 1566580158, WeightedScores: []float64{2.5, 2}},
+	}
+
+	assert.Equal(t, []string{
+		"*Poll history* (page 1 of 1)",
+		"• *Lunch?* by <@marco>, closed <!date^1566580158^{date_short_pretty} at {time}|Fri, 23 Aug 2019 17:09:18 UTC>",
+		"      Round 1: Pizza: 2, Tacos: 1 (eliminated: Tacos)",
+		"      Round 2: Pizza: 3",
+		"      :trophy: Winner: *Pizza* · `3 participants`",
+		"• *Dinner?* by <@marco>, closed <!date^1566580158^{date_short_pretty} at {time}|Fri, 23 Aug 2019 17:09:18 UTC>",
+		"      Pasta `1` (weighted `2.5`) · Curry `2` (weighted `2`) · `3 participants`",
+	}, strings.Split(formatHistory(polls, 1), "\n"))
+}
+
+func TestFormatHistoryPages(t *testing.T) {
+	polls := make([]ArchivedPoll, 0)
+	for i := 0; i < 12; i++ {
+		polls = append(polls, ArchivedPoll{PollResults: PollResults{Question: fmt.Sprintf("Question %d?", i), Creator: "marco", Options: []OptionResult{{Option: "Yes", Votes: 1}}}, Participants: 1, ClosedAt: 1566580158})
+	}
+
+	firstPage := strings.Split(formatHistory(polls, 1), "\n")
+	require.Len(t, firstPage, 22)
+	assert.Equal(t, "*Poll history* (page 1 of 2)", firstPage[0])
+	assert.Equal(t, "• *Question 0?* by <@marco>, closed <!date^1566580158^{date_short_pretty} at {time}|Fri, 23 Aug 2019 17:09:18 UTC>", firstPage[1])
+	assert.Equal(t, "      Yes `1` · `1 participant`", firstPage[2])
+	assert.Equal(t, "Use `/poll history 2` to see older polls", firstPage[21])
+
+	secondPage := strings.Split(formatHistory(polls, 2), "\n")
+	require.Len(t, secondPage, 5)
+	assert.Equal(t, "*Poll history* (page 2 of 2)", secondPage[0])
+	assert.Equal(t, "• *Question 10?* by <@marco>, closed <!date^1566580158^{date_short_pretty} at {time}|Fri, 23 Aug 2019 17:09:18 UTC>", secondPage[1])
+
+	assert.Equal(t, ":warning: There's no page 3. The history has 2 page(s)", formatHistory(polls, 3))
+	assert.Equal(t, "There are no closed polls in this channel yet", formatHistory([]ArchivedPoll{}, 1))
+}
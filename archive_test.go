@@ -0,0 +1,186 @@
+package marcopoller_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexandre-normand/marcopoller"
+	mmocks "github.com/alexandre-normand/marcopoller/mocks"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newArchivePoller returns a MarcoPoller with the given poll and archive storers
+func newArchivePoller(t *testing.T, storer *marcopoller.MemoryStorer, archiveStorer *marcopoller.MemoryStorer, pollVerifier marcopoller.PollVerifier) (mp *marcopoller.MarcoPoller) {
+	verifier := &Verifier{}
+	verifier.On("Verify", mock.Anything, mock.Anything).Return(nil)
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", mock.Anything).Return(&slack.User{Profile: slack.UserProfile{Image24: "http://image.me"}}, nil)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionArchiveStorer(archiveStorer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(pollVerifier))
+	require.NoError(t, err)
+
+	return mp
+}
+
+func TestCloseDuePollsArchivesResults(t *testing.T) {
+	storer := marcopoller.NewMemoryStorer()
+	require.NoError(t, storer.PutSiloString("1566576557-duePoll", "pollInfo", "{\"id\":\"1566576557-duePoll\",\"msgID\":{\"channelID\":\"CID\",\"timestamp\":\"1566576557.354007\"},\"question\":\"Lunch?\",\"options\":[\"Pizza\",\"Tacos\"],\"features\":{\"multianswers\":false,\"deadline\":1566580000},\"creator\":\"marco\"}"))
+	require.NoError(t, storer.PutSiloString("1566576557-duePoll", "marco", "0"))
+	require.NoError(t, storer.PutSiloString("1566576557-duePoll", "polo", "1"))
+	require.NoError(t, storer.PutSiloString("1566576557-duePoll", "luigi", "1"))
+
+	archiveStorer := marcopoller.NewMemoryStorer()
+	mp := newArchivePoller(t, storer, archiveStorer, marcopoller.AlwaysValidPollVerifier{})
+
+	closed, err := mp.CloseDuePolls(time.Unix(1566580158, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 1, closed)
+
+	archived, err := archiveStorer.GetSiloString("CID", "1566576557-duePoll")
+	require.NoError(t, err)
+	assert.Equal(t, "{\"id\":\"1566576557-duePoll\",\"question\":\"Lunch?\",\"creator\":\"marco\",\"closed\":true,\"options\":[{\"option\":\"Pizza\",\"votes\":1},{\"option\":\"Tacos\",\"votes\":2}],\"voters\":[{\"userID\":\"luigi\",\"choices\":[\"Tacos\"]},{\"userID\":\"marco\",\"choices\":[\"Pizza\"]},{\"userID\":\"polo\",\"choices\":[\"Tacos\"]}],\"channelID\":\"CID\",\"participants\":3,\"created\":1566576557,\"closedAt\":1566580158}", archived)
+
+	polls, err := storer.GlobalScan()
+	require.NoError(t, err)
+	assert.Len(t, polls, 0)
+}
+
+func TestDeleteExpiredPollsArchivesPollsNotClosed(t *testing.T) {
+	storer := marcopoller.NewMemoryStorer()
+	require.NoError(t, storer.PutSiloString("1566576557-expiredPoll", "pollInfo", "{\"id\":\"1566576557-expiredPoll\",\"question\":\"Lunch?\",\"options\":[\"Pizza\",\"Tacos\"],\"features\":{\"multianswers\":false,\"anonymous\":true},\"creator\":\"marco\"}"))
+	require.NoError(t, storer.PutSiloString("1566576557-expiredPoll", "polo", "1"))
+	require.NoError(t, storer.PutSiloString("1566576558-closedPoll", "pollInfo", "{\"id\":\"1566576558-closedPoll\",\"question\":\"Dinner?\",\"options\":[\"Pasta\",\"Curry\"],\"features\":{\"multianswers\":false,\"keepResults\":true},\"creator\":\"marco\",\"closed\":true}"))
+
+	archiveStorer := marcopoller.NewMemoryStorer()
+	mp := newArchivePoller(t, storer, archiveStorer, marcopoller.ExpirationPollVerifier{ValidityPeriod: time.Hour})
+
+	deleted, err := mp.DeleteExpiredPolls(time.Unix(1566580258, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	// Polls without a known channel are archived with their creator and anonymous polls are archived without voters
+	archive, err := archiveStorer.ScanSilo("marco")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"1566576557-expiredPoll": "{\"id\":\"1566576557-expiredPoll\",\"question\":\"Lunch?\",\"creator\":\"marco\",\"closed\":true,\"options\":[{\"option\":\"Pizza\",\"votes\":0},{\"option\":\"Tacos\",\"votes\":1}],\"participants\":1,\"created\":1566576557,\"closedAt\":1566580258}"}, archive)
+}
+
+// failingArchiveStorer fails to archive one poll
+type failingArchiveStorer struct {
+	*marcopoller.MemoryStorer
+	failingPollID string
+}
+
+func (fs *failingArchiveStorer) PutSiloString(silo string, key string, value string) (err error) {
+	if key == fs.failingPollID {
+		return fmt.Errorf("archive unavailable")
+	}
+
+	return fs.MemoryStorer.PutSiloString(silo, key, value)
+}
+
+func TestDeleteExpiredPollsSkipsPollsThatCantBeArchived(t *testing.T) {
+	storer := marcopoller.NewMemoryStorer()
+	require.NoError(t, storer.PutSiloString("1566576557-brokenPoll", "pollInfo", "{\"id\":\"1566576557-brokenPoll\",\"question\":\"Lunch?\",\"options\":[\"Pizza\",\"Tacos\"],\"features\":{\"multianswers\":false},\"creator\":\"marco\"}"))
+	require.NoError(t, storer.PutSiloString("1566576558-expiredPoll", "pollInfo", "{\"id\":\"1566576558-expiredPoll\",\"question\":\"Dinner?\",\"options\":[\"Pasta\",\"Curry\"],\"features\":{\"multianswers\":false},\"creator\":\"marco\"}"))
+
+	archiveStorer := &failingArchiveStorer{MemoryStorer: marcopoller.NewMemoryStorer(), failingPollID: "1566576557-brokenPoll"}
+
+	verifier := &Verifier{}
+	verifier.On("Verify", mock.Anything, mock.Anything).Return(nil)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionStorer(storer), marcopoller.OptionArchiveStorer(archiveStorer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.ExpirationPollVerifier{ValidityPeriod: time.Hour}))
+	require.NoError(t, err)
+
+	deleted, err := mp.DeleteExpiredPolls(time.Unix(1566580258, 0))
+	assert.EqualError(t, err, "Error archiving poll [1566576557-brokenPoll]: archive unavailable")
+	assert.Equal(t, 1, deleted)
+
+	// The poll that couldn't be archived is kept for the next run
+	polls, err := storer.GlobalScan()
+	require.NoError(t, err)
+	assert.Len(t, polls, 1)
+	assert.Contains(t, polls, "1566576557-brokenPoll")
+}
+
+func TestCloseSubcommandArchivesResults(t *testing.T) {
+	pollServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "OK")
+	}))
+	defer pollServer.Close()
+
+	storer := newSubcommandStorer(t, pollServer.URL)
+	archiveStorer := marcopoller.NewMemoryStorer()
+
+	slackRequests := runArchiveSubcommand(t, storer, archiveStorer, "close 1566576557-poll1")
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\"Closed *Lunch?*\",\"replace_original\":false}"}, slackRequests)
+
+	archive, err := archiveStorer.ScanSilo("marco")
+	require.NoError(t, err)
+	require.Len(t, archive, 1)
+	assert.Contains(t, archive["1566576557-poll1"], "\"options\":[{\"option\":\"Pizza\",\"votes\":0},{\"option\":\"Tacos\",\"votes\":1}]")
+}
+
+// runArchiveSubcommand sends a /poll command from marco in channel CID with the text and returns the requests sent to slack
+func runArchiveSubcommand(t *testing.T, storer *marcopoller.MemoryStorer, archiveStorer *marcopoller.MemoryStorer, text string) (slackRequests []string) {
+	server := newSlackServer()
+	defer server.Close()
+
+	mp := newArchivePoller(t, storer, archiveStorer, marcopoller.AlwaysValidPollVerifier{})
+
+	body := fmt.Sprintf("token=sometoken&team_id=TEAMID3&channel_id=CID&user_id=marco&command=%%2Fpoll&text=%s&response_url=%s&trigger_id=someTriggerID", url.QueryEscape(text), server.URL)
+
+	w := httptest.NewRecorder()
+	mp.StartPoll(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	return server.Requests()
+}
+
+func TestHistorySubcommand(t *testing.T) {
+	archiveStorer := marcopoller.NewMemoryStorer()
+	require.NoError(t, archiveStorer.PutSiloString("CID", "1566576557-lunch", "{\"id\":\"1566576557-lunch\",\"question\":\"Lunch?\",\"creator\":\"polo\",\"closed\":true,\"options\":[{\"option\":\"Pizza\",\"votes\":1},{\"option\":\"Tacos\",\"votes\":2}],\"channelID\":\"CID\",\"participants\":3,\"created\":1566576557,\"closedAt\":1566580158}"))
+	require.NoError(t, archiveStorer.PutSiloString("marco", "1566576558-dinner", "{\"id\":\"1566576558-dinner\",\"question\":\"Dinner?\",\"creator\":\"marco\",\"closed\":true,\"options\":[{\"option\":\"Pasta\",\"votes\":1},{\"option\":\"Curry\",\"votes\":0}],\"participants\":1,\"created\":1566576558,\"closedAt\":1566590158}"))
+	require.NoError(t, archiveStorer.PutSiloString("OTHERCID", "1566576559-coffee", "{\"id\":\"1566576559-coffee\",\"question\":\"Coffee?\",\"creator\":\"marco\",\"closed\":true,\"options\":[{\"option\":\"Yes\",\"votes\":1}],\"channelID\":\"OTHERCID\",\"participants\":1,\"created\":1566576559,\"closedAt\":1566590158}"))
+
+	slackRequests := runArchiveSubcommand(t, marcopoller.NewMemoryStorer(), archiveStorer, "history")
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\"*Poll history* (page 1 of 1)\\n" +
+		"• *Dinner?* by \\u003c@marco\\u003e, closed \\u003c!date^1566590158^{date_short_pretty} at {time}|Fri, 23 Aug 2019 19:55:58 UTC\\u003e\\n      Pasta `1` · Curry `0` · `1 participant`\\n" +
+		"• *Lunch?* by \\u003c@polo\\u003e, closed \\u003c!date^1566580158^{date_short_pretty} at {time}|Fri, 23 Aug 2019 17:09:18 UTC\\u003e\\n      Pizza `1` · Tacos `2` · `3 participants`\",\"replace_original\":false}"}, slackRequests)
+}
+
+func TestHistorySubcommandErrors(t *testing.T) {
+	testCases := []struct {
+		name             string
+		text             string
+		expectedResponse string
+	}{
+		{"Without history", "history", "{\"response_type\":\"ephemeral\",\"text\":\"There are no closed polls in this channel yet\",\"replace_original\":false}"},
+		{"Invalid page", "history last", "{\"response_type\":\"ephemeral\",\"text\":\":warning: Wrong usage. `/poll history [page]`\",\"replace_original\":false}"},
+		{"Page out of range", "history 2", "{\"response_type\":\"ephemeral\",\"text\":\"There are no closed polls in this channel yet\",\"replace_original\":false}"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			slackRequests := runArchiveSubcommand(t, marcopoller.NewMemoryStorer(), marcopoller.NewMemoryStorer(), tc.text)
+
+			assert.Equal(t, []string{tc.expectedResponse}, slackRequests)
+		})
+	}
+}
+
+func TestHistorySubcommandWithoutArchive(t *testing.T) {
+	slackRequests := runSubcommand(t, marcopoller.NewMemoryStorer(), &UserFinder{}, "history")
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\":warning: Poll history isn't enabled\",\"replace_original\":false}"}, slackRequests)
+}
@@ -0,0 +1,126 @@
+package marcopoller
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Render styles and their flag and identifiers
+const (
+	// AvatarsRenderStyle shows the avatars of the voters of each option. It's the default render style
+	AvatarsRenderStyle = "avatars"
+
+	// BarsRenderStyle adds a bar chart of the votes of each option and, once voting is closed, sorts options by votes
+	BarsRenderStyle = "bars"
+
+	renderStyleFlag = "--style"
+
+	barChartOptionID     = "barchart"
+	barChartFeatureValue = "Show results as a bar chart"
+
+	barLength     = 10
+	barFilled     = "█"
+	barEmpty      = "░"
+	winnerMarker  = ":trophy:"
+	percentFormat = "%d%%"
+)
+
+// parseRenderStyle parses a render style
+func parseRenderStyle(value string) (style string, err error) {
+	switch strings.ToLower(value) {
+	case AvatarsRenderStyle:
+		return "", nil
+	case BarsRenderStyle:
+		return BarsRenderStyle, nil
+	default:
+		return "", fmt.Errorf("Unsupported style [%s], expected [%s] or [%s]", value, AvatarsRenderStyle, BarsRenderStyle)
+	}
+}
+
+// countVoters returns the number of distinct users who voted on a poll
+func countVoters(votes map[string][]Voter) (count int) {
+	userIDs := make(map[string]bool)
+	for _, voters := range votes {
+		for _, voter := range voters {
+			userIDs[voter.userID] = true
+		}
+	}
+
+	return len(userIDs)
+}
+
+// formatBar formats the votes of an option as a bar with the percentage of voters who voted for it
+func formatBar(count int, totalVoters int) (formatted string) {
+	percent, filled := 0, 0
+	if totalVoters > 0 {
+		ratio := float64(count) / float64(totalVoters)
+		percent = int(math.Round(ratio * 100))
+		filled = int(math.Round(ratio * barLength))
+	}
+
+	bar := strings.Repeat(barFilled, filled) + strings.Repeat(barEmpty, barLength-filled)
+	return fmt.Sprintf("`%s` %s %s", bar, fmt.Sprintf(percentFormat, percent), formatVoteCount(count))
+}
+
+// optionScore returns the score used to sort options by votes: the weighted score on weighted polls and the number of votes otherwise
+func optionScore(poll Poll, voters []Voter) (score float64) {
+	if len(poll.Features.Weights) > 0 {
+		return weightedScore(voters)
+	}
+
+	return float64(len(voters))
+}
+
+// sortedOptionIDs returns the option IDs of a poll sorted by votes. Winners come first followed by the other options by
+// descending score. Options with the same score keep their original order
+func sortedOptionIDs(poll Poll, votes map[string][]Voter, winners map[string]bool) (optionIDs []string) {
+	optionIDs = make([]string, 0, len(poll.Options))
+	for i := range poll.Options {
+		optionIDs = append(optionIDs, fmt.Sprintf("%d", i))
+	}
+
+	sort.SliceStable(optionIDs, func(i, j int) bool {
+		if winners[optionIDs[i]] != winners[optionIDs[j]] {
+			return winners[optionIDs[i]]
+		}
+
+		return optionScore(poll, votes[optionIDs[i]]) > optionScore(poll, votes[optionIDs[j]])
+	})
+
+	return optionIDs
+}
+
+// findWinners returns the winning option IDs of a closed poll. The winner of a ranked choice poll is the winner of the
+// instant-runoff while the winners of other polls are the options with the highest score (ties all win). Polls without
+// votes don't have winners
+func findWinners(poll Poll, votes map[string][]Voter, rounds []runoffRound) (winners map[string]bool) {
+	winners = make(map[string]bool)
+	if poll.Features.RankedChoice {
+		for _, round := range rounds {
+			if round.winner != "" {
+				winners[round.winner] = true
+			}
+		}
+
+		return winners
+	}
+
+	best := 0.
+	for i := range poll.Options {
+		best = math.Max(best, optionScore(poll, votes[fmt.Sprintf("%d", i)]))
+	}
+
+	if best == 0 {
+		return winners
+	}
+
+	for i := range poll.Options {
+		if optionID := fmt.Sprintf("%d", i); optionScore(poll, votes[optionID]) == best {
+			winners[optionID] = true
+		}
+	}
+
+	return winners
+}
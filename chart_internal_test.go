@@ -0,0 +1,69 @@
+package marcopoller
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatBar(t *testing.T) {
+	assert.Equal(t, "`░░░░░░░░░░` 0% `0 votes`", formatBar(0, 0))
+	assert.Equal(t, "`███░░░░░░░` 33% `1 vote`", formatBar(1, 3))
+	assert.Equal(t, "`███████░░░` 67% `2 votes`", formatBar(2, 3))
+	assert.Equal(t, "`██████████` 100% `3 votes`", formatBar(3, 3))
+}
+
+func TestParseRenderStyle(t *testing.T) {
+	features, err := parsePollFlags([]string{"--style=bars"}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, PollFeatures{RenderStyle: BarsRenderStyle}, features)
+
+	features, err = parsePollFlags([]string{"--style=avatars"}, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, PollFeatures{}, features)
+
+	_, err = parsePollFlags([]string{"--style=pie"}, time.Now())
+	assert.EqualError(t, err, "Invalid value for flag [--style]: Unsupported style [pie], expected [avatars] or [bars]")
+}
+
+func TestRenderOpenPollAsBarChart(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos"}, {Text: "Sushi"}}, Creator: "marco", Features: PollFeatures{MultiAnswers: true, Anonymous: true, RenderStyle: BarsRenderStyle}}
+	blocks := renderPoll(poll, map[string][]Voter{"1": []Voter{Voter{userID: "U1"}, Voter{userID: "U2"}}, "2": []Voter{Voter{userID: "U1"}}}, false, defaultMaxVoterAvatars)
+	render, err := json.Marshal(blocks)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(render), "{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Pizza\\n`░░░░░░░░░░` 0% `0 votes`\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"0\",\"style\":\"primary\"}}")
+	assert.Contains(t, string(render), "{\"type\":\"mrkdwn\",\"text\":\" • Tacos\\n`██████████` 100% `2 votes`\"}")
+	assert.Contains(t, string(render), "{\"type\":\"mrkdwn\",\"text\":\" • Sushi\\n`█████░░░░░` 50% `1 vote`\"}")
+	assert.NotContains(t, string(render), ":trophy:")
+}
+
+func TestRenderClosedPollAsBarChartSortsByVotes(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos"}, {Text: "Sushi"}}, Creator: "marco", Features: PollFeatures{RenderStyle: BarsRenderStyle}}
+	blocks := renderPoll(poll, map[string][]Voter{
+		"1": []Voter{Voter{userID: "U1", avatarURL: "https://avatar1.me", name: "User1"}, Voter{userID: "U2", avatarURL: "https://avatar2.me", name: "User2"}},
+		"2": []Voter{Voter{userID: "U3", avatarURL: "https://avatar3.me", name: "User3"}}}, true, defaultMaxVoterAvatars)
+	render, err := json.Marshal(blocks)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*Lunch?*\"}},{\"type\":\"divider\"},"+
+		"{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • :trophy: *Tacos*\\n`███████░░░` 67% `2 votes`\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar1.me\",\"alt_text\":\"User1\"},{\"type\":\"image\",\"image_url\":\"https://avatar2.me\",\"alt_text\":\"User2\"}]},"+
+		"{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Sushi\\n`███░░░░░░░` 33% `1 vote`\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar3.me\",\"alt_text\":\"User3\"}]},"+
+		"{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Pizza\\n`░░░░░░░░░░` 0% `0 votes`\"}},"+
+		"{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e (voting closed)\"}]}]", string(render))
+}
+
+func TestFindWinners(t *testing.T) {
+	poll := Poll{Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos"}, {Text: "Sushi"}}}
+	assert.Equal(t, map[string]bool{}, findWinners(poll, map[string][]Voter{}, nil))
+	assert.Equal(t, map[string]bool{"0": true, "2": true}, findWinners(poll, map[string][]Voter{"0": []Voter{Voter{userID: "U1"}}, "2": []Voter{Voter{userID: "U2"}}}, nil))
+
+	poll.Features.Weights = map[string]float64{"U2": 2}
+	assert.Equal(t, map[string]bool{"2": true}, findWinners(poll, map[string][]Voter{"0": []Voter{Voter{userID: "U1", weight: 1}}, "2": []Voter{Voter{userID: "U2", weight: 2}}}, nil))
+
+	poll.Features = PollFeatures{RankedChoice: true}
+	assert.Equal(t, map[string]bool{"1": true}, findWinners(poll, map[string][]Voter{}, []runoffRound{runoffRound{}, runoffRound{winner: "1"}}))
+}
@@ -0,0 +1,454 @@
+// Command marcopoller runs Marco Poller as a standalone http server for deployments outside of
+// Google Cloud Functions (i.e. kubernetes or a VM).
+//
+// Configuration is read from flags which default to their matching environment variable:
+//
+//	marcopoller -addr=:8080 -slack-token=xoxb-... -signing-secret=... -project-id=my-project
+//
+// Polls are stored in the datastore of the gcloud project unless a local data directory is set with -data-dir
+// or a SQLite database is set with -sqlite. Existing datastore polls, archived polls, templates, schedules, reminders and
+// installed workspace tokens are imported into the SQLite database with:
+//
+//	marcopoller -project-id=my-project -sqlite=/var/lib/marcopoller.db -migrate
+//
+// Due polls are closed, reminders are sent to users who haven't voted and scheduled polls are created on every cleanup run.
+//
+// Marco Poller runs on a single workspace with the slack token unless it's installed on many workspaces with slack's
+// OAuth flow. Installations are enabled with the app's credentials and the public url of /install/callback (which has
+// to be the app's redirect url), in which case the slack token isn't needed:
+//
+//	marcopoller -signing-secret=... -client-id=... -client-secret=... -redirect-url=https://polls.example.com/install/callback
+//
+// The server exposes the following endpoints:
+//
+//	/startPoll         The slash command request url
+//	/registerVote      The interactivity request url
+//	/export            The poll results export
+//	/install           The link that installs the app on a workspace
+//	/install/callback  The redirect url of installations
+//	/healthz           The health check
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/alexandre-normand/marcopoller"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/spf13/cast"
+)
+
+// Environment variables used as flag defaults
+const (
+	addrEnv            = "ADDR"
+	slackTokenEnv      = "SLACK_TOKEN"
+	signingSecretEnv   = "SIGNING_SECRET"
+	exportTokenEnv     = "EXPORT_TOKEN"
+	dataDirEnv         = "DATA_DIR"
+	sqlitePathEnv      = "SQLITE_PATH"
+	pollValidityEnv    = "POLL_VALIDITY"
+	cleanupIntervalEnv = "CLEANUP_INTERVAL"
+	clientIDEnv        = "SLACK_CLIENT_ID"
+	clientSecretEnv    = "SLACK_CLIENT_SECRET"
+	redirectURLEnv     = "SLACK_REDIRECT_URL"
+)
+
+// Server defaults
+const (
+	defaultAddr            = ":8080"
+	defaultCleanupInterval = "5m"
+	shutdownTimeout        = 30 * time.Second
+	readHeaderTimeout      = 10 * time.Second
+	sqliteBusyTimeout      = 5 * time.Second
+)
+
+// Reminder, template, archive and installation storage. Reminders, templates, archived polls and the tokens of installed
+// workspaces are kept next to the polls: in leveldb directories beside the data directory or in tables of the SQLite database
+const (
+	remindersDirSuffix     = "-reminders"
+	remindersTable         = "reminders"
+	templatesDirSuffix     = "-templates"
+	templatesTable         = "templates"
+	archiveDirSuffix       = "-archive"
+	archiveTable           = "archive"
+	installationsDirSuffix = "-installations"
+	installationsTable     = "installations"
+)
+
+// installScopes are the bot scopes requested when installing on a workspace
+var installScopes = []string{"commands", "chat:write", "users:read", "users.profile:read", "usergroups:read", "channels:read", "groups:read"}
+
+// config holds the server configuration
+type config struct {
+	addr            string
+	slackToken      string
+	signingSecret   string
+	projectID       string
+	dataDir         string
+	sqlitePath      string
+	migrate         bool
+	exportToken     string
+	clientID        string
+	clientSecret    string
+	redirectURL     string
+	pollValidity    time.Duration
+	cleanupInterval time.Duration
+	debug           bool
+}
+
+func main() {
+	err := run(os.Args[1:], os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run runs the migration or the server configured by the args and environment. Everything opened along the way
+// (storers, the server and the cleanup goroutine) is closed or stopped before it returns
+func run(args []string, getenv func(key string) string) (err error) {
+	cfg, err := parseConfig(args, getenv)
+	if err != nil {
+		return fmt.Errorf("Invalid configuration: %v", err)
+	}
+
+	if cfg.migrate {
+		return migrate(cfg)
+	}
+
+	mp, err := newMarcoPoller(cfg)
+	if err != nil {
+		return fmt.Errorf("Failed to initialize Marco Poller: %v", err)
+	}
+
+	defer func() {
+		closeErr := mp.Close()
+		if closeErr != nil && err == nil {
+			err = fmt.Errorf("Error closing storage: %v", closeErr)
+		}
+	}()
+
+	return serve(cfg, mp)
+}
+
+// serve serves Marco Poller and runs the periodic cleanup until the server fails or is asked to stop with SIGINT or
+// SIGTERM. On stop, in-flight requests and a running cleanup are given time to complete before it returns
+func serve(cfg config, mp *marcopoller.MarcoPoller) (err error) {
+	server := &http.Server{Addr: cfg.addr, Handler: newServeMux(mp), ReadHeaderTimeout: readHeaderTimeout}
+
+	done := make(chan struct{})
+	var cleanup sync.WaitGroup
+	cleanup.Add(1)
+	go func() {
+		defer cleanup.Done()
+		runPeriodicCleanup(mp, cfg.cleanupInterval, done)
+	}()
+
+	defer func() {
+		close(done)
+		cleanup.Wait()
+	}()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Marco Poller listening on [%s]", cfg.addr)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+
+	select {
+	case err = <-serveErr:
+		return fmt.Errorf("Error serving on [%s]: %v", cfg.addr, err)
+	case <-stop:
+	}
+
+	log.Printf("Shutting down...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err = server.Shutdown(ctx)
+	if err != nil {
+		return fmt.Errorf("Error shutting down: %v", err)
+	}
+
+	return nil
+}
+
+// parseConfig parses the configuration from flags. Each flag defaults to the value of its
+// environment variable as returned by getenv
+func parseConfig(args []string, getenv func(key string) string) (cfg config, err error) {
+	fs := flag.NewFlagSet("marcopoller", flag.ContinueOnError)
+
+	fs.StringVar(&cfg.addr, "addr", envOrDefault(getenv, addrEnv, defaultAddr), fmt.Sprintf("The address to listen on [%s]", addrEnv))
+	fs.StringVar(&cfg.slackToken, "slack-token", getenv(slackTokenEnv), fmt.Sprintf("The slack bot token [%s]", slackTokenEnv))
+	fs.StringVar(&cfg.signingSecret, "signing-secret", getenv(signingSecretEnv), fmt.Sprintf("The slack signing secret [%s]", signingSecretEnv))
+	fs.StringVar(&cfg.projectID, "project-id", getenv(marcopoller.GCPProjectIDEnv), fmt.Sprintf("The gcloud project ID of the datastore [%s]", marcopoller.GCPProjectIDEnv))
+	fs.StringVar(&cfg.dataDir, "data-dir", getenv(dataDirEnv), fmt.Sprintf("The directory of a local leveldb database to use instead of the datastore [%s]", dataDirEnv))
+	fs.StringVar(&cfg.sqlitePath, "sqlite", getenv(sqlitePathEnv), fmt.Sprintf("The path of a SQLite database to use instead of the datastore [%s]", sqlitePathEnv))
+	fs.BoolVar(&cfg.migrate, "migrate", false, "Imports the polls, archived polls, templates, schedules, reminders and workspace tokens of the datastore project into the SQLite database and exits")
+	fs.StringVar(&cfg.exportToken, "export-token", getenv(exportTokenEnv), fmt.Sprintf("The bearer token for exports, exports are verified like slack requests when empty [%s]", exportTokenEnv))
+	fs.StringVar(&cfg.clientID, "client-id", getenv(clientIDEnv), fmt.Sprintf("The slack app's client ID, enables installing on many workspaces [%s]", clientIDEnv))
+	fs.StringVar(&cfg.clientSecret, "client-secret", getenv(clientSecretEnv), fmt.Sprintf("The slack app's client secret [%s]", clientSecretEnv))
+	fs.StringVar(&cfg.redirectURL, "redirect-url", getenv(redirectURLEnv), fmt.Sprintf("The public url of /install/callback, set as the slack app's redirect url [%s]", redirectURLEnv))
+	pollValidity := fs.String("poll-validity", envOrDefault(getenv, pollValidityEnv, "0"), fmt.Sprintf("How long polls stay open for votes before they're deleted, 0 for no expiry (i.e. 720h) [%s]", pollValidityEnv))
+	cleanupInterval := fs.String("cleanup-interval", envOrDefault(getenv, cleanupIntervalEnv, defaultCleanupInterval), fmt.Sprintf("How often expired polls are deleted and due polls are closed [%s]", cleanupIntervalEnv))
+	fs.BoolVar(&cfg.debug, "debug", cast.ToBool(getenv(marcopoller.DebugEnabledEnv)), fmt.Sprintf("Enables debug logging [%s]", marcopoller.DebugEnabledEnv))
+
+	err = fs.Parse(args)
+	if err != nil {
+		return cfg, err
+	}
+
+	cfg.pollValidity, err = time.ParseDuration(*pollValidity)
+	if err != nil {
+		return cfg, fmt.Errorf("Invalid poll validity [%s]: %v", *pollValidity, err)
+	}
+
+	cfg.cleanupInterval, err = time.ParseDuration(*cleanupInterval)
+	if err != nil {
+		return cfg, fmt.Errorf("Invalid cleanup interval [%s]: %v", *cleanupInterval, err)
+	}
+
+	if cfg.cleanupInterval <= 0 {
+		return cfg, fmt.Errorf("Invalid cleanup interval [%s], expected a positive duration", *cleanupInterval)
+	}
+
+	if cfg.dataDir != "" && cfg.sqlitePath != "" {
+		return cfg, fmt.Errorf("Only one of [data-dir] or [sqlite] can be set")
+	}
+
+	if cfg.migrate {
+		if cfg.projectID == "" || cfg.sqlitePath == "" {
+			return cfg, fmt.Errorf("Migrating requires [project-id] and [sqlite]")
+		}
+
+		return cfg, nil
+	}
+
+	if (cfg.clientID != "" || cfg.clientSecret != "" || cfg.redirectURL != "") && (cfg.clientID == "" || cfg.clientSecret == "" || cfg.redirectURL == "") {
+		return cfg, fmt.Errorf("Installing requires [client-id], [client-secret] and [redirect-url]")
+	}
+
+	required := map[string]string{"slack-token": cfg.slackToken, "signing-secret": cfg.signingSecret, "project-id": cfg.projectID}
+	for _, name := range []string{"slack-token", "signing-secret", "project-id"} {
+		// A local database doesn't need a gcloud project
+		if name == "project-id" && (cfg.dataDir != "" || cfg.sqlitePath != "") {
+			continue
+		}
+
+		// Installed workspaces each have their own token
+		if name == "slack-token" && cfg.clientID != "" {
+			continue
+		}
+
+		if required[name] == "" {
+			return cfg, fmt.Errorf("Missing required [%s]", name)
+		}
+	}
+
+	return cfg, nil
+}
+
+// envOrDefault returns the value of an environment variable or the default value when it's empty
+func envOrDefault(getenv func(key string) string, key string, defaultValue string) (value string) {
+	if value = getenv(key); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+// newMarcoPoller returns a new MarcoPoller with the default slack client implementations. Polls are stored in
+// a local leveldb or SQLite database when one is set and in the datastore otherwise
+func newMarcoPoller(cfg config) (mp *marcopoller.MarcoPoller, err error) {
+	var pollVerifier marcopoller.PollVerifier = marcopoller.AlwaysValidPollVerifier{}
+	if cfg.pollValidity > 0 {
+		pollVerifier = marcopoller.ExpirationPollVerifier{ValidityPeriod: cfg.pollValidity}
+	}
+
+	opts := []marcopoller.Option{
+		marcopoller.OptionSlackVerifier(cfg.signingSecret),
+		marcopoller.OptionSlackUserFinder(cfg.slackToken, cfg.debug),
+		marcopoller.OptionSlackDialoguer(cfg.slackToken, cfg.debug),
+		marcopoller.OptionSlackMessenger(cfg.slackToken, cfg.debug),
+		marcopoller.OptionSlackMemberFinder(cfg.slackToken, cfg.debug),
+		marcopoller.OptionPollVerifier(pollVerifier),
+		marcopoller.OptionDebug(cfg.debug),
+	}
+
+	installing := cfg.clientID != ""
+	if installing {
+		opts = append(opts, marcopoller.OptionSlackInstaller(cfg.clientID, cfg.clientSecret, cfg.redirectURL, installScopes...))
+	}
+
+	switch {
+	case cfg.dataDir != "":
+		opts = append(opts, marcopoller.OptionLevelDB(cfg.dataDir), marcopoller.OptionLevelDBReminderStorer(cfg.dataDir+remindersDirSuffix), marcopoller.OptionLevelDBTemplateStorer(cfg.dataDir+templatesDirSuffix), marcopoller.OptionLevelDBArchiveStorer(cfg.dataDir+archiveDirSuffix))
+		if installing {
+			opts = append(opts, marcopoller.OptionLevelDBTokenStore(cfg.dataDir+installationsDirSuffix))
+		}
+	case cfg.sqlitePath != "":
+		storers, err := openSQLite(cfg.sqlitePath)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, marcopoller.OptionStorer(storers.polls), marcopoller.OptionReminderStorer(storers.reminders), marcopoller.OptionTemplateStorer(storers.templates), marcopoller.OptionArchiveStorer(storers.archive))
+		if installing {
+			opts = append(opts, marcopoller.OptionTokenStore(marcopoller.NewStorerTokenStore(storers.installations)))
+		}
+	default:
+		opts = append(opts, marcopoller.OptionDatastore(cfg.projectID), marcopoller.OptionDatastoreReminderStorer(cfg.projectID), marcopoller.OptionDatastoreTemplateStorer(cfg.projectID), marcopoller.OptionDatastoreArchiveStorer(cfg.projectID))
+		if installing {
+			opts = append(opts, marcopoller.OptionDatastoreTokenStore(cfg.projectID))
+		}
+	}
+
+	if cfg.exportToken != "" {
+		opts = append(opts, marcopoller.OptionExportToken(cfg.exportToken))
+	}
+
+	return marcopoller.NewWithOptions(opts...)
+}
+
+// sqliteDSN returns the data source name of the SQLite database at a path. Concurrent writes wait for each other
+// (up to the busy timeout) instead of failing on a locked database
+func sqliteDSN(path string) (dsn string) {
+	return fmt.Sprintf("%s?_busy_timeout=%d", path, sqliteBusyTimeout/time.Millisecond)
+}
+
+// sqliteStorers holds the storers of the tables of a SQLite database
+type sqliteStorers struct {
+	polls         *marcopoller.SQLStorer
+	reminders     *marcopoller.SQLSiloStorer
+	templates     *marcopoller.SQLSiloStorer
+	archive       *marcopoller.SQLSiloStorer
+	installations *marcopoller.SQLSiloStorer
+}
+
+// openSQLite opens the SQLite database at a path and returns the storers of its tables, creating the tables that don't
+// exist
+func openSQLite(path string) (storers sqliteStorers, err error) {
+	db, err := sql.Open("sqlite3", sqliteDSN(path))
+	if err != nil {
+		return storers, err
+	}
+
+	storers.polls, err = marcopoller.NewSQLStorer(db)
+	if err != nil {
+		db.Close()
+		return storers, err
+	}
+
+	for table, storer := range map[string]**marcopoller.SQLSiloStorer{remindersTable: &storers.reminders, templatesTable: &storers.templates, archiveTable: &storers.archive, installationsTable: &storers.installations} {
+		*storer, err = marcopoller.NewSQLSiloStorer(db, table)
+		if err != nil {
+			db.Close()
+			return storers, err
+		}
+	}
+
+	return storers, nil
+}
+
+// migrate imports the polls, archived polls, templates, schedules, reminders and installed workspace tokens of the datastore into
+// the SQLite database
+func migrate(cfg config) (err error) {
+	storers, err := openSQLite(cfg.sqlitePath)
+	if err != nil {
+		return fmt.Errorf("Error initializing [%s]: %v", cfg.sqlitePath, err)
+	}
+
+	defer func() {
+		closeErr := storers.polls.Close()
+		if closeErr != nil && err == nil {
+			err = fmt.Errorf("Error closing [%s]: %v", cfg.sqlitePath, closeErr)
+		}
+	}()
+
+	counts, err := marcopoller.MigrateFromDatastore(cfg.projectID, marcopoller.MigrationStorers{Polls: storers.polls, Archive: storers.archive, Templates: storers.templates, Reminders: storers.reminders, Installations: storers.installations})
+	if err != nil {
+		return fmt.Errorf("Error migrating after importing %+v: %v", counts, err)
+	}
+
+	log.Printf("Imported %d poll(s), %d archived poll value(s), %d template and schedule value(s), %d reminder value(s) and %d workspace token(s) into [%s]", counts.Polls, counts.Archive, counts.Templates, counts.Reminders, counts.Installations, cfg.sqlitePath)
+
+	return nil
+}
+
+// newServeMux returns the mux routing requests to Marco Poller's handlers
+func newServeMux(mp *marcopoller.MarcoPoller) (mux *http.ServeMux) {
+	mux = http.NewServeMux()
+	mux.HandleFunc("/startPoll", mp.StartPoll)
+	mux.HandleFunc("/registerVote", mp.HandleInteractions)
+	mux.HandleFunc("/export", mp.ExportPoll)
+	mux.HandleFunc("/install", mp.StartInstall)
+	mux.HandleFunc("/install/callback", mp.HandleInstall)
+	mux.HandleFunc("/healthz", handleHealth)
+
+	return mux
+}
+
+// handleHealth reports the server as healthy as long as it's serving requests
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, "OK")
+}
+
+// runPeriodicCleanup deletes expired polls and closes due polls every interval until done is closed
+func runPeriodicCleanup(mp *marcopoller.MarcoPoller, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			cleanUp(mp, now)
+		}
+	}
+}
+
+// cleanUp deletes expired polls, closes due polls, sends due reminders and creates due scheduled polls. Errors are logged
+// and retried on the next run
+func cleanUp(mp *marcopoller.MarcoPoller, now time.Time) {
+	deleted, err := mp.DeleteExpiredPolls(now)
+	if err != nil {
+		log.Printf("Error deleting expired polls: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Deleted %d expired poll(s)", deleted)
+	}
+
+	closed, err := mp.CloseDuePolls(now)
+	if err != nil {
+		log.Printf("Error closing due polls: %v", err)
+	} else if closed > 0 {
+		log.Printf("Closed %d due poll(s)", closed)
+	}
+
+	reminded, err := mp.SendReminders(now)
+	if err != nil {
+		log.Printf("Error sending reminders: %v", err)
+	} else if reminded > 0 {
+		log.Printf("Sent %d reminder(s)", reminded)
+	}
+
+	scheduled, err := mp.RunScheduledPolls(now)
+	if err != nil {
+		log.Printf("Error running scheduled polls: %v", err)
+	} else if scheduled > 0 {
+		log.Printf("Created %d scheduled poll(s)", scheduled)
+	}
+}
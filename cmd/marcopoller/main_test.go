@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getenvFrom(env map[string]string) func(key string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+func TestParseConfigFromEnv(t *testing.T) {
+	cfg, err := parseConfig([]string{}, getenvFrom(map[string]string{"SLACK_TOKEN": "xoxb-token", "SIGNING_SECRET": "secret", "PROJECT_ID": "my-project", "POLL_VALIDITY": "720h", "DEBUG": "true"}))
+	require.NoError(t, err)
+
+	assert.Equal(t, config{addr: ":8080", slackToken: "xoxb-token", signingSecret: "secret", projectID: "my-project", pollValidity: 720 * time.Hour, cleanupInterval: 5 * time.Minute, debug: true}, cfg)
+}
+
+func TestParseConfigFlagsOverrideEnv(t *testing.T) {
+	cfg, err := parseConfig([]string{"-addr=:9090", "-slack-token=xoxb-flag", "-cleanup-interval=1h", "-export-token=exportSecret"}, getenvFrom(map[string]string{"ADDR": ":8081", "SLACK_TOKEN": "xoxb-token", "SIGNING_SECRET": "secret", "PROJECT_ID": "my-project"}))
+	require.NoError(t, err)
+
+	assert.Equal(t, config{addr: ":9090", slackToken: "xoxb-flag", signingSecret: "secret", projectID: "my-project", exportToken: "exportSecret", cleanupInterval: time.Hour}, cfg)
+}
+
+func TestParseConfigWithDataDir(t *testing.T) {
+	cfg, err := parseConfig([]string{"-data-dir=/var/lib/marcopoller"}, getenvFrom(map[string]string{"SLACK_TOKEN": "xoxb-token", "SIGNING_SECRET": "secret"}))
+	require.NoError(t, err)
+
+	assert.Equal(t, config{addr: ":8080", slackToken: "xoxb-token", signingSecret: "secret", dataDir: "/var/lib/marcopoller", cleanupInterval: 5 * time.Minute}, cfg)
+}
+
+func TestParseConfigForMigration(t *testing.T) {
+	cfg, err := parseConfig([]string{"-sqlite=/var/lib/marcopoller.db", "-migrate"}, getenvFrom(map[string]string{"PROJECT_ID": "my-project"}))
+	require.NoError(t, err)
+
+	assert.Equal(t, config{addr: ":8080", projectID: "my-project", sqlitePath: "/var/lib/marcopoller.db", migrate: true, cleanupInterval: 5 * time.Minute}, cfg)
+}
+
+func TestParseConfigForInstallations(t *testing.T) {
+	cfg, err := parseConfig([]string{"-redirect-url=https://polls.example.com/install/callback"}, getenvFrom(map[string]string{"SIGNING_SECRET": "secret", "PROJECT_ID": "my-project", "SLACK_CLIENT_ID": "123.456", "SLACK_CLIENT_SECRET": "clientSecret"}))
+	require.NoError(t, err)
+
+	assert.Equal(t, config{addr: ":8080", signingSecret: "secret", projectID: "my-project", clientID: "123.456", clientSecret: "clientSecret", redirectURL: "https://polls.example.com/install/callback", cleanupInterval: 5 * time.Minute}, cfg)
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	tests := map[string]struct {
+		args          []string
+		env           map[string]string
+		expectedError string
+	}{
+		"missing token":            {env: map[string]string{"SIGNING_SECRET": "secret", "PROJECT_ID": "my-project"}, expectedError: "Missing required [slack-token]"},
+		"missing project":          {env: map[string]string{"SLACK_TOKEN": "xoxb-token", "SIGNING_SECRET": "secret"}, expectedError: "Missing required [project-id]"},
+		"invalid validity":         {args: []string{"-poll-validity=forever"}, env: map[string]string{"SLACK_TOKEN": "xoxb-token", "SIGNING_SECRET": "secret", "PROJECT_ID": "my-project"}, expectedError: "Invalid poll validity [forever]: time: invalid duration \"forever\""},
+		"non-positive cleanup":     {args: []string{"-cleanup-interval=0s"}, env: map[string]string{"SLACK_TOKEN": "xoxb-token", "SIGNING_SECRET": "secret", "PROJECT_ID": "my-project"}, expectedError: "Invalid cleanup interval [0s], expected a positive duration"},
+		"many databases":           {args: []string{"-data-dir=/var/lib/marcopoller", "-sqlite=/var/lib/marcopoller.db"}, env: map[string]string{"SLACK_TOKEN": "xoxb-token", "SIGNING_SECRET": "secret"}, expectedError: "Only one of [data-dir] or [sqlite] can be set"},
+		"migration without sqlite": {args: []string{"-migrate"}, env: map[string]string{"PROJECT_ID": "my-project"}, expectedError: "Migrating requires [project-id] and [sqlite]"},
+		"partial installation":     {args: []string{"-client-id=123.456"}, env: map[string]string{"SIGNING_SECRET": "secret", "PROJECT_ID": "my-project"}, expectedError: "Installing requires [client-id], [client-secret] and [redirect-url]"},
+		"invalid cleanup from env": {env: map[string]string{"SLACK_TOKEN": "xoxb-token", "SIGNING_SECRET": "secret", "PROJECT_ID": "my-project", "CLEANUP_INTERVAL": "often"}, expectedError: "Invalid cleanup interval [often]: time: invalid duration \"often\""},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseConfig(tc.args, getenvFrom(tc.env))
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
+
+func TestRunWithInvalidConfiguration(t *testing.T) {
+	err := run([]string{}, getenvFrom(map[string]string{"SIGNING_SECRET": "secret", "PROJECT_ID": "my-project"}))
+	assert.EqualError(t, err, "Invalid configuration: Missing required [slack-token]")
+}
+
+func TestHealth(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	newServeMux(nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+	assert.Equal(t, "OK", w.Body.String())
+}
+
+func TestInstallRedirectsToSlack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "marcopoller")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	mp, err := newMarcoPoller(config{signingSecret: "secret", dataDir: filepath.Join(dir, "polls"), clientID: "123.456", clientSecret: "clientSecret", redirectURL: "https://polls.example.com/install/callback"})
+	require.NoError(t, err)
+	defer mp.Close()
+
+	w := httptest.NewRecorder()
+
+	newServeMux(mp).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/install", nil))
+
+	require.Equal(t, http.StatusFound, w.Result().StatusCode)
+
+	location, err := url.Parse(w.Result().Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "slack.com", location.Host)
+	assert.Equal(t, "123.456", location.Query().Get("client_id"))
+	assert.Equal(t, "https://polls.example.com/install/callback", location.Query().Get("redirect_uri"))
+	assert.Equal(t, "commands,chat:write,users:read,users.profile:read,usergroups:read,channels:read,groups:read", location.Query().Get("scope"))
+}
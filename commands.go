@@ -0,0 +1,314 @@
+package marcopoller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Slash command subcommands
+const (
+	helpSubcommand       = "help"
+	listSubcommand       = "list"
+	closeSubcommand      = "close"
+	resultsSubcommand    = "results"
+	templateSubcommand   = "template"
+	scheduleSubcommand   = "schedule"
+	unscheduleSubcommand = "unschedule"
+	historySubcommand    = "history"
+)
+
+// usage describes the slash command's usage
+const usage = "*Usage*\n" +
+	"• `/poll` opens up a prompt to create a poll\n" +
+	"• `/poll [flags] \"Question\" \"Option 1\" \"Option 2\" ...` creates a poll\n" +
+	"• `/poll list` lists your open polls\n" +
+	"• `/poll close <poll id>` closes one of your polls\n" +
+	"• `/poll results <poll id>` shows the results of a poll\n" +
+	"• `/poll history [page]` shows the results of the polls closed in this channel\n" +
+	"• `/poll template <name>` creates a poll from a template saved in the `/poll` prompt\n" +
+	"• `/poll template` lists the saved templates\n" +
+	"• `/poll schedule <template name> <minute> <hour> <day of month> <month> <day of week>` creates a poll from a template in this channel on a schedule (i.e. `/poll schedule Sprint Retro 0 9 * * mon`)\n" +
+	"• `/poll schedule` lists the scheduled polls\n" +
+	"• `/poll unschedule <template name>` stops creating a template's poll in this channel\n" +
+	"• `/poll help` shows this message\n\n" +
+	"*Flags*\n" +
+	"• `--anonymous` only shows vote counts\n" +
+	"• `--ranked` votes for options in order of preference\n" +
+	"• `--deadline=2h` closes voting automatically (with a duration or a time like 2020-10-20T15:00:00-07:00)\n" +
+	"• `--remind=24h,1h` reminds channel members who haven't voted before the deadline\n" +
+	"• `--keep-results` keeps results available for export after voting closes\n" +
+	"• `--open` allows voters to add options\n" +
+	"• `--hidden-results` hides results until voting closes\n" +
+	"• `--weight=@user:2` gives more weight to the votes of a user or user group\n" +
+	"• `--voters=@user,@group` only allows some users and user groups to vote\n" +
+	"• `--channel-members` only allows members of the channel to vote\n" +
+	"• `--style=bars` shows results as a bar chart"
+
+// parseSubcommand returns the subcommand and its arguments if the slash command text starts with a subcommand. Polls
+// are never mistaken for subcommands since their question is quoted
+func parseSubcommand(text string) (subcommand string, args []string, ok bool) {
+	fields := strings.FieldsFunc(text, unicode.IsSpace)
+	if len(fields) == 0 {
+		return "", nil, false
+	}
+
+	switch strings.ToLower(fields[0]) {
+	case helpSubcommand, listSubcommand, closeSubcommand, resultsSubcommand, historySubcommand, templateSubcommand, scheduleSubcommand, unscheduleSubcommand:
+		return strings.ToLower(fields[0]), fields[1:], true
+	default:
+		return "", nil, false
+	}
+}
+
+// handleSubcommand runs a slash command subcommand on behalf of a user. Subcommands reply with messages only visible to the
+// user except for the template subcommand which creates a poll in the channel
+func (mp *MarcoPoller) handleSubcommand(subcommand string, args []string, userID string, channelID string, responseURL string, w http.ResponseWriter) {
+	if (subcommand == templateSubcommand || subcommand == scheduleSubcommand || subcommand == unscheduleSubcommand) && mp.templateStorer == nil {
+		showErrorToUser(responseURL, ":warning: Templates aren't enabled")
+		return
+	}
+
+	if subcommand == historySubcommand && mp.archiveStorer == nil {
+		showErrorToUser(responseURL, ":warning: Poll history isn't enabled")
+		return
+	}
+
+	switch subcommand {
+	case helpSubcommand:
+		showMessageToUser(responseURL, usage)
+	case listSubcommand:
+		mp.listUserPolls(userID, responseURL)
+	case historySubcommand:
+		mp.showHistory(args, userID, channelID, responseURL)
+	case templateSubcommand:
+		if len(args) == 0 {
+			mp.showTemplates(responseURL)
+			return
+		}
+
+		mp.createPollFromTemplate(strings.Join(args, " "), userID, channelID, responseURL, w)
+	case scheduleSubcommand:
+		if len(args) == 0 {
+			mp.showScheduledPolls(responseURL)
+			return
+		}
+
+		mp.schedulePoll(args, userID, channelID, responseURL)
+	case unscheduleSubcommand:
+		if len(args) == 0 {
+			showErrorToUser(responseURL, ":warning: Wrong usage. `/poll unschedule <template name>`")
+			return
+		}
+
+		mp.unschedulePoll(strings.Join(args, " "), channelID, responseURL)
+	case closeSubcommand, resultsSubcommand:
+		if len(args) != 1 {
+			showErrorToUser(responseURL, fmt.Sprintf(":warning: Wrong usage. `/poll %s <poll id>`", subcommand))
+			return
+		}
+
+		poll, ok := mp.loadPoll(args[0], responseURL)
+		if !ok {
+			return
+		}
+
+		if subcommand == closeSubcommand {
+			mp.closePollByCommand(poll, userID, responseURL)
+		} else {
+			mp.showPollResults(poll, responseURL)
+		}
+	}
+}
+
+// loadPoll loads a poll by ID. If the poll can't be loaded, the user is told why and ok is false
+func (mp *MarcoPoller) loadPoll(pollID string, responseURL string) (poll Poll, ok bool) {
+	encodedPoll, err := mp.storer.GetSiloString(pollID, pollInfoKey)
+	if isNotFound(err) {
+		showErrorToUser(responseURL, fmt.Sprintf(":warning: Poll [%s] not found", pollID))
+		return poll, false
+	} else if err != nil {
+		log.Printf("Error getting existing poll info for id [%s]: %v", pollID, err)
+		showErrorToUser(responseURL, ":warning: Error getting existing poll info. Please try again.")
+		return poll, false
+	}
+
+	poll, err = decodePoll(encodedPoll)
+	if err != nil {
+		log.Printf("Error parsing existing poll [%s] for id [%s]: %v", encodedPoll, pollID, err)
+		showErrorToUser(responseURL, ":warning: Error parsing existing poll info. Please report this issue at https://github.com/alexandre-normand/marcopoller")
+		return poll, false
+	}
+
+	return poll, true
+}
+
+// listUserPolls shows a user the polls they created that are still open
+func (mp *MarcoPoller) listUserPolls(userID string, responseURL string) {
+	entries, err := mp.storer.GlobalScan()
+	if err != nil {
+		log.Printf("Error listing polls: %v", err)
+		showErrorToUser(responseURL, ":warning: Error listing polls. Please try again.")
+		return
+	}
+
+	now := time.Now()
+	polls := make([]Poll, 0)
+	for pollID, values := range entries {
+		encodedPoll, ok := values[pollInfoKey]
+		if !ok {
+			continue
+		}
+
+		poll, err := decodePoll(encodedPoll)
+		if err != nil {
+			log.Printf("Error parsing existing poll [%s] for id [%s]: %v", encodedPoll, pollID, err)
+			continue
+		}
+
+		if poll.Creator == userID && !poll.Closed && !poll.Features.isDue(now) {
+			polls = append(polls, poll)
+		}
+	}
+
+	showMessageToUser(responseURL, formatPollList(polls))
+}
+
+// closePollByCommand closes a poll on behalf of a user. Only the poll creator is allowed to close the poll
+func (mp *MarcoPoller) closePollByCommand(poll Poll, userID string, responseURL string) {
+	if poll.Creator != userID {
+		showErrorToUser(responseURL, fmt.Sprintf(":warning: Only the poll creator (<@%s>) is allowed to close the poll", poll.Creator))
+		return
+	}
+
+	if poll.Closed {
+		showErrorToUser(responseURL, fmt.Sprintf(":warning: Poll [%s] is already closed", poll.ID))
+		return
+	}
+
+	votes, err := mp.listVotes(poll)
+	if err != nil {
+		log.Printf("Error listing votes for poll [%s]: %v", poll.ID, err)
+		showErrorToUser(responseURL, ":warning: Error listing votes for poll. Please try again")
+		return
+	}
+
+	// The poll message is updated with the poll's own response url since the command's response url is for the command's channel
+	err = mp.updatePollMessage(poll, poll.ResponseURL, renderPoll(poll, votes, true, mp.maxVoterAvatars))
+	if err != nil {
+		log.Printf("Error updating poll [%s] message : %v", poll.ID, err)
+		showErrorToUser(responseURL, ":warning: Error updating poll message. Please try again")
+		return
+	}
+
+	err = mp.closePoll(poll, time.Now())
+	if err != nil {
+		log.Printf("Error closing poll [%s]: %s", poll.ID, err.Error())
+		showErrorToUser(responseURL, ":warning: Error closing poll. Please try again")
+		return
+	}
+
+	showMessageToUser(responseURL, fmt.Sprintf("Closed *%s*", poll.Question))
+}
+
+// showPollResults shows a user a summary of the results of a poll. Results of polls with hidden results are only
+// shown once voting is closed
+func (mp *MarcoPoller) showPollResults(poll Poll, responseURL string) {
+	values, err := mp.storer.ScanSilo(poll.ID)
+	if err != nil {
+		log.Printf("Error listing votes for poll [%s]: %v", poll.ID, err)
+		showErrorToUser(responseURL, ":warning: Error listing votes for poll. Please try again.")
+		return
+	}
+
+	var weights map[string]float64
+	if len(poll.Features.Weights) > 0 {
+		weights = mp.voterWeights(poll)
+	}
+
+	closed := poll.Closed || poll.Features.isDue(time.Now())
+	showMessageToUser(responseURL, formatResultsSummary(poll, values, weights, closed))
+}
+
+// formatPollList formats a list of polls for display, sorted by poll ID (which starts with the poll's creation time)
+func formatPollList(polls []Poll) (formatted string) {
+	if len(polls) == 0 {
+		return "You don't have any open polls"
+	}
+
+	sort.Slice(polls, func(i, j int) bool { return polls[i].ID < polls[j].ID })
+
+	lines := []string{"*Your open polls*"}
+	for _, poll := range polls {
+		line := fmt.Sprintf("• `%s` %s", poll.ID, poll.Question)
+		if poll.Features.Deadline != 0 {
+			line = fmt.Sprintf("%s (voting closes %s)", line, formatSlackDate(poll.Features.DeadlineTime()))
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// formatResultsSummary formats the vote counts of a poll given all of its stored values (poll info and votes) and the
+// weights of its voters. Ranked choice polls show the rounds of their instant-runoff tally and weighted polls show the
+// weighted score of each option. Only the number of participants is shown for open polls with hidden results
+func formatResultsSummary(poll Poll, values map[string]string, weights map[string]float64, closed bool) (formatted string) {
+	results := newPollResults(poll, values)
+	participants := countParticipants(values)
+
+	status := "voting is open"
+	if closed {
+		status = "voting closed"
+	}
+
+	lines := []string{fmt.Sprintf("*%s* (%s)", poll.Question, status)}
+	if poll.Features.HiddenResults && !closed {
+		lines = append(lines, fmt.Sprintf("%s · Results are hidden until voting closes", formatParticipantCount(participants)))
+		return strings.Join(lines, "\n")
+	}
+
+	if poll.Features.RankedChoice {
+		rounds, winner := runoffFromValues(poll, values)
+		for i, round := range rounds {
+			lines = append(lines, formatRunoffRound(i+1, round))
+		}
+
+		lines = append(lines, formatRunoffWinner(winner), formatParticipantCount(participants))
+		return strings.Join(lines, "\n")
+	}
+
+	var scores []float64
+	if len(poll.Features.Weights) > 0 {
+		scores = weightedScores(poll, values, weights)
+	}
+
+	for i, opt := range results.Options {
+		line := fmt.Sprintf("• %s %s", opt.Option, formatBar(opt.Votes, participants))
+		if scores != nil {
+			line = fmt.Sprintf("%s · weighted `%s`", line, formatWeight(scores[i]))
+		}
+
+		lines = append(lines, line)
+	}
+
+	lines = append(lines, formatParticipantCount(participants))
+
+	return strings.Join(lines, "\n")
+}
+
+// countParticipants returns the number of users with votes given all of a poll's stored values (poll info and votes)
+func countParticipants(values map[string]string) (count int) {
+	for key, userVotes := range values {
+		if key != pollInfoKey && userVotes != "" {
+			count++
+		}
+	}
+
+	return count
+}
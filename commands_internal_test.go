@@ -0,0 +1,70 @@
+package marcopoller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSubcommand(t *testing.T) {
+	tests := map[string]struct {
+		text               string
+		expectedSubcommand string
+		expectedArgs       []string
+		expectedOK         bool
+	}{
+		"help":             {text: "help", expectedSubcommand: "help", expectedArgs: []string{}, expectedOK: true},
+		"list":             {text: " List ", expectedSubcommand: "list", expectedArgs: []string{}, expectedOK: true},
+		"close":            {text: "close 1566576557-poll1", expectedSubcommand: "close", expectedArgs: []string{"1566576557-poll1"}, expectedOK: true},
+		"results":          {text: "results  1566576557-poll1", expectedSubcommand: "results", expectedArgs: []string{"1566576557-poll1"}, expectedOK: true},
+		"empty":            {text: "", expectedOK: false},
+		"poll":             {text: "\"Favorite thing?\" \"Reading\" \"Running\"", expectedOK: false},
+		"quoted help poll": {text: "\"help\" \"Me\" \"You\"", expectedOK: false},
+		"poll with flags":  {text: "--anonymous \"Lunch?\" \"Pizza\" \"Tacos\"", expectedOK: false},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			subcommand, args, ok := parseSubcommand(tc.text)
+
+			assert.Equal(t, tc.expectedOK, ok)
+			assert.Equal(t, tc.expectedSubcommand, subcommand)
+			assert.Equal(t, tc.expectedArgs, args)
+		})
+	}
+}
+
+func TestFormatPollList(t *testing.T) {
+	assert.Equal(t, "You don't have any open polls", formatPollList([]Poll{}))
+
+	polls := []Poll{
+		Poll{ID: "1566576558-poll2", Question: "Dinner?", Features: PollFeatures{Deadline: 1566590000}},
+		Poll{ID: "1566576557-poll1", Question: "Lunch?"},
+	}
+	assert.Equal(t, "*Your open polls*\n• `1566576557-poll1` Lunch?\n• `1566576558-poll2` Dinner? (voting closes <!date^1566590000^{date_short_pretty} at {time}|Fri, 23 Aug 2019 19:53:20 UTC>)", formatPollList(polls))
+}
+
+func TestFormatResultsSummary(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos"}}, Features: PollFeatures{MultiAnswers: true}}
+	values := map[string]string{pollInfoKey: "{}", "U1": "0,1", "U2": "1"}
+
+	assert.Equal(t, "*Lunch?* (voting is open)\n• Pizza `█████░░░░░` 50% `1 vote`\n• Tacos `██████████` 100% `2 votes`\n`2 participants`", formatResultsSummary(poll, values, nil, false))
+
+	poll.Features.HiddenResults = true
+	assert.Equal(t, "*Lunch?* (voting is open)\n`2 participants` · Results are hidden until voting closes", formatResultsSummary(poll, values, nil, false))
+	assert.Equal(t, "*Lunch?* (voting closed)\n• Pizza `█████░░░░░` 50% `1 vote`\n• Tacos `██████████` 100% `2 votes`\n`2 participants`", formatResultsSummary(poll, values, nil, true))
+}
+
+func TestFormatResultsSummaryOfRankedPoll(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos"}, {Text: "Sushi"}}, Features: PollFeatures{RankedChoice: true}}
+	values := map[string]string{pollInfoKey: "{}", "U1": "0,2", "U2": "1", "U3": "2,0", "U4": "0", "U5": "1,0"}
+
+	assert.Equal(t, "*Lunch?* (voting closed)\nRound 1: Pizza: 2, Tacos: 2, Sushi: 1 (eliminated: Sushi)\nRound 2: Pizza: 3, Tacos: 2\n:trophy: Winner: *Pizza*\n`5 participants`", formatResultsSummary(poll, values, nil, true))
+}
+
+func TestFormatResultsSummaryOfWeightedPoll(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos"}}, Features: PollFeatures{Weights: map[string]float64{"U1": 3}}}
+	values := map[string]string{pollInfoKey: "{}", "U1": "0", "U2": "1", "U3": "1"}
+
+	assert.Equal(t, "*Lunch?* (voting is open)\n• Pizza `███░░░░░░░` 33% `1 vote` · weighted `3`\n• Tacos `███████░░░` 67% `2 votes` · weighted `2`\n`3 participants`", formatResultsSummary(poll, values, map[string]float64{"U1": 3}, false))
+}
@@ -0,0 +1,144 @@
+package marcopoller_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/marcopoller"
+	mmocks "github.com/alexandre-normand/marcopoller/mocks"
+	"github.com/alexandre-normand/slackscot/store"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runSubcommand sends a /poll command from marco with the text and returns the requests sent to slack
+func runSubcommand(t *testing.T, storer store.GlobalSiloStringStorer, userFinder *UserFinder, text string) (slackRequests []string) {
+	server := newSlackServer()
+	defer server.Close()
+
+	body := fmt.Sprintf("token=sometoken&team_id=TEAMID3&channel_id=CID&user_id=marco&command=%%2Fpoll&text=%s&response_url=%s&trigger_id=someTriggerID", url.QueryEscape(text), server.URL)
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.StartPoll(w, r)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	return server.Requests()
+}
+
+// newSubcommandStorer returns a storer with an open poll and a closed poll created by marco and a poll created by polo
+func newSubcommandStorer(t *testing.T, responseURL string) (storer *marcopoller.MemoryStorer) {
+	storer = marcopoller.NewMemoryStorer()
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", fmt.Sprintf("{\"id\":\"1566576557-poll1\",\"responseURL\":\"%s\",\"question\":\"Lunch?\",\"options\":[\"Pizza\",\"Tacos\"],\"features\":{\"keepResults\":true},\"creator\":\"marco\"}", responseURL)))
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "marco", "1"))
+	require.NoError(t, storer.PutSiloString("1566576558-poll2", "pollInfo", "{\"id\":\"1566576558-poll2\",\"question\":\"Dinner?\",\"options\":[\"Pasta\",\"Curry\"],\"creator\":\"marco\",\"closed\":true}"))
+	require.NoError(t, storer.PutSiloString("1566576559-poll3", "pollInfo", "{\"id\":\"1566576559-poll3\",\"question\":\"Coffee?\",\"options\":[\"Yes\",\"No\"],\"creator\":\"polo\"}"))
+
+	return storer
+}
+
+func TestHelpSubcommand(t *testing.T) {
+	slackRequests := runSubcommand(t, marcopoller.NewMemoryStorer(), &UserFinder{}, "help")
+
+	require.Len(t, slackRequests, 1)
+	assert.Contains(t, slackRequests[0], "\"response_type\":\"ephemeral\",\"text\":\"*Usage*")
+}
+
+func TestListSubcommand(t *testing.T) {
+	slackRequests := runSubcommand(t, newSubcommandStorer(t, ""), &UserFinder{}, "list")
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\"*Your open polls*\\n• `1566576557-poll1` Lunch?\",\"replace_original\":false}"}, slackRequests)
+}
+
+func TestResultsSubcommand(t *testing.T) {
+	slackRequests := runSubcommand(t, newSubcommandStorer(t, ""), &UserFinder{}, "results 1566576557-poll1")
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\"*Lunch?* (voting is open)\\n• Pizza `░░░░░░░░░░` 0% `0 votes`\\n• Tacos `██████████` 100% `1 vote`\\n`1 participant`\",\"replace_original\":false}"}, slackRequests)
+}
+
+func TestSubcommandOnUnknownPoll(t *testing.T) {
+	slackRequests := runSubcommand(t, newSubcommandStorer(t, ""), &UserFinder{}, "results 1566576557-missing")
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\":warning: Poll [1566576557-missing] not found\",\"replace_original\":false}"}, slackRequests)
+}
+
+func TestSubcommandWithoutPollID(t *testing.T) {
+	slackRequests := runSubcommand(t, newSubcommandStorer(t, ""), &UserFinder{}, "close")
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\":warning: Wrong usage. `/poll close \\u003cpoll id\\u003e`\",\"replace_original\":false}"}, slackRequests)
+}
+
+func TestCloseSubcommand(t *testing.T) {
+	pollMessageRequest := ""
+	pollServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := ioutil.ReadAll(r.Body)
+		pollMessageRequest = string(reqBody)
+		fmt.Fprintln(w, "OK")
+	}))
+	defer pollServer.Close()
+
+	storer := newSubcommandStorer(t, pollServer.URL)
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "marco").Return(&slack.User{ID: "marco", Profile: slack.UserProfile{Image24: "http://image.me", RealName: "Marco Poller"}}, nil)
+	defer userFinder.AssertExpectations(t)
+
+	slackRequests := runSubcommand(t, storer, userFinder, "close 1566576557-poll1")
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\"Closed *Lunch?*\",\"replace_original\":false}"}, slackRequests)
+	assert.Contains(t, pollMessageRequest, "Created by \\u003c@marco\\u003e (voting closed)")
+
+	pollInfo, err := storer.GetSiloString("1566576557-poll1", "pollInfo")
+	require.NoError(t, err)
+	assert.Contains(t, pollInfo, "\"closed\":true")
+}
+
+func TestCloseSubcommandErrors(t *testing.T) {
+	tests := map[string]struct {
+		text        string
+		expectedMsg string
+	}{
+		"not creator":    {text: "close 1566576559-poll3", expectedMsg: ":warning: Only the poll creator (\\u003c@polo\\u003e) is allowed to close the poll"},
+		"already closed": {text: "close 1566576558-poll2", expectedMsg: ":warning: Poll [1566576558-poll2] is already closed"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			slackRequests := runSubcommand(t, newSubcommandStorer(t, ""), &UserFinder{}, tc.text)
+
+			assert.Equal(t, []string{fmt.Sprintf("{\"response_type\":\"ephemeral\",\"text\":\"%s\",\"replace_original\":false}", tc.expectedMsg)}, slackRequests)
+		})
+	}
+}
+
+func TestSlashCommandOptionsAreLiteral(t *testing.T) {
+	storer := marcopoller.NewMemoryStorer()
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "marco").Return(&slack.User{Profile: slack.UserProfile{Image24: "http://image.me"}}, nil).Maybe()
+
+	slackRequests := runSubcommand(t, storer, userFinder, "\"Which one?\" \"A|B\" \"C | https://docs.example.com/c\"")
+	require.Len(t, slackRequests, 1)
+	assert.Contains(t, slackRequests[0], "{\"type\":\"mrkdwn\",\"text\":\" • A|B\"}")
+
+	polls, err := storer.GlobalScan()
+	require.NoError(t, err)
+	require.Len(t, polls, 1)
+	for _, values := range polls {
+		assert.Contains(t, values["pollInfo"], "\"options\":[\"A|B\",\"C | https://docs.example.com/c\"]")
+	}
+}
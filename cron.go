@@ -0,0 +1,168 @@
+package marcopoller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron expression fields and limits
+const (
+	cronFieldCount = 5
+
+	// cronSearchYears bounds the search for the next run of a schedule that can never happen (i.e. on February 30th)
+	cronSearchYears = 5
+)
+
+// cronField describes the range of values and the names allowed in a cron expression field
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	minuteField     = cronField{name: "minute", min: 0, max: 59}
+	hourField       = cronField{name: "hour", min: 0, max: 23}
+	dayOfMonthField = cronField{name: "day of month", min: 1, max: 31}
+	monthField      = cronField{name: "month", min: 1, max: 12, names: map[string]int{"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12}}
+
+	// Sunday is both 0 and 7 like in most cron implementations
+	dayOfWeekField = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6}}
+)
+
+// cronSchedule represents a parsed cron expression (minute hour day-of-month month day-of-week) with the allowed values of each field
+type cronSchedule struct {
+	minutes     map[int]bool
+	hours       map[int]bool
+	daysOfMonth map[int]bool
+	months      map[int]bool
+	daysOfWeek  map[int]bool
+
+	// When both days are restricted, a day matching either of them matches (like in most cron implementations)
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+// parseCronSchedule parses a cron expression with 5 fields: minute, hour, day of month, month and day of week. Fields
+// support wildcards (*), values, names (i.e. mon, jan), ranges (1-5), lists (1,3,5) and steps (*/15)
+func parseCronSchedule(expression string) (schedule cronSchedule, err error) {
+	fields := strings.Fields(expression)
+	if len(fields) != cronFieldCount {
+		return schedule, fmt.Errorf("Invalid schedule [%s], expected 5 fields: minute, hour, day of month, month and day of week (i.e. 0 9 * * mon)", expression)
+	}
+
+	parsed := make([]map[int]bool, cronFieldCount)
+	for i, f := range []cronField{minuteField, hourField, dayOfMonthField, monthField, dayOfWeekField} {
+		parsed[i], err = f.parse(fields[i])
+		if err != nil {
+			return schedule, err
+		}
+	}
+
+	if parsed[4][7] {
+		parsed[4][0] = true
+	}
+
+	return cronSchedule{minutes: parsed[0], hours: parsed[1], daysOfMonth: parsed[2], months: parsed[3], daysOfWeek: parsed[4], anyDayOfMonth: fields[2] == "*", anyDayOfWeek: fields[4] == "*"}, nil
+}
+
+// parse returns the values allowed by a cron field
+func (cf cronField) parse(field string) (values map[int]bool, err error) {
+	values = make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i != -1 {
+			rangePart = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("Invalid %s step in [%s]", cf.name, part)
+			}
+		}
+
+		start, end := cf.min, cf.max
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			start, err = cf.value(bounds[0])
+			if err != nil {
+				return nil, err
+			}
+
+			end = start
+			if len(bounds) == 2 {
+				end, err = cf.value(bounds[1])
+				if err != nil {
+					return nil, err
+				}
+			} else if step > 1 {
+				end = cf.max
+			}
+
+			if end < start {
+				return nil, fmt.Errorf("Invalid %s range [%s]", cf.name, rangePart)
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			values[v] = true
+		}
+	}
+
+	return values, nil
+}
+
+// value parses a single value (a number or a name) of a cron field
+func (cf cronField) value(raw string) (value int, err error) {
+	if v, ok := cf.names[strings.ToLower(raw)]; ok {
+		return v, nil
+	}
+
+	value, err = strconv.Atoi(raw)
+	if err != nil || value < cf.min || value > cf.max {
+		return 0, fmt.Errorf("Invalid %s [%s], expected a value between %d and %d", cf.name, raw, cf.min, cf.max)
+	}
+
+	return value, nil
+}
+
+// matchesDay returns true if the day of a time is allowed by the schedule
+func (cs cronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth, dayOfWeek := cs.daysOfMonth[t.Day()], cs.daysOfWeek[int(t.Weekday())]
+
+	switch {
+	case cs.anyDayOfMonth && cs.anyDayOfWeek:
+		return true
+	case cs.anyDayOfMonth:
+		return dayOfWeek
+	case cs.anyDayOfWeek:
+		return dayOfMonth
+	default:
+		return dayOfMonth || dayOfWeek
+	}
+}
+
+// next returns the first time strictly after the given time matching the schedule in the time's location. A zero time
+// is returned if the schedule never matches
+func (cs cronSchedule) next(after time.Time) (next time.Time) {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.AddDate(cronSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case !cs.months[int(t.Month())]:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !cs.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !cs.hours[t.Hour()]:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !cs.minutes[t.Minute()]:
+			t = t.Truncate(time.Minute).Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,80 @@
+package marcopoller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// Friday October 16th 2020 at 10:30 UTC
+	after := time.Date(2020, time.October, 16, 10, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name         string
+		expression   string
+		expectedNext time.Time
+	}{
+		{"Every minute", "* * * * *", time.Date(2020, time.October, 16, 10, 31, 0, 0, time.UTC)},
+		{"Every 15 minutes", "*/15 * * * *", time.Date(2020, time.October, 16, 10, 45, 0, 0, time.UTC)},
+		{"Daily later today", "0 12 * * *", time.Date(2020, time.October, 16, 12, 0, 0, 0, time.UTC)},
+		{"Daily tomorrow", "0 9 * * *", time.Date(2020, time.October, 17, 9, 0, 0, 0, time.UTC)},
+		{"Weekly by name", "0 9 * * MON", time.Date(2020, time.October, 19, 9, 0, 0, 0, time.UTC)},
+		{"Weekdays range", "30 8 * * mon-fri", time.Date(2020, time.October, 19, 8, 30, 0, 0, time.UTC)},
+		{"Sunday as 7", "0 0 * * 7", time.Date(2020, time.October, 18, 0, 0, 0, 0, time.UTC)},
+		{"Monthly", "0 9 1 * *", time.Date(2020, time.November, 1, 9, 0, 0, 0, time.UTC)},
+		{"Day of month or day of week", "0 9 20 * sat", time.Date(2020, time.October, 17, 9, 0, 0, 0, time.UTC)},
+		{"Yearly", "0 0 1 jan *", time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"List", "0 9,17 * * *", time.Date(2020, time.October, 16, 17, 0, 0, 0, time.UTC)},
+		{"Leap day", "0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			schedule, err := parseCronSchedule(tc.expression)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedNext, schedule.next(after))
+		})
+	}
+}
+
+func TestCronScheduleNextInLocation(t *testing.T) {
+	location, err := time.LoadLocation("America/Montreal")
+	require.NoError(t, err)
+
+	schedule, err := parseCronSchedule("0 9 * * mon")
+	require.NoError(t, err)
+
+	next := schedule.next(time.Date(2020, time.October, 16, 10, 30, 0, 0, time.UTC).In(location))
+	assert.Equal(t, time.Date(2020, time.October, 19, 13, 0, 0, 0, time.UTC), next.UTC())
+}
+
+func TestCronScheduleNeverMatching(t *testing.T) {
+	schedule, err := parseCronSchedule("0 0 30 feb *")
+	require.NoError(t, err)
+
+	assert.True(t, schedule.next(time.Date(2020, time.October, 16, 10, 30, 0, 0, time.UTC)).IsZero())
+}
+
+func TestParseInvalidCronSchedule(t *testing.T) {
+	testCases := []struct {
+		expression    string
+		expectedError string
+	}{
+		{"0 9 * *", "Invalid schedule [0 9 * *], expected 5 fields: minute, hour, day of month, month and day of week (i.e. 0 9 * * mon)"},
+		{"60 9 * * *", "Invalid minute [60], expected a value between 0 and 59"},
+		{"0 9 * * funday", "Invalid day of week [funday], expected a value between 0 and 7"},
+		{"*/0 9 * * *", "Invalid minute step in [*/0]"},
+		{"0 17-9 * * *", "Invalid hour range [17-9]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expression, func(t *testing.T) {
+			_, err := parseCronSchedule(tc.expression)
+			assert.EqualError(t, err, tc.expectedError)
+		})
+	}
+}
@@ -0,0 +1,446 @@
+package marcopoller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+)
+
+// Edit prompt identifiers
+const (
+	editPollCallbackID    = "poll-edit"
+	confirmEditCallbackID = "poll-edit-confirm"
+
+	editOptionInputBlockIDPrefix = "poll_option_"
+	editOptionActionID           = "poll_option"
+)
+
+// pollEdit holds the question and options entered in the edit prompt. Options are kept at the position of the
+// option they edit with removed options left nil so that votes follow the options they were made on
+type pollEdit struct {
+	Question   string        `json:"question"`
+	Options    []*PollOption `json:"options"`
+	NewOptions []PollOption  `json:"newOptions,omitempty"`
+}
+
+// handlePollEditRequest handles a request to edit a poll by opening up the edit prompt prefilled with the poll's
+// question and options. Only the poll creator is allowed to edit a poll
+func (mp *MarcoPoller) handlePollEditRequest(poll Poll, callback InteractionCallback, w http.ResponseWriter) {
+	if poll.Creator != callback.User.ID {
+		showErrorToUser(callback.ResponseURL, fmt.Sprintf(":warning: Only the poll creator (<@%s>) is allowed to edit the poll", poll.Creator))
+		return
+	}
+
+	if poll.Closed || poll.Features.isDue(actionTime(callback)) {
+		showErrorToUser(callback.ResponseURL, ":warning: Sorry, voting on this poll is closed")
+		return
+	}
+
+	metadata, err := encodeViewMetadata(pollViewMetadata{PollID: poll.ID, ResponseURL: callback.ResponseURL, EditedOptions: len(poll.Options)})
+	if err != nil {
+		log.Printf("Error encoding view metadata for poll [%s]: %v", poll.ID, err)
+		showErrorToUser(callback.ResponseURL, ":warning: Error opening up the prompt to edit the poll. Please report this at https://github.com/alexandre-normand/marcopoller.")
+		return
+	}
+
+	_, err = mp.dialoguer.OpenView(callback.TriggerID, createEditPollPrompt(poll, metadata))
+	if err != nil {
+		log.Printf("Error opening up edit prompt for trigger id [%s]: %s", callback.TriggerID, err.Error())
+		showErrorToUser(callback.ResponseURL, ":warning: Error opening up the prompt to edit the poll. Try again, maybe?")
+		return
+	}
+}
+
+// createEditPollPrompt renders the content of the dialog to edit a poll. Each option gets its own input so that
+// votes stay on an option when it's renamed and are only dropped when its input is cleared
+func createEditPollPrompt(poll Poll, metadata string) (viewRequest slack.ModalViewRequest) {
+	blocks := make([]slack.Block, 0)
+
+	questionInput := slack.NewPlainTextInputBlockElement(slack.NewTextBlockObject("plain_text", "What's your favorite color?", false, false), pollQuestionActionID)
+	questionInput.InitialValue = poll.Question
+	blocks = append(blocks, slack.NewInputBlock(pollQuestionInputBlockID, slack.NewTextBlockObject("plain_text", "What's your poll about?", false, false), questionInput))
+
+	for i, opt := range poll.Options {
+		optionInput := slack.NewPlainTextInputBlockElement(slack.NewTextBlockObject("plain_text", "Leave empty to remove the option and its votes", false, false), editOptionActionID)
+		optionInput.InitialValue = formatOptionLine(opt)
+		optionBlock := slack.NewInputBlock(editOptionBlockID(i), slack.NewTextBlockObject("plain_text", fmt.Sprintf("Option %d", i+1), false, false), optionInput)
+		optionBlock.Optional = true
+		blocks = append(blocks, optionBlock)
+	}
+
+	newOptionsInput := slack.NewPlainTextInputBlockElement(slack.NewTextBlockObject("plain_text", "More color options (one per line)", false, false), pollOptionsActionID)
+	newOptionsInput.Multiline = true
+	newOptionsBlock := slack.NewInputBlock(pollOptionsInputBlockID, slack.NewTextBlockObject("plain_text", "New options", false, false), newOptionsInput)
+	newOptionsBlock.Optional = true
+	newOptionsBlock.Hint = slack.NewTextBlockObject("plain_text", "Enter the new answer options (one per line) as :emoji: Option | Description | https://link", false, false)
+	blocks = append(blocks, newOptionsBlock)
+
+	viewRequest.Type = slack.VTModal
+	viewRequest.Title = slack.NewTextBlockObject("plain_text", friendlyName, false, false)
+	viewRequest.Close = slack.NewTextBlockObject("plain_text", "Cancel", false, false)
+	viewRequest.Submit = slack.NewTextBlockObject("plain_text", "Save", false, false)
+	viewRequest.CallbackID = editPollCallbackID
+	viewRequest.PrivateMetadata = metadata
+	viewRequest.Blocks = slack.Blocks{BlockSet: blocks}
+
+	return viewRequest
+}
+
+// createConfirmEditPrompt renders the content of the dialog confirming an edit that removes options with votes
+func createConfirmEditPrompt(removed []OptionResult, metadata string) (viewRequest slack.ModalViewRequest) {
+	blocks := make([]slack.Block, 0)
+
+	blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", "The following options are removed along with their votes:", false, false), nil, nil))
+	for _, opt := range removed {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", fmt.Sprintf(" • %s %s", opt.Option, formatVoteCount(opt.Votes)), false, false), nil, nil))
+	}
+
+	viewRequest.Type = slack.VTModal
+	viewRequest.Title = slack.NewTextBlockObject("plain_text", friendlyName, false, false)
+	viewRequest.Close = slack.NewTextBlockObject("plain_text", "Back", false, false)
+	viewRequest.Submit = slack.NewTextBlockObject("plain_text", "Remove votes", false, false)
+	viewRequest.CallbackID = confirmEditCallbackID
+	viewRequest.PrivateMetadata = metadata
+	viewRequest.Blocks = slack.Blocks{BlockSet: blocks}
+
+	return viewRequest
+}
+
+// handlePollEditSubmission handles a submission of the edit dialog or of its confirmation dialog. An edit removing
+// options with votes asks for confirmation before it's applied
+func (mp *MarcoPoller) handlePollEditSubmission(callback InteractionCallback, w http.ResponseWriter) {
+	metadata, err := decodeViewMetadata(callback.View.PrivateMetadata)
+	if err != nil {
+		log.Printf("Error decoding view metadata [%s]: %v", callback.View.PrivateMetadata, err)
+		return
+	}
+
+	encodedPoll, err := mp.storer.GetSiloString(metadata.PollID, pollInfoKey)
+	if err != nil {
+		log.Printf("Error getting existing poll info for id [%s]: %v", metadata.PollID, err)
+		showErrorToUser(metadata.ResponseURL, ":warning: Error getting existing poll info. Please try again.")
+		return
+	}
+
+	poll, err := decodePoll(encodedPoll)
+	if err != nil {
+		log.Printf("Error parsing existing poll [%s] for id [%s]: %v", encodedPoll, metadata.PollID, err)
+		showErrorToUser(metadata.ResponseURL, ":warning: Error parsing existing poll info. Please report this issue at https://github.com/alexandre-normand/marcopoller")
+		return
+	}
+
+	if poll.Creator != callback.User.ID {
+		showErrorToUser(metadata.ResponseURL, fmt.Sprintf(":warning: Only the poll creator (<@%s>) is allowed to edit the poll", poll.Creator))
+		return
+	}
+
+	if poll.Closed || poll.Features.isDue(time.Now()) {
+		showErrorToUser(metadata.ResponseURL, ":warning: Sorry, voting on this poll is closed")
+		return
+	}
+
+	values, err := mp.storer.ScanSilo(poll.ID)
+	if err != nil {
+		log.Printf("Error listing votes for poll [%s]: %v", poll.ID, err)
+		showErrorToUser(metadata.ResponseURL, ":warning: Error listing votes for poll. Please try again.")
+		return
+	}
+
+	if callback.View.CallbackID == editPollCallbackID {
+		edit, errs := parseEditedPoll(callback, metadata.EditedOptions)
+		if len(errs) > 0 {
+			writeViewSubmissionResponse(w, slack.NewErrorsViewSubmissionResponse(errs))
+			return
+		}
+
+		_, moves, errs := editOptions(poll, edit)
+		if len(errs) > 0 {
+			writeViewSubmissionResponse(w, slack.NewErrorsViewSubmissionResponse(errs))
+			return
+		}
+
+		metadata.Edit = &edit
+
+		removed := removedOptionsWithVotes(poll, moves, values)
+		if len(removed) > 0 {
+			pendingEdit, err := encodeViewMetadata(metadata)
+			if err != nil {
+				log.Printf("Error encoding view metadata for poll [%s]: %v", poll.ID, err)
+				showErrorToUser(metadata.ResponseURL, ":warning: Error editing poll. Please report this at https://github.com/alexandre-normand/marcopoller.")
+				return
+			}
+
+			confirmation := createConfirmEditPrompt(removed, pendingEdit)
+			writeViewSubmissionResponse(w, slack.NewPushViewSubmissionResponse(&confirmation))
+			return
+		}
+	}
+
+	if metadata.Edit == nil {
+		log.Printf("Error editing poll [%s]: view metadata [%s] has no edit", poll.ID, callback.View.PrivateMetadata)
+		showErrorToUser(metadata.ResponseURL, ":warning: Error editing poll. Please report this at https://github.com/alexandre-normand/marcopoller.")
+		return
+	}
+
+	// The confirmation is pushed on top of the edit dialog so both are closed once the edit is confirmed
+	if callback.View.CallbackID == confirmEditCallbackID {
+		writeViewSubmissionResponse(w, slack.NewClearViewSubmissionResponse())
+	}
+
+	poll, err = mp.applyPollEdit(poll.ID, values, *metadata.Edit)
+	if err != nil {
+		log.Printf("Error editing poll [%s]: %v", poll.ID, err)
+		showErrorToUser(metadata.ResponseURL, ":warning: Error persisting poll changes. Please try again.")
+		return
+	}
+
+	votes, err := mp.listVotes(poll)
+	if err != nil {
+		log.Printf("Error listing votes for poll [%s]: %v", poll.ID, err)
+		showErrorToUser(metadata.ResponseURL, ":warning: Error listing votes for poll. Please try again.")
+		return
+	}
+
+	err = mp.updatePollMessage(poll, metadata.ResponseURL, renderPoll(poll, votes, false, mp.maxVoterAvatars))
+	if err != nil {
+		log.Printf("Error updating poll [%s] message : %v", poll.ID, err)
+		showErrorToUser(metadata.ResponseURL, ":warning: Error updating slack message for poll. Please try again.")
+		return
+	}
+}
+
+// editOptionBlockID returns the block ID of the input of the option at index i in the edit prompt
+func editOptionBlockID(i int) (blockID string) {
+	return fmt.Sprintf("%s%d", editOptionInputBlockIDPrefix, i)
+}
+
+// parseEditedPoll returns the edit entered in an edit dialog opened with optionCount options. Validation errors
+// are returned keyed by the block ID of the invalid input
+func parseEditedPoll(callback InteractionCallback, optionCount int) (edit pollEdit, errs map[string]string) {
+	errs = make(map[string]string)
+	if callback.View.State == nil {
+		errs[pollQuestionInputBlockID] = "Enter a question"
+		return edit, errs
+	}
+
+	values := callback.View.State.Values
+	edit.Question = strings.TrimSpace(values[pollQuestionInputBlockID][pollQuestionActionID].Value)
+
+	if edit.Question == "" {
+		errs[pollQuestionInputBlockID] = "Enter a question"
+	}
+
+	edit.Options = make([]*PollOption, optionCount)
+	for i := range edit.Options {
+		rawOption := values[editOptionBlockID(i)][editOptionActionID].Value
+		if strings.TrimSpace(rawOption) != "" {
+			option := parseOption(rawOption)
+			edit.Options[i] = &option
+		}
+	}
+
+	edit.NewOptions = parseOptions(splitOptions(values[pollOptionsInputBlockID][pollOptionsActionID].Value))
+
+	return edit, errs
+}
+
+// editOptions returns the options of a poll once edited along with the new index of each of the poll's options
+// (-1 for removed options). Options added to the poll after the edit prompt was opened are kept after the edited
+// options. Edited options follow the same rules as options added by voters and validation errors are returned keyed
+// by the block ID of the invalid input
+func editOptions(poll Poll, edit pollEdit) (options []PollOption, moves []int, errs map[string]string) {
+	errs = make(map[string]string)
+	options = make([]PollOption, 0, len(poll.Options)+len(edit.NewOptions))
+	moves = make([]int, len(poll.Options))
+
+	addOption := func(option PollOption, blockID string) (index int) {
+		err := validateOption(options, option)
+		if err != nil {
+			if _, exists := errs[blockID]; !exists {
+				errs[blockID] = err.Error()
+			}
+
+			return -1
+		}
+
+		options = append(options, option)
+		return len(options) - 1
+	}
+
+	for i, opt := range poll.Options {
+		if i >= len(edit.Options) {
+			moves[i] = addOption(opt, pollOptionsInputBlockID)
+			continue
+		}
+
+		if edit.Options[i] == nil {
+			moves[i] = -1
+			continue
+		}
+
+		moves[i] = addOption(*edit.Options[i], editOptionBlockID(i))
+	}
+
+	for _, opt := range edit.NewOptions {
+		addOption(opt, pollOptionsInputBlockID)
+	}
+
+	if len(options) == 0 {
+		errs[pollOptionsInputBlockID] = "Enter at least one option"
+	}
+
+	return options, moves, errs
+}
+
+// removedOptionsWithVotes returns the options of a poll that have votes and are removed by an edit given the new
+// index of each option
+func removedOptionsWithVotes(poll Poll, moves []int, values map[string]string) (removed []OptionResult) {
+	removed = make([]OptionResult, 0)
+	for i, result := range newPollResults(poll, values).Options {
+		if moves[i] < 0 && result.Votes > 0 {
+			removed = append(removed, result)
+		}
+	}
+
+	return removed
+}
+
+// applyPollEdit updates the question and options of a poll given the poll's values (its info and votes) as last read.
+// The edited poll only replaces the poll if it hasn't changed since it was read, otherwise the edit is applied again
+// on the poll as it is now. Votes then follow their options to their new positions while votes on removed options
+// are dropped. If moving votes fails, the moved votes and the poll are restored so that votes never count for the
+// wrong options
+func (mp *MarcoPoller) applyPollEdit(pollID string, values map[string]string, edit pollEdit) (editedPoll Poll, err error) {
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			values, err = mp.storer.ScanSilo(pollID)
+			if err != nil {
+				return editedPoll, err
+			}
+		}
+
+		poll, err := decodePoll(values[pollInfoKey])
+		if err != nil {
+			return editedPoll, err
+		}
+
+		if poll.Closed {
+			return poll, fmt.Errorf("Poll [%s] closed before the edit was saved", pollID)
+		}
+
+		options, moves, errs := editOptions(poll, edit)
+		if len(errs) > 0 {
+			return poll, fmt.Errorf("Edit no longer applies to poll [%s]: %v", pollID, errs)
+		}
+
+		editedPoll = poll
+		editedPoll.Question = edit.Question
+		editedPoll.Options = options
+
+		encodedPoll, err := encodePoll(editedPoll)
+		if err != nil {
+			return poll, err
+		}
+
+		swapped, err := mp.compareAndSwapPollInfo(pollID, values[pollInfoKey], encodedPoll)
+		if err != nil {
+			return poll, err
+		}
+
+		if swapped {
+			return editedPoll, mp.moveEditedVotes(poll, encodedPoll, values, moves)
+		}
+
+		if attempt >= maxVoteAttempts {
+			return poll, fmt.Errorf("Poll [%s] changed concurrently on all %d attempts", pollID, attempt)
+		}
+	}
+}
+
+// moveEditedVotes moves the votes read before an edit to the options' new positions. If moving votes fails, the
+// moved votes and the poll are restored
+func (mp *MarcoPoller) moveEditedVotes(poll Poll, encodedEditedPoll string, values map[string]string, moves []int) (err error) {
+	moved := make(map[string]string)
+	for userID, userVotes := range values {
+		if userID == pollInfoKey {
+			continue
+		}
+
+		newUserVotes := remapVotes(moves, userVotes, poll.Features.RankedChoice)
+		if newUserVotes == userVotes {
+			continue
+		}
+
+		swapped, err := mp.moveVotes(poll.ID, userID, userVotes, newUserVotes)
+		if err != nil {
+			mp.revertPollEdit(poll.ID, encodedEditedPoll, values, moved)
+			return errors.Wrapf(err, "Error moving votes of user [%s]", userID)
+		}
+
+		// Votes changed since they were read were made on the edited poll so they're kept as they are
+		if swapped {
+			moved[userID] = newUserVotes
+		}
+	}
+
+	return nil
+}
+
+// moveVotes replaces a user's votes with their votes on the edited options. Storers that can compare-and-swap only
+// replace the votes if they're still the votes read before the edit
+func (mp *MarcoPoller) moveVotes(pollID string, userID string, userVotes string, newUserVotes string) (swapped bool, err error) {
+	if cas, ok := mp.storer.(CompareAndSwapper); ok {
+		return cas.CompareAndSwapSiloString(pollID, userID, userVotes, newUserVotes)
+	}
+
+	if newUserVotes == "" {
+		return true, mp.storer.DeleteSiloString(pollID, userID)
+	}
+
+	return true, mp.storer.PutSiloString(pollID, userID, newUserVotes)
+}
+
+// revertPollEdit restores a poll as it was before an edit that failed along with the votes already moved by the edit
+// given the values read before the edit and the moved votes keyed by user ID. The poll is only restored if it's still
+// the edited poll
+func (mp *MarcoPoller) revertPollEdit(pollID string, encodedEditedPoll string, values map[string]string, moved map[string]string) {
+	for userID, newUserVotes := range moved {
+		_, err := mp.moveVotes(pollID, userID, newUserVotes, values[userID])
+		if err != nil {
+			log.Printf("Error restoring votes [%s] of user [%s] on poll [%s]: %v", values[userID], userID, pollID, err)
+		}
+	}
+
+	_, err := mp.compareAndSwapPollInfo(pollID, encodedEditedPoll, values[pollInfoKey])
+	if err != nil {
+		log.Printf("Error restoring poll info [%s] for poll [%s]: %v", values[pollInfoKey], pollID, err)
+	}
+}
+
+// remapVotes maps a user's votes to the new index of the options they were made on. Votes on removed options
+// (with a new index of -1) are dropped. Ranked votes keep their order while other votes are sorted like
+// toggleVoteForValue does
+func remapVotes(moves []int, userVotes string, ranked bool) (newUserVotes string) {
+	remapped := make([]string, 0)
+	for _, vote := range strings.Split(userVotes, voteDelimiter) {
+		i, err := strconv.Atoi(vote)
+		if err != nil || i < 0 || i >= len(moves) || moves[i] < 0 {
+			continue
+		}
+
+		remapped = append(remapped, strconv.Itoa(moves[i]))
+	}
+
+	if !ranked {
+		sort.Strings(remapped)
+	}
+
+	return strings.Join(remapped, voteDelimiter)
+}
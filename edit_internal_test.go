@@ -0,0 +1,90 @@
+package marcopoller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemapVotes(t *testing.T) {
+	testCases := []struct {
+		name           string
+		moves          []int
+		userVotes      string
+		ranked         bool
+		expectedOutput string
+	}{
+		{"Unchanged options", []int{0, 1, 2}, "0,2", false, "0,2"},
+		{"Removed option drops its votes", []int{0, -1, 1}, "0,1", false, "0"},
+		{"Moved options", []int{1, 2, 0}, "0,2", false, "0,1"},
+		{"Removed only vote", []int{0, 1, -1}, "2", false, ""},
+		{"Ranked votes keep their order", []int{2, 1, 0}, "2,0,1", true, "0,2,1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedOutput, remapVotes(tc.moves, tc.userVotes, tc.ranked))
+		})
+	}
+}
+
+func TestEditOptions(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Where to?", Options: []PollOption{{Text: "Paris"}, {Text: "Rmoe"}, {Text: "Oslo"}, {Text: "Lisbon"}}}
+	rome := PollOption{Text: "Rome"}
+	paris := PollOption{Text: "Paris"}
+
+	testCases := []struct {
+		name            string
+		edit            pollEdit
+		expectedOptions []PollOption
+		expectedMoves   []int
+		expectedErrs    map[string]string
+	}{
+		{"Renamed option keeps its position", pollEdit{Options: []*PollOption{&paris, &rome, {Text: "Oslo"}, {Text: "Lisbon"}}}, []PollOption{{Text: "Paris"}, {Text: "Rome"}, {Text: "Oslo"}, {Text: "Lisbon"}}, []int{0, 1, 2, 3}, map[string]string{}},
+		{"Removed option", pollEdit{Options: []*PollOption{&paris, nil, {Text: "Oslo"}, {Text: "Lisbon"}}}, []PollOption{{Text: "Paris"}, {Text: "Oslo"}, {Text: "Lisbon"}}, []int{0, -1, 1, 2}, map[string]string{}},
+		{"Option added after the prompt opened is kept", pollEdit{Options: []*PollOption{&paris, &rome, nil}, NewOptions: []PollOption{{Text: "Berlin"}}}, []PollOption{{Text: "Paris"}, {Text: "Rome"}, {Text: "Lisbon"}, {Text: "Berlin"}}, []int{0, 1, -1, 2}, map[string]string{}},
+		{"Renamed to a duplicate", pollEdit{Options: []*PollOption{&paris, &paris, {Text: "Oslo"}, {Text: "Lisbon"}}}, []PollOption{{Text: "Paris"}, {Text: "Oslo"}, {Text: "Lisbon"}}, []int{0, -1, 1, 2}, map[string]string{"poll_option_1": "[Paris] is already an option"}},
+		{"All options removed", pollEdit{Options: []*PollOption{nil, nil, nil, nil}}, []PollOption{}, []int{-1, -1, -1, -1}, map[string]string{pollOptionsInputBlockID: "Enter at least one option"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			options, moves, errs := editOptions(poll, tc.edit)
+
+			assert.Equal(t, tc.expectedOptions, options)
+			assert.Equal(t, tc.expectedMoves, moves)
+			assert.Equal(t, tc.expectedErrs, errs)
+		})
+	}
+}
+
+func TestRemovedOptionsWithVotes(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Where to?", Options: []PollOption{{Text: "Paris"}, {Text: "Rome"}, {Text: "Oslo"}}}
+	values := map[string]string{pollInfoKey: "{}", "marco": "0,1", "polo": "1"}
+
+	assert.Equal(t, []OptionResult{OptionResult{Option: "Rome", Votes: 2}}, removedOptionsWithVotes(poll, []int{0, -1, -1}, values))
+	assert.Equal(t, []OptionResult{}, removedOptionsWithVotes(poll, []int{0, 1, -1}, values))
+}
+
+func TestEditPollWithTooManyOptions(t *testing.T) {
+	rawOptions := make([]string, 0, maxOptions)
+	for i := 0; i < maxOptions; i++ {
+		rawOptions = append(rawOptions, fmt.Sprintf("Option %d", i))
+	}
+
+	poll := Poll{ID: "un", Question: "Where to?", Options: []PollOption{{Text: "Paris"}}}
+	callback := InteractionCallback{View: slack.View{State: &slack.ViewState{Values: map[string]map[string]slack.BlockAction{
+		pollQuestionInputBlockID: {pollQuestionActionID: slack.BlockAction{Value: "Where to?"}},
+		editOptionBlockID(0):     {editOptionActionID: slack.BlockAction{Value: "Paris"}},
+		pollOptionsInputBlockID:  {pollOptionsActionID: slack.BlockAction{Value: strings.Join(rawOptions, "\n")}},
+	}}}}
+
+	edit, errs := parseEditedPoll(callback, len(poll.Options))
+	assert.Equal(t, map[string]string{}, errs)
+
+	_, _, errs = editOptions(poll, edit)
+	assert.Equal(t, map[string]string{pollOptionsInputBlockID: fmt.Sprintf("This poll already has the maximum number of options (%d)", maxOptions)}, errs)
+}
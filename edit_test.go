@@ -0,0 +1,382 @@
+package marcopoller_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/marcopoller"
+	mmocks "github.com/alexandre-normand/marcopoller/mocks"
+	"github.com/alexandre-normand/slackscot/store/mocks"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const editedPoll = "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\",\"Rome\",\"Oslo\"],\"features\":{\"multianswers\":true},\"creator\":\"marco\"}"
+
+func newEditSubmission(callbackID string, metadata string, values map[string]map[string]slack.BlockAction) (r *http.Request, body string) {
+	callback := marcopoller.InteractionCallback{Type: "view_submission",
+		User: slack.User{ID: "marco"},
+		View: slack.View{CallbackID: callbackID, PrivateMetadata: metadata, State: &slack.ViewState{Values: values}}}
+
+	payload, _ := json.Marshal(callback)
+	body = fmt.Sprintf("payload=%s", payload)
+
+	return httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)), body
+}
+
+func TestEditPollOpensPrefilledPrompt(t *testing.T) {
+	callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "marco"}, TriggerID: "someTriggerID", ResponseURL: "https://hooks.slack.com/actions/bla", ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,edit", Value: "edit"}}}}
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	userFinder := &UserFinder{}
+	defer userFinder.AssertExpectations(t)
+
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(editedPoll, nil)
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	dialoguer.On("OpenView", "someTriggerID", mock.MatchedBy(func(view slack.ModalViewRequest) bool {
+		render, _ := json.Marshal(view)
+		return view.CallbackID == "poll-edit" && view.PrivateMetadata == "{\"pollID\":\"1566576557-poll1\",\"responseURL\":\"https://hooks.slack.com/actions/bla\",\"editedOptions\":3}" && strings.Contains(string(render), "\"initial_value\":\"Where to?\"") && strings.Contains(string(render), "\"block_id\":\"poll_option_1\",\"label\":{\"type\":\"plain_text\",\"text\":\"Option 2\"},\"element\":{\"type\":\"plain_text_input\",\"action_id\":\"poll_option\",\"placeholder\":{\"type\":\"plain_text\",\"text\":\"Leave empty to remove the option and its votes\"},\"initial_value\":\"Rome\"}")
+	})).Return(&slack.ViewResponse{}, nil)
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+}
+
+func TestUnauthorizedEditPoll(t *testing.T) {
+	slackRequest := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := ioutil.ReadAll(r.Body)
+		slackRequest = string(reqBody)
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "polo"}, TriggerID: "someTriggerID", ResponseURL: server.URL, ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,edit", Value: "edit"}}}}
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	userFinder := &UserFinder{}
+	defer userFinder.AssertExpectations(t)
+
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(editedPoll, nil)
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+	assert.Equal(t, "{\"response_type\":\"ephemeral\",\"text\":\":warning: Only the poll creator (\\u003c@marco\\u003e) is allowed to edit the poll\",\"replace_original\":false}", slackRequest)
+}
+
+func TestEditPollRenamingVotedOption(t *testing.T) {
+	slackRequest := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := ioutil.ReadAll(r.Body)
+		slackRequest = string(reqBody)
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	r, body := newEditSubmission("poll-edit", fmt.Sprintf("{\"pollID\":\"1566576557-poll1\",\"responseURL\":\"%s\",\"editedOptions\":3}", server.URL), map[string]map[string]slack.BlockAction{
+		"poll_question":       map[string]slack.BlockAction{"poll_question": slack.BlockAction{Value: "Where should we go?"}},
+		"poll_option_0":       map[string]slack.BlockAction{"poll_option": slack.BlockAction{Value: "Paris"}},
+		"poll_option_1":       map[string]slack.BlockAction{"poll_option": slack.BlockAction{Value: "Roma"}},
+		"poll_option_2":       map[string]slack.BlockAction{"poll_option": slack.BlockAction{Value: "Oslo"}},
+		"poll_answer_options": map[string]slack.BlockAction{"poll_answer_options": slack.BlockAction{Value: "Lisbon\n"}},
+	})
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "polo").Return(&slack.User{ID: "polo", Profile: slack.UserProfile{Image24: "http://image.me", RealName: "Polo"}}, nil)
+	defer userFinder.AssertExpectations(t)
+
+	updatedPoll := "{\"id\":\"1566576557-poll1\",\"question\":\"Where should we go?\",\"options\":[\"Paris\",\"Roma\",\"Oslo\",\"Lisbon\"],\"features\":{\"multianswers\":true},\"creator\":\"marco\"}"
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(editedPoll, nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": editedPoll, "polo": "0,1"}, nil).Once()
+	storer.On("PutSiloString", "1566576557-poll1", "pollInfo", updatedPoll).Return(nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": updatedPoll, "polo": "0,1"}, nil).Once()
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	resp := w.Result()
+	rbody, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "", string(rbody))
+	assert.Contains(t, slackRequest, "*Where should we go?*")
+	assert.Contains(t, slackRequest, "Roma")
+}
+
+func TestEditPollRemovingVotedOptionAsksForConfirmation(t *testing.T) {
+	r, body := newEditSubmission("poll-edit", "{\"pollID\":\"1566576557-poll1\",\"responseURL\":\"https://hooks.slack.com/actions/bla\",\"editedOptions\":3}", map[string]map[string]slack.BlockAction{
+		"poll_question":       map[string]slack.BlockAction{"poll_question": slack.BlockAction{Value: "Where to?"}},
+		"poll_option_0":       map[string]slack.BlockAction{"poll_option": slack.BlockAction{Value: "Paris"}},
+		"poll_option_1":       map[string]slack.BlockAction{"poll_option": slack.BlockAction{Value: ""}},
+		"poll_option_2":       map[string]slack.BlockAction{"poll_option": slack.BlockAction{Value: "Oslo"}},
+		"poll_answer_options": map[string]slack.BlockAction{"poll_answer_options": slack.BlockAction{Value: ""}},
+	})
+
+	userFinder := &UserFinder{}
+	defer userFinder.AssertExpectations(t)
+
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(editedPoll, nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": editedPoll, "polo": "0,1"}, nil)
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	resp := w.Result()
+	rbody, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "{\"response_action\":\"push\",\"view\":{\"type\":\"modal\",\"title\":{\"type\":\"plain_text\",\"text\":\"Marco Poller\"},\"blocks\":[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"The following options are removed along with their votes:\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Rome `1 vote`\"}}],\"close\":{\"type\":\"plain_text\",\"text\":\"Back\"},\"submit\":{\"type\":\"plain_text\",\"text\":\"Remove votes\"},\"private_metadata\":\"{\\\"pollID\\\":\\\"1566576557-poll1\\\",\\\"responseURL\\\":\\\"https://hooks.slack.com/actions/bla\\\",\\\"editedOptions\\\":3,\\\"edit\\\":{\\\"question\\\":\\\"Where to?\\\",\\\"options\\\":[\\\"Paris\\\",null,\\\"Oslo\\\"]}}\",\"callback_id\":\"poll-edit-confirm\"}}\n", string(rbody))
+}
+
+func TestConfirmedEditDropsVotesOnRemovedOptions(t *testing.T) {
+	slackRequest := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := ioutil.ReadAll(r.Body)
+		slackRequest = string(reqBody)
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	r, body := newEditSubmission("poll-edit-confirm", fmt.Sprintf("{\"pollID\":\"1566576557-poll1\",\"responseURL\":\"%s\",\"editedOptions\":3,\"edit\":{\"question\":\"Where to?\",\"options\":[\"Paris\",null,\"Oslo\"]}}", server.URL), nil)
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "polo").Return(&slack.User{ID: "polo", Profile: slack.UserProfile{Image24: "http://image.me", RealName: "Polo"}}, nil)
+	defer userFinder.AssertExpectations(t)
+
+	updatedPoll := "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\",\"Oslo\"],\"features\":{\"multianswers\":true},\"creator\":\"marco\"}"
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(editedPoll, nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": editedPoll, "polo": "0,1", "marco": "1"}, nil).Once()
+	storer.On("PutSiloString", "1566576557-poll1", "polo", "0").Return(nil)
+	storer.On("DeleteSiloString", "1566576557-poll1", "marco").Return(nil)
+	storer.On("PutSiloString", "1566576557-poll1", "pollInfo", updatedPoll).Return(nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": updatedPoll, "polo": "0"}, nil).Once()
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	resp := w.Result()
+	rbody, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "{\"response_action\":\"clear\"}\n", string(rbody))
+	assert.Contains(t, slackRequest, " • Oslo")
+	assert.NotContains(t, slackRequest, " • Rome")
+}
+
+// failingVoteStorer fails to move the votes of one user to simulate an edit failing partway
+type failingVoteStorer struct {
+	*marcopoller.MemoryStorer
+	failingUserID string
+}
+
+func (fs *failingVoteStorer) CompareAndSwapSiloString(silo string, key string, old string, new string) (swapped bool, err error) {
+	if key == fs.failingUserID {
+		return false, fmt.Errorf("boom")
+	}
+
+	return fs.MemoryStorer.CompareAndSwapSiloString(silo, key, old, new)
+}
+
+func TestFailedEditRestoresPollAndVotes(t *testing.T) {
+	slackRequest := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := ioutil.ReadAll(r.Body)
+		slackRequest = string(reqBody)
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	r, body := newEditSubmission("poll-edit-confirm", fmt.Sprintf("{\"pollID\":\"1566576557-poll1\",\"responseURL\":\"%s\",\"editedOptions\":3,\"edit\":{\"question\":\"Where should we go?\",\"options\":[null,\"Rome\",\"Oslo\"]}}", server.URL), nil)
+
+	storer := &failingVoteStorer{MemoryStorer: marcopoller.NewMemoryStorer(), failingUserID: "marco"}
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", editedPoll))
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "polo", "0,1"))
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "luigi", "2"))
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "marco", "1"))
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+	assert.Equal(t, "{\"response_type\":\"ephemeral\",\"text\":\":warning: Error persisting poll changes. Please try again.\",\"replace_original\":false}", slackRequest)
+
+	values, err := storer.ScanSilo("1566576557-poll1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pollInfo": editedPoll, "polo": "0,1", "luigi": "2", "marco": "1"}, values)
+}
+
+func TestEditPollWithDuplicateOptions(t *testing.T) {
+	r, body := newEditSubmission("poll-edit", "{\"pollID\":\"1566576557-poll1\",\"responseURL\":\"https://hooks.slack.com/actions/bla\",\"editedOptions\":3}", map[string]map[string]slack.BlockAction{
+		"poll_question":       map[string]slack.BlockAction{"poll_question": slack.BlockAction{Value: "Where to?"}},
+		"poll_option_0":       map[string]slack.BlockAction{"poll_option": slack.BlockAction{Value: "Paris"}},
+		"poll_option_1":       map[string]slack.BlockAction{"poll_option": slack.BlockAction{Value: "Rome"}},
+		"poll_option_2":       map[string]slack.BlockAction{"poll_option": slack.BlockAction{Value: "Oslo"}},
+		"poll_answer_options": map[string]slack.BlockAction{"poll_answer_options": slack.BlockAction{Value: "paris"}},
+	})
+
+	storer := marcopoller.NewMemoryStorer()
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", editedPoll))
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	rbody, _ := ioutil.ReadAll(w.Result().Body)
+	assert.Equal(t, "{\"response_action\":\"errors\",\"errors\":{\"poll_answer_options\":\"[Paris] is already an option\"}}\n", string(rbody))
+
+	encodedPoll, err := storer.GetSiloString("1566576557-poll1", "pollInfo")
+	require.NoError(t, err)
+	assert.Equal(t, editedPoll, encodedPoll)
+}
+
+// racingPollStorer changes a poll's info right before the first compare-and-swap of that info to simulate a
+// concurrent change to the poll
+type racingPollStorer struct {
+	*marcopoller.MemoryStorer
+	concurrentPoll string
+	raced          bool
+}
+
+func (rs *racingPollStorer) CompareAndSwapSiloString(silo string, key string, old string, new string) (swapped bool, err error) {
+	if key == "pollInfo" && !rs.raced {
+		rs.raced = true
+		err = rs.MemoryStorer.PutSiloString(silo, key, rs.concurrentPoll)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	return rs.MemoryStorer.CompareAndSwapSiloString(silo, key, old, new)
+}
+
+func TestEditPollAppliedAgainOnConcurrentChange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	r, body := newEditSubmission("poll-edit-confirm", fmt.Sprintf("{\"pollID\":\"1566576557-poll1\",\"responseURL\":\"%s\",\"editedOptions\":3,\"edit\":{\"question\":\"Where to?\",\"options\":[null,\"Roma\",\"Oslo\"]}}", server.URL), nil)
+
+	pollWithAddedOption := "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\",\"Rome\",\"Oslo\",\"Lisbon\"],\"features\":{\"multianswers\":true},\"creator\":\"marco\"}"
+	storer := &racingPollStorer{MemoryStorer: marcopoller.NewMemoryStorer(), concurrentPoll: pollWithAddedOption}
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", editedPoll))
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "polo", "0,1"))
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "polo").Return(&slack.User{ID: "polo", Profile: slack.UserProfile{Image24: "http://image.me", RealName: "Polo"}}, nil)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	values, err := storer.ScanSilo("1566576557-poll1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pollInfo": "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Roma\",\"Oslo\",\"Lisbon\"],\"features\":{\"multianswers\":true},\"creator\":\"marco\"}", "polo": "0"}, values)
+}
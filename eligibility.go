@@ -0,0 +1,195 @@
+package marcopoller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+)
+
+// Eligibility flags and identifiers
+const (
+	votersFlag         = "--voters"
+	channelMembersFlag = "--channel-members"
+	votersDelimiter    = ","
+
+	channelMembersOptionID     = "channelmembers"
+	channelMembersFeatureValue = "Only members of the channel can vote"
+
+	// conversationMembersPageSize is the number of channel members fetched per call when checking channel membership
+	conversationMembersPageSize = 1000
+)
+
+// Eligibility restricts who can vote on a poll. When both voters and channel membership are set, voters
+// must also be members of the channel
+type Eligibility struct {
+	// Voters are the user IDs and user group IDs of the users allowed to vote
+	Voters []string `json:"voters,omitempty"`
+
+	// ChannelMembers restricts voting to members of the channel where the poll is posted
+	ChannelMembers bool `json:"channelMembers,omitempty"`
+}
+
+// MemberFinder is implemented by any value that has the GetUserGroupMembers and GetUsersInConversation methods like
+// a slack-go/slack.Client. It complements the UserFinder with the membership lookups needed to check who can vote
+type MemberFinder interface {
+	UserGroupFinder
+
+	// GetUsersInConversation returns a page of the members of a conversation. See https://pkg.go.dev/github.com/slack-go/slack?tab=doc#Client.GetUsersInConversation
+	GetUsersInConversation(params *slack.GetUsersInConversationParameters) (members []string, nextCursor string, err error)
+}
+
+// OptionSlackMemberFinder sets a slack-go/slack.Client as the implementation of MemberFinder
+func OptionSlackMemberFinder(token string, debug bool) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.memberFinder = slack.New(token, slack.OptionDebug(debug))
+		return nil
+	}
+}
+
+// OptionMemberFinder sets a memberFinder as the implementation on MarcoPoller
+func OptionMemberFinder(memberFinder MemberFinder) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.memberFinder = memberFinder
+		return nil
+	}
+}
+
+// parseVoters parses the value of a voters flag: a comma-delimited list of users and user groups
+func parseVoters(value string) (voters []string, err error) {
+	voters = make([]string, 0)
+	for _, rawVoter := range strings.Split(value, votersDelimiter) {
+		id, err := parseMentionID(rawVoter)
+		if err != nil {
+			return nil, err
+		}
+
+		voters = append(voters, id)
+	}
+
+	return voters, nil
+}
+
+// checkEligibility returns true if a user can vote on a poll. The channel ID is the channel of the interaction
+// and is only used if the poll's message channel isn't known
+func (mp *MarcoPoller) checkEligibility(poll Poll, userID string, channelID string) (eligible bool, err error) {
+	eligibility := poll.Features.Eligibility
+	if eligibility == nil {
+		return true, nil
+	}
+
+	if (len(eligibility.Voters) > 0 || eligibility.ChannelMembers) && mp.memberFinder == nil {
+		return false, fmt.Errorf("A MemberFinder is needed to check who can vote on poll [%s]", poll.ID)
+	}
+
+	if len(eligibility.Voters) > 0 {
+		listed, err := mp.isListedVoter(eligibility.Voters, userID)
+		if err != nil || !listed {
+			return false, err
+		}
+	}
+
+	if eligibility.ChannelMembers {
+		if poll.MsgID != nil {
+			channelID = poll.MsgID.ChannelID
+		}
+
+		return mp.isChannelMember(channelID, userID)
+	}
+
+	return true, nil
+}
+
+// isListedVoter returns true if the user is one of the voters or a member of one of the voters' user groups
+func (mp *MarcoPoller) isListedVoter(voters []string, userID string) (listed bool, err error) {
+	for _, id := range voters {
+		if id == userID {
+			return true, nil
+		}
+	}
+
+	for _, id := range voters {
+		if !isUserGroupID(id) {
+			continue
+		}
+
+		members, err := mp.memberFinder.GetUserGroupMembers(id)
+		if err != nil {
+			return false, errors.Wrapf(err, "Error getting members of user group [%s]", id)
+		}
+
+		for _, member := range members {
+			if member == userID {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// isChannelMember returns true if the user is a member of the channel. Pages of members are only requested until
+// the user is found
+func (mp *MarcoPoller) isChannelMember(channelID string, userID string) (member bool, err error) {
+	params := slack.GetUsersInConversationParameters{ChannelID: channelID, Limit: conversationMembersPageSize}
+	for {
+		members, nextCursor, err := mp.memberFinder.GetUsersInConversation(&params)
+		if err != nil {
+			return false, errors.Wrapf(err, "Error getting members of channel [%s]", channelID)
+		}
+
+		for _, m := range members {
+			if m == userID {
+				return true, nil
+			}
+		}
+
+		if nextCursor == "" {
+			return false, nil
+		}
+
+		params.Cursor = nextCursor
+	}
+}
+
+// listChannelMembers returns the user IDs of all members of a channel. It's meant for reminders which need every
+// member while isChannelMember stops at the page with the user
+func (mp *MarcoPoller) listChannelMembers(channelID string) (members []string, err error) {
+	members = make([]string, 0)
+	params := slack.GetUsersInConversationParameters{ChannelID: channelID, Limit: conversationMembersPageSize}
+	for {
+		page, nextCursor, err := mp.memberFinder.GetUsersInConversation(&params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error getting members of channel [%s]", channelID)
+		}
+
+		members = append(members, page...)
+		if nextCursor == "" {
+			return members, nil
+		}
+
+		params.Cursor = nextCursor
+	}
+}
+
+// formatEligibility formats who can vote on a poll for display
+func formatEligibility(eligibility Eligibility) (formatted string) {
+	voters := make([]string, 0, len(eligibility.Voters))
+	for _, id := range eligibility.Voters {
+		if isUserGroupID(id) {
+			voters = append(voters, fmt.Sprintf("members of <!subteam^%s>", id))
+		} else {
+			voters = append(voters, fmt.Sprintf("<@%s>", id))
+		}
+	}
+
+	switch {
+	case len(voters) > 0 && eligibility.ChannelMembers:
+		return fmt.Sprintf("Who can vote: %s (if they're members of this channel)", strings.Join(voters, ", "))
+	case len(voters) > 0:
+		return fmt.Sprintf("Who can vote: %s", strings.Join(voters, ", "))
+	default:
+		return "Who can vote: members of this channel"
+	}
+}
@@ -0,0 +1,104 @@
+package marcopoller
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pagedMemberFinder is a MemberFinder returning channel members one page at a time and counting the pages requested
+type pagedMemberFinder struct {
+	groupUserFinder
+	pages     [][]string
+	requested int
+}
+
+func (pmf *pagedMemberFinder) GetUsersInConversation(params *slack.GetUsersInConversationParameters) (members []string, nextCursor string, err error) {
+	page := 0
+	if params.Cursor != "" {
+		page, _ = strconv.Atoi(params.Cursor)
+	}
+
+	pmf.requested++
+	if page+1 < len(pmf.pages) {
+		nextCursor = strconv.Itoa(page + 1)
+	}
+
+	return pmf.pages[page], nextCursor, nil
+}
+
+func TestParseVoters(t *testing.T) {
+	voters, err := parseVoters("<@U123|marco>,W456,<!subteam^S789|@leads>")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"U123", "W456", "S789"}, voters)
+
+	_, err = parseVoters("U123,@polo")
+	assert.EqualError(t, err, "[@polo] isn't a user or user group")
+}
+
+func TestFormatEligibility(t *testing.T) {
+	tests := map[string]struct {
+		eligibility Eligibility
+		expected    string
+	}{
+		"voters":          {eligibility: Eligibility{Voters: []string{"U1", "S1"}}, expected: "Who can vote: <@U1>, members of <!subteam^S1>"},
+		"channel members": {eligibility: Eligibility{ChannelMembers: true}, expected: "Who can vote: members of this channel"},
+		"both":            {eligibility: Eligibility{Voters: []string{"S1"}, ChannelMembers: true}, expected: "Who can vote: members of <!subteam^S1> (if they're members of this channel)"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, formatEligibility(tc.eligibility))
+		})
+	}
+}
+
+func TestCheckEligibilityWithoutMemberFinder(t *testing.T) {
+	mp := &MarcoPoller{}
+
+	eligible, err := mp.checkEligibility(Poll{ID: "un"}, "U1", "C1")
+	require.NoError(t, err)
+	assert.True(t, eligible)
+
+	_, err = mp.checkEligibility(Poll{ID: "un", Features: PollFeatures{Eligibility: &Eligibility{ChannelMembers: true}}}, "U1", "C1")
+	assert.EqualError(t, err, "A MemberFinder is needed to check who can vote on poll [un]")
+}
+
+func TestRenderPollWithEligibility(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos"}}, Creator: "marco", Features: PollFeatures{Eligibility: &Eligibility{ChannelMembers: true}}}
+
+	render, err := json.Marshal(renderPoll(poll, map[string][]Voter{}, false, defaultMaxVoterAvatars))
+	require.NoError(t, err)
+	assert.Contains(t, string(render), "{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Who can vote: members of this channel\"}]}")
+
+	render, err = json.Marshal(renderPoll(poll, map[string][]Voter{}, true, defaultMaxVoterAvatars))
+	require.NoError(t, err)
+	assert.NotContains(t, string(render), "Who can vote")
+}
+
+func TestIsChannelMemberStopsAtUsersPage(t *testing.T) {
+	memberFinder := &pagedMemberFinder{pages: [][]string{{"polo"}, {"marco"}, {"luigi"}}}
+	mp := &MarcoPoller{memberFinder: memberFinder}
+
+	member, err := mp.isChannelMember("CID", "marco")
+	require.NoError(t, err)
+	assert.True(t, member)
+	assert.Equal(t, 2, memberFinder.requested)
+
+	member, err = mp.isChannelMember("CID", "mario")
+	require.NoError(t, err)
+	assert.False(t, member)
+	assert.Equal(t, 5, memberFinder.requested)
+}
+
+func TestListChannelMembers(t *testing.T) {
+	mp := &MarcoPoller{memberFinder: &pagedMemberFinder{pages: [][]string{{"polo"}, {"marco"}, {"luigi"}}}}
+
+	members, err := mp.listChannelMembers("CID")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"polo", "marco", "luigi"}, members)
+}
@@ -0,0 +1,131 @@
+package marcopoller_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/marcopoller"
+	mmocks "github.com/alexandre-normand/marcopoller/mocks"
+	"github.com/alexandre-normand/slackscot/store/mocks"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const eligibilityPollInfo = "{\"id\":\"1566576557-poll1\",\"msgID\":{\"channelID\":\"myLittleChannel\",\"timestamp\":\"1566576557.354007\"},\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false,\"eligibility\":%s},\"creator\":\"UID\"}"
+
+// voteWithEligibility registers a vote from marco on a poll with the given eligibility and returns the requests sent to slack
+func voteWithEligibility(t *testing.T, storer *mocks.Storer, memberFinder *mmocks.MemberFinder) (slackRequests []string) {
+	server := newSlackServer()
+	defer server.Close()
+
+	callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "marco"}, ResponseURL: server.URL, ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,vote", Value: "1"}}}}
+	callback.Channel.ID = "myLittleChannel"
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionMemberFinder(memberFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	resp := w.Result()
+	assert.Equal(t, 200, resp.StatusCode)
+
+	return server.Requests()
+}
+
+func TestVoteFromChannelMember(t *testing.T) {
+	pollInfo := fmt.Sprintf(eligibilityPollInfo, "{\"channelMembers\":true}")
+
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(pollInfo, nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": pollInfo}, nil)
+	storer.On("PutSiloString", "1566576557-poll1", "marco", "1").Return(nil)
+	defer storer.AssertExpectations(t)
+
+	memberFinder := &mmocks.MemberFinder{}
+	memberFinder.On("GetUsersInConversation", &slack.GetUsersInConversationParameters{ChannelID: "myLittleChannel", Limit: 1000}).Return([]string{"polo"}, "next", nil).Once()
+	memberFinder.On("GetUsersInConversation", &slack.GetUsersInConversationParameters{ChannelID: "myLittleChannel", Cursor: "next", Limit: 1000}).Return([]string{"marco"}, "", nil).Once()
+	defer memberFinder.AssertExpectations(t)
+
+	slackRequests := voteWithEligibility(t, storer, memberFinder)
+
+	require.Len(t, slackRequests, 2)
+	assert.Regexp(t, regexp.MustCompile("\\{\"blocks\".*,\"replace_original\":true}"), slackRequests[0])
+}
+
+func TestVoteFromUserOutsideOfChannel(t *testing.T) {
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(fmt.Sprintf(eligibilityPollInfo, "{\"channelMembers\":true}"), nil)
+	defer storer.AssertExpectations(t)
+
+	memberFinder := &mmocks.MemberFinder{}
+	memberFinder.On("GetUsersInConversation", &slack.GetUsersInConversationParameters{ChannelID: "myLittleChannel", Limit: 1000}).Return([]string{"polo"}, "", nil)
+	defer memberFinder.AssertExpectations(t)
+
+	slackRequests := voteWithEligibility(t, storer, memberFinder)
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\":warning: Sorry, you can't vote on this poll. Who can vote: members of this channel.\",\"replace_original\":false}"}, slackRequests)
+}
+
+func TestVoteFromUserGroupMember(t *testing.T) {
+	pollInfo := fmt.Sprintf(eligibilityPollInfo, "{\"voters\":[\"U1\",\"S1\"]}")
+
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(pollInfo, nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": pollInfo}, nil)
+	storer.On("PutSiloString", "1566576557-poll1", "marco", "1").Return(nil)
+	defer storer.AssertExpectations(t)
+
+	memberFinder := &mmocks.MemberFinder{}
+	memberFinder.On("GetUserGroupMembers", "S1").Return([]string{"polo", "marco"}, nil)
+	defer memberFinder.AssertExpectations(t)
+
+	slackRequests := voteWithEligibility(t, storer, memberFinder)
+
+	require.Len(t, slackRequests, 2)
+	assert.Regexp(t, regexp.MustCompile("\\{\"blocks\".*,\"replace_original\":true}"), slackRequests[0])
+}
+
+func TestVoteFromUserNotInVoters(t *testing.T) {
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(fmt.Sprintf(eligibilityPollInfo, "{\"voters\":[\"U1\",\"S1\"]}"), nil)
+	defer storer.AssertExpectations(t)
+
+	memberFinder := &mmocks.MemberFinder{}
+	memberFinder.On("GetUserGroupMembers", "S1").Return([]string{"polo"}, nil)
+	defer memberFinder.AssertExpectations(t)
+
+	slackRequests := voteWithEligibility(t, storer, memberFinder)
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\":warning: Sorry, you can't vote on this poll. Who can vote: \\u003c@U1\\u003e, members of \\u003c!subteam^S1\\u003e.\",\"replace_original\":false}"}, slackRequests)
+}
+
+func TestErrorCheckingEligibility(t *testing.T) {
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(fmt.Sprintf(eligibilityPollInfo, "{\"voters\":[\"S1\"]}"), nil)
+	defer storer.AssertExpectations(t)
+
+	memberFinder := &mmocks.MemberFinder{}
+	memberFinder.On("GetUserGroupMembers", "S1").Return(nil, fmt.Errorf("usergroup_not_found"))
+	defer memberFinder.AssertExpectations(t)
+
+	slackRequests := voteWithEligibility(t, storer, memberFinder)
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\":warning: Error checking who can vote on this poll. Please try again.\",\"replace_original\":false}"}, slackRequests)
+}
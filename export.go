@@ -0,0 +1,238 @@
+package marcopoller
+
+import (
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Export request parameters and formats
+const (
+	exportPollIDParam = "id"
+	exportFormatParam = "format"
+
+	csvFormat  = "csv"
+	jsonFormat = "json"
+
+	csvContentType  = "text/csv"
+	jsonContentType = "application/json"
+
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+)
+
+// PollResults represents the exported results of a poll
+type PollResults struct {
+	ID       string         `json:"id"`
+	Question string         `json:"question"`
+	Creator  string         `json:"creator"`
+	Closed   bool           `json:"closed"`
+	Options  []OptionResult `json:"options"`
+	Voters   []VoterChoices `json:"voters,omitempty"`
+}
+
+// OptionResult represents the vote count of a poll option
+type OptionResult struct {
+	Option string `json:"option"`
+	Votes  int    `json:"votes"`
+}
+
+// VoterChoices represents the options a user voted for. For ranked choice polls, choices
+// are in the voter's order of preference
+type VoterChoices struct {
+	UserID  string   `json:"userID"`
+	Choices []string `json:"choices"`
+}
+
+// TokenVerifier verifies requests with a bearer token in the Authorization header
+type TokenVerifier struct {
+	Token string
+}
+
+// Verify returns an error if the request doesn't have an Authorization header with the bearer token
+func (tv *TokenVerifier) Verify(header http.Header, body []byte) (err error) {
+	authorization := header.Get(authorizationHeader)
+	if !strings.HasPrefix(authorization, bearerPrefix) {
+		return fmt.Errorf("Missing bearer token in %s header", authorizationHeader)
+	}
+
+	token := strings.TrimPrefix(authorization, bearerPrefix)
+	if tv.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(tv.Token)) != 1 {
+		return fmt.Errorf("Invalid bearer token")
+	}
+
+	return nil
+}
+
+// ExportPoll handles a request to export the results of a poll identified by the id query parameter (and the team query
+// parameter when many teams are supported). Results are exported as csv or json depending on the format query parameter
+// or, if absent, the Accept header (json being the default). Voter choices are left out for anonymous polls. Polls are
+// only available for export while they're stored so polls that should be exported after voting closes must be created
+// with the keep results feature
+func (mp *MarcoPoller) ExportPoll(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Printf("Error reading request body: %v", err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	err = mp.exportVerifier.Verify(r.Header, body)
+	if err != nil {
+		log.Printf("Error validating request: %v", err)
+		http.Error(w, err.Error(), 403)
+		return
+	}
+
+	pollID := r.URL.Query().Get(exportPollIDParam)
+	if pollID == "" {
+		http.Error(w, fmt.Sprintf("Missing [%s] parameter", exportPollIDParam), 400)
+		return
+	}
+
+	format, err := exportFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+
+	// Polls are stored per team when many teams are supported
+	if mp.tokenStore != nil {
+		teamID := r.URL.Query().Get(exportTeamParam)
+		if teamID == "" {
+			http.Error(w, fmt.Sprintf("Missing [%s] parameter", exportTeamParam), 400)
+			return
+		}
+
+		mp = mp.forTeamStorage(teamID)
+	}
+
+	encodedPoll, err := mp.storer.GetSiloString(pollID, pollInfoKey)
+	if isNotFound(err) {
+		http.Error(w, fmt.Sprintf("Poll [%s] not found", pollID), 404)
+		return
+	} else if err != nil {
+		log.Printf("Error getting existing poll info for id [%s]: %v", pollID, err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	poll, err := decodePoll(encodedPoll)
+	if err != nil {
+		log.Printf("Error parsing existing poll [%s] for id [%s]: %v", encodedPoll, pollID, err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	values, err := mp.storer.ScanSilo(pollID)
+	if err != nil {
+		log.Printf("Error listing votes for poll [%s]: %v", pollID, err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	results := newPollResults(poll, values)
+
+	if format == csvFormat {
+		w.Header().Set("Content-Type", csvContentType)
+		err = writeResultsCSV(w, results)
+	} else {
+		w.Header().Set("Content-Type", jsonContentType)
+		err = json.NewEncoder(w).Encode(results)
+	}
+
+	if err != nil {
+		log.Printf("Error writing results for poll [%s]: %v", pollID, err)
+	}
+}
+
+// exportFormat returns the export format requested by the format query parameter or by the Accept header
+// if the parameter is absent. An error is returned if the format parameter is unsupported
+func exportFormat(r *http.Request) (format string, err error) {
+	format = strings.ToLower(r.URL.Query().Get(exportFormatParam))
+
+	switch format {
+	case csvFormat, jsonFormat:
+		return format, nil
+	case "":
+		if strings.Contains(r.Header.Get("Accept"), csvContentType) {
+			return csvFormat, nil
+		}
+
+		return jsonFormat, nil
+	default:
+		return "", fmt.Errorf("Unsupported format [%s], expected [%s] or [%s]", format, csvFormat, jsonFormat)
+	}
+}
+
+// newPollResults returns the results of a poll given all of its stored values (poll info and votes)
+func newPollResults(poll Poll, values map[string]string) (results PollResults) {
+	results = PollResults{ID: poll.ID, Question: poll.Question, Creator: poll.Creator, Closed: poll.Closed}
+
+	userIDs := make([]string, 0, len(values))
+	for k := range values {
+		if k != pollInfoKey {
+			userIDs = append(userIDs, k)
+		}
+	}
+	sort.Strings(userIDs)
+
+	counts := make([]int, len(poll.Options))
+	voters := make([]VoterChoices, 0, len(userIDs))
+	for _, userID := range userIDs {
+		choices := make([]string, 0)
+		for _, value := range strings.Split(values[userID], voteDelimiter) {
+			i, err := strconv.Atoi(value)
+			if err != nil || i < 0 || i >= len(poll.Options) {
+				continue
+			}
+
+			counts[i]++
+			choices = append(choices, poll.Options[i].Text)
+		}
+
+		if len(choices) > 0 {
+			voters = append(voters, VoterChoices{UserID: userID, Choices: choices})
+		}
+	}
+
+	results.Options = make([]OptionResult, 0, len(poll.Options))
+	for i, opt := range poll.Options {
+		results.Options = append(results.Options, OptionResult{Option: opt.Text, Votes: counts[i]})
+	}
+
+	// Anonymous polls never reveal voters, only how many voted for each option
+	if !poll.Features.Anonymous {
+		results.Voters = voters
+	}
+
+	return results
+}
+
+// writeResultsCSV writes poll results as csv: the question, followed by the option vote counts and
+// the voter choices, each section separated by an empty line
+func writeResultsCSV(w io.Writer, results PollResults) (err error) {
+	cw := csv.NewWriter(w)
+
+	records := [][]string{{"Question", results.Question}, {}, {"Option", "Votes"}}
+	for _, opt := range results.Options {
+		records = append(records, []string{opt.Option, strconv.Itoa(opt.Votes)})
+	}
+
+	if len(results.Voters) > 0 {
+		records = append(records, []string{}, []string{"Voter", "Choices"})
+		for _, voter := range results.Voters {
+			records = append(records, append([]string{voter.UserID}, voter.Choices...))
+		}
+	}
+
+	return cw.WriteAll(records)
+}
@@ -0,0 +1,213 @@
+package marcopoller_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cloud.google.com/go/datastore"
+	"github.com/alexandre-normand/marcopoller"
+	mmocks "github.com/alexandre-normand/marcopoller/mocks"
+	"github.com/alexandre-normand/slackscot/store/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const exportedPoll = "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\",\"Rome, Italy\",\"Oslo\"],\"features\":{\"multianswers\":true,\"keepResults\":true},\"creator\":\"marco\",\"closed\":true}"
+
+func newExportPoller(t *testing.T, storer *mocks.Storer, opts ...marcopoller.Option) (mp *marcopoller.MarcoPoller) {
+	userFinder := &UserFinder{}
+	defer userFinder.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	opts = append([]marcopoller.Option{marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{})}, opts...)
+	mp, err := marcopoller.NewWithOptions(opts...)
+	require.NoError(t, err)
+
+	return mp
+}
+
+func TestExportPollAsJSON(t *testing.T) {
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(exportedPoll, nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": exportedPoll, "marco": "0,1", "polo": "1", "undecided": ""}, nil)
+	defer storer.AssertExpectations(t)
+
+	mp := newExportPoller(t, storer, marcopoller.OptionExportToken("secret"))
+
+	r := httptest.NewRequest(http.MethodGet, "/export?id=1566576557-poll1", nil)
+	r.Header.Add("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+
+	mp.ExportPoll(w, r)
+
+	resp := w.Result()
+	rbody, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"creator\":\"marco\",\"closed\":true,\"options\":[{\"option\":\"Paris\",\"votes\":1},{\"option\":\"Rome, Italy\",\"votes\":2},{\"option\":\"Oslo\",\"votes\":0}],\"voters\":[{\"userID\":\"marco\",\"choices\":[\"Paris\",\"Rome, Italy\"]},{\"userID\":\"polo\",\"choices\":[\"Rome, Italy\"]}]}\n", string(rbody))
+}
+
+func TestExportPollAsCSV(t *testing.T) {
+	testCases := []struct {
+		name   string
+		url    string
+		accept string
+	}{
+		{"Format parameter", "/export?id=1566576557-poll1&format=csv", "application/json"},
+		{"Accept header", "/export?id=1566576557-poll1", "text/csv"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			storer := &mocks.Storer{}
+			storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(exportedPoll, nil)
+			storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": exportedPoll, "marco": "0,1", "polo": "1"}, nil)
+			defer storer.AssertExpectations(t)
+
+			mp := newExportPoller(t, storer, marcopoller.OptionExportToken("secret"))
+
+			r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			r.Header.Add("Authorization", "Bearer secret")
+			r.Header.Add("Accept", tc.accept)
+			w := httptest.NewRecorder()
+
+			mp.ExportPoll(w, r)
+
+			resp := w.Result()
+			rbody, _ := ioutil.ReadAll(resp.Body)
+
+			assert.Equal(t, 200, resp.StatusCode)
+			assert.Equal(t, "text/csv", resp.Header.Get("Content-Type"))
+			assert.Equal(t, "Question,Where to?\n\nOption,Votes\nParis,1\n\"Rome, Italy\",2\nOslo,0\n\nVoter,Choices\nmarco,Paris,\"Rome, Italy\"\npolo,\"Rome, Italy\"\n", string(rbody))
+		})
+	}
+}
+
+func TestExportAnonymousPoll(t *testing.T) {
+	anonymousPoll := "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\",\"Rome\"],\"features\":{\"multianswers\":false,\"anonymous\":true},\"creator\":\"marco\"}"
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(anonymousPoll, nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": anonymousPoll, "marco": "0", "polo": "1"}, nil)
+	defer storer.AssertExpectations(t)
+
+	mp := newExportPoller(t, storer, marcopoller.OptionExportToken("secret"))
+
+	r := httptest.NewRequest(http.MethodGet, "/export?id=1566576557-poll1&format=csv", nil)
+	r.Header.Add("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+
+	mp.ExportPoll(w, r)
+
+	resp := w.Result()
+	rbody, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "Question,Where to?\n\nOption,Votes\nParis,1\nRome,1\n", string(rbody))
+}
+
+func TestExportPollWithInvalidToken(t *testing.T) {
+	testCases := []struct {
+		name          string
+		authorization string
+	}{
+		{"Missing header", ""},
+		{"Wrong token", "Bearer notsecret"},
+		{"Not a bearer token", "secret"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			storer := &mocks.Storer{}
+			defer storer.AssertExpectations(t)
+
+			mp := newExportPoller(t, storer, marcopoller.OptionExportToken("secret"))
+
+			r := httptest.NewRequest(http.MethodGet, "/export?id=1566576557-poll1", nil)
+			if tc.authorization != "" {
+				r.Header.Add("Authorization", tc.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			mp.ExportPoll(w, r)
+
+			assert.Equal(t, 403, w.Result().StatusCode)
+		})
+	}
+}
+
+func TestExportPollVerifiedWithVerifierByDefault(t *testing.T) {
+	storer := &mocks.Storer{}
+	defer storer.AssertExpectations(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/export?id=1566576557-poll1", nil)
+
+	userFinder := &UserFinder{}
+	defer userFinder.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte{}).Return(assert.AnError)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.ExportPoll(w, r)
+
+	assert.Equal(t, 403, w.Result().StatusCode)
+}
+
+func TestExportPollBadRequests(t *testing.T) {
+	testCases := []struct {
+		name string
+		url  string
+	}{
+		{"Missing poll id", "/export"},
+		{"Unsupported format", "/export?id=1566576557-poll1&format=xml"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			storer := &mocks.Storer{}
+			defer storer.AssertExpectations(t)
+
+			mp := newExportPoller(t, storer, marcopoller.OptionExportToken("secret"))
+
+			r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			r.Header.Add("Authorization", "Bearer secret")
+			w := httptest.NewRecorder()
+
+			mp.ExportPoll(w, r)
+
+			assert.Equal(t, 400, w.Result().StatusCode)
+		})
+	}
+}
+
+func TestExportUnknownPoll(t *testing.T) {
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return("", datastore.ErrNoSuchEntity)
+	defer storer.AssertExpectations(t)
+
+	mp := newExportPoller(t, storer, marcopoller.OptionExportToken("secret"))
+
+	r := httptest.NewRequest(http.MethodGet, "/export?id=1566576557-poll1", nil)
+	r.Header.Add("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+
+	mp.ExportPoll(w, r)
+
+	assert.Equal(t, 404, w.Result().StatusCode)
+}
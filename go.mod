@@ -10,11 +10,13 @@ require (
 	github.com/imroc/req v0.2.4
 	github.com/kr/text v0.2.0 // indirect
 	github.com/lithammer/shortuuid v3.0.0+incompatible
+	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
 	github.com/pkg/errors v0.8.1
 	github.com/slack-go/slack v0.7.2
 	github.com/spf13/cast v1.3.0
 	github.com/stretchr/testify v1.7.0
+	github.com/syndtr/goleveldb v0.0.0-20190203031304-2f17a3356c66
 	go.opentelemetry.io/otel v0.17.0
 	go.opentelemetry.io/otel/metric v0.17.0
 	golang.org/x/tools v0.0.0-20200410194907-79a7a3126eef // indirect
@@ -0,0 +1,58 @@
+package marcopoller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// handleMyVoteRequest handles a request from a user to see their own votes on a poll. Votes are shown in a message only
+// visible to the user so that they can check their choice on polls with hidden results
+func (mp *MarcoPoller) handleMyVoteRequest(poll Poll, callback InteractionCallback, w http.ResponseWriter) {
+	userVotes, err := mp.storer.GetSiloString(poll.ID, callback.User.ID)
+	if err != nil && !isNotFound(err) {
+		log.Printf("Error getting existing votes for user [%s] on poll id [%s]: %v", callback.User.ID, poll.ID, err)
+		showErrorToUser(callback.ResponseURL, ":warning: Error loading your votes. Please try again.")
+		return
+	}
+
+	showMessageToUser(callback.ResponseURL, formatMyVote(poll, userVotes))
+}
+
+// formatMyVote formats a user's votes on a poll for display. Votes on ranked choice polls are listed in order of preference
+func formatMyVote(poll Poll, userVotes string) (formatted string) {
+	choices := make([]string, 0)
+	for _, vote := range strings.Split(userVotes, voteDelimiter) {
+		i, err := strconv.Atoi(vote)
+		if err != nil || i < 0 || i >= len(poll.Options) {
+			continue
+		}
+
+		if poll.Features.RankedChoice {
+			choices = append(choices, fmt.Sprintf("%d. %s", len(choices)+1, poll.Options[i]))
+		} else {
+			choices = append(choices, poll.Options[i].String())
+		}
+	}
+
+	if len(choices) == 0 {
+		return fmt.Sprintf("You haven't voted on *%s* yet", poll.Question)
+	}
+
+	if poll.Features.RankedChoice {
+		return fmt.Sprintf("Your ranking on *%s*:\n%s", poll.Question, strings.Join(choices, "\n"))
+	}
+
+	return fmt.Sprintf("Your vote on *%s*: %s", poll.Question, strings.Join(choices, ", "))
+}
+
+// formatParticipantCount formats a number of participants for display
+func formatParticipantCount(count int) (formatted string) {
+	if count == 1 {
+		return "`1 participant`"
+	}
+
+	return fmt.Sprintf("`%d participants`", count)
+}
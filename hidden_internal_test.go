@@ -0,0 +1,43 @@
+package marcopoller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderOpenPollWithHiddenResults(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos"}}, Creator: "marco", Features: PollFeatures{MultiAnswers: true, HiddenResults: true, RenderStyle: BarsRenderStyle}}
+	blocks := renderPoll(poll, map[string][]Voter{"0": []Voter{Voter{userID: "U1", avatarURL: "https://avatar1.me", name: "User1"}}, "1": []Voter{Voter{userID: "U1", avatarURL: "https://avatar1.me", name: "User1"}, Voter{userID: "U2", avatarURL: "https://avatar2.me", name: "User2"}}}, false, defaultMaxVoterAvatars)
+	render, err := json.Marshal(blocks)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*Lunch?*\"}},{\"type\":\"divider\"},"+
+		"{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Pizza\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"0\",\"style\":\"primary\"}},"+
+		"{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Tacos\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"1\",\"style\":\"primary\"}},"+
+		"{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"`2 participants` · Results are hidden until voting closes\"}]},"+
+		"{\"type\":\"actions\",\"block_id\":\"un\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"My vote\"},\"action_id\":\"un,myvote\",\"value\":\"myvote\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Remove my vote\"},\"action_id\":\"un,removevote\",\"value\":\"removevote\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Edit\"},\"action_id\":\"un,edit\",\"value\":\"edit\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Close voting\"},\"action_id\":\"un,close\",\"value\":\"close\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Delete poll\"},\"action_id\":\"un,delete\",\"value\":\"delete\",\"style\":\"danger\"}]},"+
+		"{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e\"}]}]", string(render))
+}
+
+func TestRenderClosedPollWithHiddenResults(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos"}}, Creator: "marco", Features: PollFeatures{HiddenResults: true, Anonymous: true}}
+	blocks := renderPoll(poll, map[string][]Voter{"1": []Voter{Voter{userID: "U1"}, Voter{userID: "U2"}}}, true, defaultMaxVoterAvatars)
+	render, err := json.Marshal(blocks)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(render), "{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"`2 votes`\"}]}")
+	assert.NotContains(t, string(render), "Results are hidden")
+}
+
+func TestFormatMyVote(t *testing.T) {
+	poll := Poll{Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos"}, {Text: "Sushi"}}}
+
+	assert.Equal(t, "You haven't voted on *Lunch?* yet", formatMyVote(poll, ""))
+	assert.Equal(t, "Your vote on *Lunch?*: Pizza, Sushi", formatMyVote(poll, "0,2,7"))
+
+	poll.Features.RankedChoice = true
+	assert.Equal(t, "Your ranking on *Lunch?*:\n1. Sushi\n2. Pizza", formatMyVote(poll, "2,0"))
+}
@@ -0,0 +1,72 @@
+package marcopoller_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/marcopoller"
+	mmocks "github.com/alexandre-normand/marcopoller/mocks"
+	"github.com/alexandre-normand/slackscot/store/mocks"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMyVote(t *testing.T) {
+	tests := map[string]struct {
+		userVotes   string
+		err         error
+		expectedMsg string
+	}{
+		"voted":     {userVotes: "0,1", expectedMsg: "Your vote on *To do or not to do?*: Do, Not Do"},
+		"not voted": {err: marcopoller.ErrNotFound, expectedMsg: "You haven't voted on *To do or not to do?* yet"},
+		"error":     {err: fmt.Errorf("failed to load"), expectedMsg: ":warning: Error loading your votes. Please try again."},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			slackRequest := ""
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reqBody, _ := ioutil.ReadAll(r.Body)
+				slackRequest = string(reqBody)
+				fmt.Fprintln(w, "OK")
+			}))
+			defer server.Close()
+
+			callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "marco"}, ResponseURL: server.URL, ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,myvote", Value: "myvote"}}}}
+			callback.Channel.ID = "myLittleChannel"
+
+			payload, _ := json.Marshal(callback)
+			body := fmt.Sprintf("payload=%s", payload)
+
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+			storer := &mocks.Storer{}
+			storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return("{\"id\":\"1566576557-poll1\",\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":true,\"hiddenResults\":true},\"creator\":\"UID\"}", nil)
+			storer.On("GetSiloString", "1566576557-poll1", "marco").Return(tc.userVotes, tc.err)
+			defer storer.AssertExpectations(t)
+
+			verifier := &Verifier{}
+			verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+			defer verifier.AssertExpectations(t)
+
+			mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+
+			mp.HandleInteractions(w, r)
+
+			assert.Equal(t, 200, w.Result().StatusCode)
+
+			actionResponse := marcopoller.ActionResponse{}
+			require.NoError(t, json.Unmarshal([]byte(slackRequest), &actionResponse))
+			assert.Equal(t, marcopoller.ActionResponse{ResponseType: "ephemeral", Text: tc.expectedMsg, ReplaceOriginal: false}, actionResponse)
+		})
+	}
+}
@@ -4,19 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
 
-	"cloud.google.com/go/datastore"
 	"github.com/alexandre-normand/slackscot/store"
-	"github.com/alexandre-normand/slackscot/store/datastoredb"
 	"github.com/imroc/req"
 	"github.com/lithammer/shortuuid"
 	"github.com/pkg/errors"
@@ -45,14 +45,19 @@ const (
 	friendlyName          = "Marco Poller"
 	persistenceKindName   = "marcoPoller"
 	voteDelimiter         = ","
+	maxVoteAttempts       = 10
 	buttonIDPartDelimiter = ","
 )
 
 // Fixed button identifiers
 const (
-	voteButtonValue   = "vote"
-	deleteButtonValue = "delete"
-	closeButtonValue  = "close"
+	voteButtonValue       = "vote"
+	deleteButtonValue     = "delete"
+	closeButtonValue      = "close"
+	addOptionButtonValue  = "addoption"
+	editButtonValue       = "edit"
+	myVoteButtonValue     = "myvote"
+	removeVoteButtonValue = "removevote"
 )
 
 // Interactive Prompt Identifiers
@@ -68,11 +73,40 @@ const (
 	pollFeaturesInputBlockID = "poll_features"
 	pollFeaturesActionID     = "poll_features"
 	multiAnswerOptionID      = "multivoting"
+	anonymousOptionID        = "anonymous"
+	rankedChoiceOptionID     = "rankedchoice"
+	keepResultsOptionID      = "keepresults"
+	openOptionsOptionID      = "openoptions"
+	hiddenResultsOptionID    = "hiddenresults"
 
 	pollOptionsInputBlockID = "poll_answer_options"
 	pollOptionsActionID     = "poll_answer_options"
 
-	multiAnswerFeatureValue = "Allow voters to vote for many options"
+	pollDeadlineInputBlockID = "poll_deadline"
+	pollDeadlineActionID     = "poll_deadline"
+
+	pollMaxVotesInputBlockID = "poll_max_votes"
+	pollMaxVotesActionID     = "poll_max_votes"
+
+	multiAnswerFeatureValue   = "Allow voters to vote for many options"
+	anonymousFeatureValue     = "Anonymous voting (only show vote counts)"
+	rankedChoiceFeatureValue  = "Ranked choice voting (vote for options in order of preference)"
+	keepResultsFeatureValue   = "Keep results available for export after voting closes"
+	openOptionsFeatureValue   = "Allow voters to add options"
+	hiddenResultsFeatureValue = "Hide results until voting closes"
+)
+
+// Slash command poll flags
+const (
+	flagPrefix         = "--"
+	flagValueDelimiter = "="
+	anonymousFlag      = "--anonymous"
+	rankedChoiceFlag   = "--ranked"
+	deadlineFlag       = "--deadline"
+	keepResultsFlag    = "--keep-results"
+	openOptionsFlag    = "--open"
+	hiddenResultsFlag  = "--hidden-results"
+	weightFlag         = "--weight"
 )
 
 // Slack slash command parameter names
@@ -86,16 +120,64 @@ const (
 
 // Poll represents a poll
 type Poll struct {
-	ID       string       `json:"id"`
-	Question string       `json:"question"`
-	Options  []string     `json:"options"`
-	Features PollFeatures `json:"features,omitempty"`
-	Creator  string       `json:"creator"`
+	ID          string       `json:"id"`
+	MsgID       *MsgID       `json:"msgID,omitempty"`
+	ResponseURL string       `json:"responseURL,omitempty"`
+	Question    string       `json:"question"`
+	Options     []PollOption `json:"options"`
+	Features    PollFeatures `json:"features,omitempty"`
+	Creator     string       `json:"creator"`
+	Closed      bool         `json:"closed,omitempty"`
+}
+
+// MsgID identifies the slack message of a poll
+type MsgID struct {
+	ChannelID string `json:"channelID"`
+	Timestamp string `json:"timestamp"`
 }
 
 // PollFeatures represents features on a poll
 type PollFeatures struct {
-	MultiAnswers bool `json:"multianswers"`
+	MultiAnswers bool  `json:"multianswers"`
+	Anonymous    bool  `json:"anonymous,omitempty"`
+	Deadline     int64 `json:"deadline,omitempty"`
+	RankedChoice bool  `json:"rankedChoice,omitempty"`
+	KeepResults  bool  `json:"keepResults,omitempty"`
+	OpenOptions  bool  `json:"openOptions,omitempty"`
+
+	// MaxVotesPerUser limits the number of options a user can vote for on polls allowing many votes. A value of 0 means
+	// there's no limit
+	MaxVotesPerUser int `json:"maxVotesPerUser,omitempty"`
+
+	// Weights maps user IDs or user group IDs to the weight of their votes. Users without a weight have a weight of 1
+	Weights map[string]float64 `json:"weights,omitempty"`
+
+	// Eligibility restricts who can vote. Anyone can vote when it's nil
+	Eligibility *Eligibility `json:"eligibility,omitempty"`
+
+	// Reminders are the times (in seconds before the deadline) when users who haven't voted are reminded, from the earliest to the latest
+	Reminders []int64 `json:"reminders,omitempty"`
+
+	// HiddenResults hides votes while voting is open. Only the number of participants is shown until the poll is closed
+	HiddenResults bool `json:"hiddenResults,omitempty"`
+
+	// RenderStyle is how the poll is rendered. Polls are rendered with the AvatarsRenderStyle when it's empty
+	RenderStyle string `json:"renderStyle,omitempty"`
+}
+
+// DeadlineTime returns the time at which voting closes automatically. The zero time is returned
+// if the poll doesn't have a deadline
+func (pf PollFeatures) DeadlineTime() (deadline time.Time) {
+	if pf.Deadline == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(pf.Deadline, 0)
+}
+
+// isDue returns true if the poll has a deadline that is at or before the given time
+func (pf PollFeatures) isDue(now time.Time) bool {
+	return pf.Deadline != 0 && !now.Before(pf.DeadlineTime())
 }
 
 // ActionResponse represents a response to a slash command or action
@@ -111,6 +193,8 @@ type Voter struct {
 	userID    string
 	avatarURL string
 	name      string
+	rank      int
+	weight    float64
 }
 
 // instruments
@@ -125,14 +209,27 @@ type instruments struct {
 
 // MarcoPoller represents a Marco Poller instance
 type MarcoPoller struct {
-	storer       store.GlobalSiloStringStorer
-	userFinder   UserFinder
-	verifier     Verifier
-	pollVerifier PollVerifier
-	dialoguer    Dialoguer
-	debug        bool
-	meter        metric.Meter
-	instruments  *instruments
+	storer         store.GlobalSiloStringStorer
+	reminderStorer store.GlobalSiloStringStorer
+	templateStorer store.GlobalSiloStringStorer
+	archiveStorer  store.GlobalSiloStringStorer
+	userFinder     UserFinder
+	memberFinder   MemberFinder
+	verifier       Verifier
+	exportVerifier Verifier
+	pollVerifier   PollVerifier
+	dialoguer      Dialoguer
+	messenger      Messenger
+	tokenStore     TokenStore
+	newTeamClient  TeamClientFactory
+	installer      Installer
+
+	// maxVoterAvatars is the number of voter avatars shown for each option
+	maxVoterAvatars int
+
+	debug       bool
+	meter       metric.Meter
+	instruments *instruments
 }
 
 // DeleteMessage represents the slack action response to delete an original message
@@ -156,10 +253,25 @@ type Verifier interface {
 	Verify(header http.Header, body []byte) (err error)
 }
 
-// Dialoguer is implemented by any value that has the OpenView method
+// Dialoguer is implemented by any value that has the OpenView and UpdateView methods
 type Dialoguer interface {
 	// OpenView will open a block kit modal view. See https://pkg.go.dev/github.com/slack-go/slack?tab=doc#Client.OpenView
 	OpenView(triggerID string, view slack.ModalViewRequest) (resp *slack.ViewResponse, err error)
+
+	// UpdateView will update an open block kit modal view. See https://pkg.go.dev/github.com/slack-go/slack?tab=doc#Client.UpdateView
+	UpdateView(view slack.ModalViewRequest, externalID string, hash string, viewID string) (resp *slack.ViewResponse, err error)
+}
+
+// Messenger is implemented by any value that has the PostMessage, UpdateMessage and DeleteMessage methods
+type Messenger interface {
+	// PostMessage will send a message to a channel. See https://pkg.go.dev/github.com/slack-go/slack?tab=doc#Client.PostMessage
+	PostMessage(channelID string, options ...slack.MsgOption) (respChannel string, respTimestamp string, err error)
+
+	// UpdateMessage will update a message in a channel. See https://pkg.go.dev/github.com/slack-go/slack?tab=doc#Client.UpdateMessage
+	UpdateMessage(channelID string, timestamp string, options ...slack.MsgOption) (respChannel string, respTimestamp string, text string, err error)
+
+	// DeleteMessage will delete a message in a channel. See https://pkg.go.dev/github.com/slack-go/slack?tab=doc#Client.DeleteMessage
+	DeleteMessage(channelID string, messageTimestamp string) (respChannel string, respTimestamp string, err error)
 }
 
 // SlackVerifier represents a slack verifier backed by github.com/slack-go/slack
@@ -242,6 +354,15 @@ func OptionSlackDialoguer(token string, debug bool) Option {
 	}
 }
 
+// OptionSlackMessenger sets a slack-go/slack.Client as the implementation of Messenger
+func OptionSlackMessenger(token string, debug bool) Option {
+	return func(mp *MarcoPoller) (err error) {
+		sc := slack.New(token, slack.OptionDebug(debug))
+		mp.messenger = sc
+		return nil
+	}
+}
+
 // OptionSlackVerifier sets a slack-go-backed SlackVerifier as the implementation of Verifier
 func OptionSlackVerifier(slackSigningSecret string) Option {
 	return func(mp *MarcoPoller) (err error) {
@@ -251,12 +372,12 @@ func OptionSlackVerifier(slackSigningSecret string) Option {
 	}
 }
 
-// OptionDatastore sets a datastoredb as the implementation of GlobalSiloStringStorer
+// OptionDatastore sets a DatastoreStorer as the implementation of GlobalSiloStringStorer
 func OptionDatastore(datastoreProjectID string, gcloudClientOpts ...option.ClientOption) Option {
 	return func(mp *MarcoPoller) (err error) {
 		meter := otel.GetMeterProvider().Meter("github.com/alexandre-normand/marcopoller")
 
-		mp.storer, err = datastoredb.NewWithTelemetry(appName, meter, persistenceKindName, datastoreProjectID, gcloudClientOpts...)
+		mp.storer, err = NewDatastoreStorer(appName, meter, persistenceKindName, datastoreProjectID, gcloudClientOpts...)
 		if err != nil {
 			return errors.Wrapf(err, "Error initializing datastore persistence on project [%s]", datastoreProjectID)
 		}
@@ -281,6 +402,15 @@ func OptionDialoguer(dialoguer Dialoguer) Option {
 	}
 }
 
+// OptionMessenger sets a messenger as the implementation on MarcoPoller. Without a messenger, polls are
+// posted and updated using slack response urls only
+func OptionMessenger(messenger Messenger) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.messenger = messenger
+		return nil
+	}
+}
+
 // OptionStorer sets a storer as the implementation on MarcoPoller
 func OptionStorer(storer store.GlobalSiloStringStorer) Option {
 	return func(mp *MarcoPoller) (err error) {
@@ -297,6 +427,20 @@ func OptionVerifier(verifier Verifier) Option {
 	}
 }
 
+// OptionExportVerifier sets the verifier used to authorize poll result exports. Exports are verified
+// with the Verifier when this option isn't set
+func OptionExportVerifier(verifier Verifier) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.exportVerifier = verifier
+		return nil
+	}
+}
+
+// OptionExportToken authorizes poll result exports with a bearer token instead of the Verifier
+func OptionExportToken(token string) Option {
+	return OptionExportVerifier(&TokenVerifier{Token: token})
+}
+
 // OptionPollVerifier provides a pollVerifier implementation to MarcoPoller
 func OptionPollVerifier(pollVerifier PollVerifier) Option {
 	return func(mp *MarcoPoller) (err error) {
@@ -315,12 +459,13 @@ func OptionDebug(debug bool) Option {
 
 // New returns a new MarcoPoller with the default slack client and datastoredb implementations
 func New(slackToken string, slackSigningSecret string, datastoreProjectID string, gcloudClientOpts ...option.ClientOption) (mp *MarcoPoller, err error) {
-	return NewWithOptions(OptionSlackVerifier(slackSigningSecret), OptionSlackUserFinder(slackToken, cast.ToBool(os.Getenv(DebugEnabledEnv))), OptionSlackDialoguer(slackToken, cast.ToBool(os.Getenv(DebugEnabledEnv))), OptionDatastore(datastoreProjectID, gcloudClientOpts...), OptionPollVerifier(AlwaysValidPollVerifier{}))
+	return NewWithOptions(OptionSlackVerifier(slackSigningSecret), OptionSlackUserFinder(slackToken, cast.ToBool(os.Getenv(DebugEnabledEnv))), OptionSlackDialoguer(slackToken, cast.ToBool(os.Getenv(DebugEnabledEnv))), OptionSlackMessenger(slackToken, cast.ToBool(os.Getenv(DebugEnabledEnv))), OptionSlackMemberFinder(slackToken, cast.ToBool(os.Getenv(DebugEnabledEnv))), OptionDatastore(datastoreProjectID, gcloudClientOpts...), OptionDatastoreReminderStorer(datastoreProjectID, gcloudClientOpts...), OptionDatastoreTemplateStorer(datastoreProjectID, gcloudClientOpts...), OptionDatastoreArchiveStorer(datastoreProjectID, gcloudClientOpts...), OptionPollVerifier(AlwaysValidPollVerifier{}))
 }
 
 // NewWithOptions returns a new MarcoPoller with specified options
 func NewWithOptions(opts ...Option) (mp *MarcoPoller, err error) {
 	mp = new(MarcoPoller)
+	mp.maxVoterAvatars = defaultMaxVoterAvatars
 
 	for _, apply := range opts {
 		err := apply(mp)
@@ -329,7 +474,8 @@ func NewWithOptions(opts ...Option) (mp *MarcoPoller, err error) {
 		}
 	}
 
-	if mp.userFinder == nil {
+	// With a token store, slack clients are created for each team so there's no need for a UserFinder or a Dialoguer
+	if mp.userFinder == nil && mp.tokenStore == nil {
 		return nil, fmt.Errorf("UserFinder is nil after applying all Options. Did you forget to set one?")
 	}
 
@@ -345,16 +491,50 @@ func NewWithOptions(opts ...Option) (mp *MarcoPoller, err error) {
 		return nil, fmt.Errorf("PollVerifier is nil after applying all Options. Did you forget to set one?")
 	}
 
-	if mp.dialoguer == nil {
+	if mp.dialoguer == nil && mp.tokenStore == nil {
 		return nil, fmt.Errorf("Dialoguer is nil after applying all Options. Did you forget to set one?")
 	}
 
+	if mp.newTeamClient == nil {
+		mp.newTeamClient = mp.newSlackTeamClient
+	}
+
+	if mp.exportVerifier == nil {
+		mp.exportVerifier = mp.verifier
+	}
+
 	mp.meter = otel.GetMeterProvider().Meter("github.com/alexandre-normand/marcopoller")
 	mp.instruments = newInstruments(mp.meter)
 
 	return mp, err
 }
 
+// Close closes the storers of the MarcoPoller: the storers of polls, reminders, templates and archived polls and the
+// TokenStore when it can be closed. Storers are all closed even if some fail and their errors are returned together
+func (mp *MarcoPoller) Close() (err error) {
+	closers := []io.Closer{mp.reminderStorer, mp.templateStorer, mp.archiveStorer}
+	if closer, ok := mp.tokenStore.(io.Closer); ok {
+		closers = append(closers, closer)
+	}
+
+	// The poll storer is closed last since the other storers may share its database (i.e. SQLSiloStorer)
+	closers = append(closers, mp.storer)
+
+	errs := make([]error, 0)
+	for _, closer := range closers {
+		if closer == nil {
+			continue
+		}
+
+		err = closer.Close()
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return combineErrors(errs)
+}
+
 func newInstruments(meter metric.Meter) *instruments {
 	defaultLabels := label.String("name", appName)
 	mt := metric.Must(meter)
@@ -402,7 +582,7 @@ func (mp *MarcoPoller) StartPoll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	pollText, creator, responseURL, triggerID, err := parseNewPollRequest(string(body))
+	pollText, creator, channelID, responseURL, triggerID, teamID, err := parseNewPollRequest(string(body))
 	if err != nil {
 		log.Printf("Error parsing poll request: %v", err)
 		http.Error(w, err.Error(), 400)
@@ -413,7 +593,19 @@ func (mp *MarcoPoller) StartPoll(w http.ResponseWriter, r *http.Request) {
 	// to avoid timeouts
 	w.WriteHeader(http.StatusOK)
 
-	interactive, question, options, err := parsePollParams(pollText)
+	mp, err = mp.forTeam(teamID)
+	if err != nil {
+		log.Printf("Error loading team [%s]: %v", teamID, err)
+		showErrorToUser(responseURL, fmt.Sprintf(":warning: %s isn't installed on this workspace. Please ask an admin to install it.", friendlyName))
+		return
+	}
+
+	if subcommand, args, ok := parseSubcommand(pollText); ok {
+		mp.handleSubcommand(subcommand, args, creator, channelID, responseURL, w)
+		return
+	}
+
+	interactive, question, options, features, err := parsePollParams(pollText, time.Now())
 	if err != nil {
 		showErrorToUser(responseURL, ":warning: Wrong usage. `/poll \"Question\" \"Option 1\" \"Option 2\" ...`")
 
@@ -421,8 +613,14 @@ func (mp *MarcoPoller) StartPoll(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if interactive {
-		interactivePrompt := createInteractivePollPrompt()
-		_, err := mp.dialoguer.OpenView(triggerID, interactivePrompt)
+		templates, err := mp.listTemplates()
+		if err != nil {
+			// The prompt is still useful without the template picker
+			log.Printf("Error listing templates: %v", err)
+		}
+
+		interactivePrompt := createInteractivePollPrompt(templates, nil, mp.templateStorer != nil)
+		_, err = mp.dialoguer.OpenView(triggerID, interactivePrompt)
 		if err != nil {
 			log.Printf("Error opening up interactive prompt for trigger id [%s]: %s", triggerID, err.Error())
 			showErrorToUser(responseURL, ":warning: Error opening up interactive prompt. Try again, maybe?")
@@ -433,7 +631,7 @@ func (mp *MarcoPoller) StartPoll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	mp.createNewPoll(question, options, creator, PollFeatures{}, responseURL, w)
+	mp.createNewPoll(question, literalOptions(options), creator, features, channelID, responseURL, w)
 }
 
 // showErrorToUser sends an ephemeral response to a user with a best effort. If there's an error
@@ -452,10 +650,22 @@ func showErrorToUser(responseURL string, errorMsg string) {
 	}
 }
 
-// createNewPoll creates a new poll and handles the persistence and posting to slack
-func (mp *MarcoPoller) createNewPoll(question string, options []string, creator string, features PollFeatures, responseURL string, w http.ResponseWriter) {
+// showMessageToUser sends a message only visible to the user of an interaction using the response url
+func showMessageToUser(responseURL string, msg string) {
+	actionResponse := ActionResponse{ResponseType: "ephemeral", Text: msg, ReplaceOriginal: false}
+	resp, err := req.Post(responseURL, req.BodyJSON(&actionResponse))
+	if err != nil {
+		log.Printf("Error sending message [%s] to user: %s", msg, err.Error())
+	} else if resp.Response().StatusCode != 200 {
+		log.Printf("Error sending message [%s] to user: %s", msg, resp.String())
+	}
+}
+
+// createNewPoll creates a new poll and handles the persistence and posting to slack. When a messenger is configured, the poll
+// is posted to the channel so that its message can be updated without relying on the short-lived response url
+func (mp *MarcoPoller) createNewPoll(question string, options []PollOption, creator string, features PollFeatures, channelID string, responseURL string, w http.ResponseWriter) {
 	pollCreationTime := time.Now()
-	poll := Poll{ID: generatePollID(pollCreationTime.Unix()), Question: question, Options: options, Creator: creator, Features: features}
+	poll := Poll{ID: generatePollID(pollCreationTime.Unix()), ResponseURL: responseURL, Question: question, Options: options, Creator: creator, Features: features}
 
 	encodedPoll, err := encodePoll(poll)
 	if err != nil {
@@ -471,17 +681,27 @@ func (mp *MarcoPoller) createNewPoll(question string, options []string, creator
 		return
 	}
 
-	actionResponse := ActionResponse{ResponseType: "in_channel", Blocks: renderPoll(poll, map[string][]Voter{}, false)}
-	resp, err := req.Post(responseURL, req.BodyJSON(&actionResponse))
-	if err != nil || resp.Response().StatusCode != 200 {
-		if err != nil {
-			log.Printf("Error writing new poll [%s] message: %s", poll.ID, err.Error())
-			showErrorToUser(responseURL, ":warning: Error writing new poll to slack. Please try again.")
-		} else {
-			log.Printf("Error writing new poll [%s] message: %s", poll.ID, resp.String())
-			showErrorToUser(responseURL, ":warning: Error writing new poll to slack. Please try again.")
+	blocks := renderPoll(poll, map[string][]Voter{}, false, mp.maxVoterAvatars)
+
+	if mp.messenger != nil && channelID != "" {
+		_, timestamp, err := mp.messenger.PostMessage(channelID, slack.MsgOptionText(poll.Question, false), slack.MsgOptionBlocks(blocks...))
+		if err == nil {
+			poll.MsgID = &MsgID{ChannelID: channelID, Timestamp: timestamp}
+			mp.persistPollMsgID(poll)
+
+			ctx := context.Background()
+			mp.instruments.pollCount.Add(ctx, 1)
+			return
 		}
 
+		// Fall back on the response url (i.e. if the bot isn't a member of the channel)
+		log.Printf("Error posting new poll [%s] message to channel [%s], falling back on response url: %s", poll.ID, channelID, err.Error())
+	}
+
+	err = postToResponseURL(responseURL, &ActionResponse{ResponseType: "in_channel", Blocks: blocks})
+	if err != nil {
+		log.Printf("Error writing new poll [%s] message: %s", poll.ID, err.Error())
+		showErrorToUser(responseURL, ":warning: Error writing new poll to slack. Please try again.")
 		return
 	}
 
@@ -489,6 +709,64 @@ func (mp *MarcoPoller) createNewPoll(question string, options []string, creator
 	mp.instruments.pollCount.Add(ctx, 1)
 }
 
+// persistPollMsgID updates a stored poll with the identifier of its slack message. If that fails, we log the error
+// and the poll keeps working with response urls only
+func (mp *MarcoPoller) persistPollMsgID(poll Poll) {
+	encodedPoll, err := encodePoll(poll)
+	if err == nil {
+		err = mp.storer.PutSiloString(poll.ID, pollInfoKey, encodedPoll)
+	}
+
+	if err != nil {
+		log.Printf("Error persisting message identifier of poll [%s]: %s", poll.ID, err.Error())
+	}
+}
+
+// updatePollMessage replaces the content of a poll message with new blocks. The message is updated with the messenger if
+// the poll message is known, falling back on the response url otherwise
+func (mp *MarcoPoller) updatePollMessage(poll Poll, responseURL string, blocks []slack.Block) (err error) {
+	if mp.messenger != nil && poll.MsgID != nil {
+		_, _, _, err = mp.messenger.UpdateMessage(poll.MsgID.ChannelID, poll.MsgID.Timestamp, slack.MsgOptionText(poll.Question, false), slack.MsgOptionBlocks(blocks...))
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("Error updating poll [%s] message in channel [%s], falling back on response url: %s", poll.ID, poll.MsgID.ChannelID, err.Error())
+	}
+
+	return postToResponseURL(responseURL, &UpdateMessage{ActionResponse: ActionResponse{Blocks: blocks, ReplaceOriginal: true}})
+}
+
+// deletePollMessage deletes a poll message with the messenger if the poll message is known, falling back on the
+// response url otherwise
+func (mp *MarcoPoller) deletePollMessage(poll Poll, responseURL string) (err error) {
+	if mp.messenger != nil && poll.MsgID != nil {
+		_, _, err = mp.messenger.DeleteMessage(poll.MsgID.ChannelID, poll.MsgID.Timestamp)
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("Error deleting poll [%s] message in channel [%s], falling back on response url: %s", poll.ID, poll.MsgID.ChannelID, err.Error())
+	}
+
+	return postToResponseURL(responseURL, &DeleteMessage{DeleteOriginal: true})
+}
+
+// postToResponseURL posts a message to a slack response url. An error is returned if the request fails or if
+// slack doesn't accept the message
+func postToResponseURL(responseURL string, message interface{}) (err error) {
+	resp, err := req.Post(responseURL, req.BodyJSON(message))
+	if err != nil {
+		return err
+	}
+
+	if resp.Response().StatusCode != 200 {
+		return fmt.Errorf("unexpected response from slack with status [%d]: %s", resp.Response().StatusCode, resp.String())
+	}
+
+	return nil
+}
+
 // slackTimestampToTime converts a slack timestamp string (something like "1556928600.008500") to a time.
 func slackTimestampToTime(slackTimestamp string) (parsedTime time.Time) {
 	timeAsFloat := cast.ToFloat64(slackTimestamp)
@@ -518,14 +796,15 @@ func getPollCreationTime(pollID string) (creationTime time.Time) {
 	return time.Unix(creationTimeSeconds, 0)
 }
 
-// parseNewPollRequest parses a new poll request and returns the pollText, the creator and the response url
-func parseNewPollRequest(requestBody string) (pollText string, creator string, responseURL string, triggerID string, err error) {
+// parseNewPollRequest parses a new poll request and returns the pollText, the creator, the channel, the response url, the trigger id
+// and the team id
+func parseNewPollRequest(requestBody string) (pollText string, creator string, channelID string, responseURL string, triggerID string, teamID string, err error) {
 	params, err := parseRequest(requestBody)
 	if err != nil {
-		return "", "", "", "", err
+		return "", "", "", "", "", "", err
 	}
 
-	return params[textParam], params[creatorParam], params[responseURLParam], params[triggerIDParam], nil
+	return params[textParam], params[creatorParam], params[channelParam], params[responseURLParam], params[triggerIDParam], params[teamIDParam], nil
 }
 
 // parseRequest parses a slack request parameters. Since slack request parameters have a single value,
@@ -570,14 +849,54 @@ func encodePoll(poll Poll) (encoded string, err error) {
 	return string(m), nil
 }
 
-// renderPoll renders a poll with its votes to slack blocks
-func renderPoll(poll Poll, votes map[string][]Voter, votingActive bool) (blocks []slack.Block) {
+// renderPoll renders a poll with its votes to slack blocks, showing up to maxAvatars voter avatars for each option
+func renderPoll(poll Poll, votes map[string][]Voter, votingActive bool, maxAvatars int) (blocks []slack.Block) {
 	blocks = make([]slack.Block, 0)
 
 	blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*%s*", poll.Question), false, false), nil, nil))
+	if poll.Features.RankedChoice && !votingActive {
+		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", "Ranked choice: vote for options in order of preference. Vote again on an option to remove it from your ranking.", false, false)))
+	}
+
+	if poll.Features.MaxVotesPerUser > 0 && !votingActive {
+		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", formatVoteLimit(poll.Features.MaxVotesPerUser), false, false)))
+	}
+
+	if poll.Features.Eligibility != nil && !votingActive {
+		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", formatEligibility(*poll.Features.Eligibility), false, false)))
+	}
+
+	weighted := len(poll.Features.Weights) > 0
+	if weighted && !votingActive {
+		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", formatWeights(poll.Features.Weights), false, false)))
+	}
+
+	// Votes of polls with hidden results are only shown once voting is closed
+	hidden := poll.Features.HiddenResults && !votingActive
+
+	// Closed polls rendered as a bar chart are sorted by votes with their winners highlighted
+	bars := poll.Features.RenderStyle == BarsRenderStyle
+	totalVoters := countVoters(votes)
+	optionIDs := make([]string, 0, len(poll.Options))
+	for i := range poll.Options {
+		optionIDs = append(optionIDs, fmt.Sprintf("%d", i))
+	}
+
+	var rounds []runoffRound
+	if votingActive && poll.Features.RankedChoice {
+		rounds = instantRunoff(optionIDs, ballotsFromVotes(votes))
+	}
+
+	winners := make(map[string]bool)
+	if votingActive && bars {
+		winners = findWinners(poll, votes, rounds)
+		optionIDs = sortedOptionIDs(poll, votes, winners)
+	}
+
 	blocks = append(blocks, slack.NewDividerBlock())
-	for i, opt := range poll.Options {
-		optionID := fmt.Sprintf("%d", i)
+	for _, optionID := range optionIDs {
+		i, _ := strconv.Atoi(optionID)
+		opt := poll.Options[i]
 
 		var accessory *slack.Accessory
 		if !votingActive {
@@ -587,69 +906,201 @@ func renderPoll(poll Poll, votes map[string][]Voter, votingActive bool) (blocks
 			accessory = slack.NewAccessory(voteButton)
 		}
 
-		blocks = append(blocks, *slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", fmt.Sprintf(" • %s", opt), false, false), nil, accessory))
-		if voters, ok := votes[optionID]; ok {
-			voteBlocks := make([]slack.MixedElement, 0)
-			i := 0
-			for i = 0; i < len(voters) && i < 9; i++ {
-				voter := voters[i]
-				voteBlocks = append(voteBlocks, slack.NewImageBlockElement(voter.avatarURL, voter.name))
-			}
+		optionText := fmt.Sprintf(" • %s", formatOptionLabel(opt))
+		if winners[optionID] {
+			optionText = fmt.Sprintf(" • %s *%s*", winnerMarker, formatOptionLabel(opt))
+		}
 
-			if i == 9 {
-				voteBlocks = append(voteBlocks, slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("`+ %d`", len(voters)-9), false, false))
+		if opt.Description != "" {
+			optionText = fmt.Sprintf("%s\n%s", optionText, opt.Description)
+		}
+
+		// The bar and the weighted count are part of the option's section to keep polls within the blocks of a slack message
+		voters := votes[optionID]
+		if !hidden && bars {
+			bar := formatBar(len(voters), totalVoters)
+			if weighted {
+				bar = fmt.Sprintf("%s · weighted `%s`", bar, formatWeight(weightedScore(voters)))
 			}
 
-			if len(voteBlocks) > 0 {
-				blocks = append(blocks, slack.NewContextBlock("", voteBlocks...))
+			optionText = fmt.Sprintf("%s\n%s", optionText, bar)
+		} else if !hidden && weighted && !poll.Features.Anonymous && len(voters) > 0 {
+			optionText = fmt.Sprintf("%s\n%s", optionText, formatWeightedVoteCount(len(voters), weightedScore(voters)))
+		}
+
+		blocks = append(blocks, *slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", optionText, false, false), nil, accessory))
+
+		if hidden {
+			continue
+		}
+
+		// Anonymous polls never reveal voters, only how many voted for each option (which the bar chart already shows)
+		if poll.Features.Anonymous {
+			if len(voters) > 0 && weighted && !bars {
+				blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", formatWeightedVoteCount(len(voters), weightedScore(voters)), false, false)))
+			} else if len(voters) > 0 && !bars {
+				blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", formatVoteCount(len(voters)), false, false)))
 			}
+
+			continue
 		}
+
+		if _, ok := votes[optionID]; ok {
+			blocks = append(blocks, renderVoters(poll, optionID, voters, maxAvatars, votingActive)...)
+		}
+	}
+
+	if hidden {
+		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("%s · Results are hidden until voting closes", formatParticipantCount(totalVoters)), false, false)))
 	}
 
 	if !votingActive {
 		deleteButton := slack.NewButtonBlockElement(formatButtonID(poll.ID, deleteButtonValue), deleteButtonValue, slack.NewTextBlockObject("plain_text", "Delete poll", false, false))
 		deleteButton.Style = slack.StyleDanger
 
-		blocks = append(blocks, slack.NewActionBlock(poll.ID, slack.NewButtonBlockElement(formatButtonID(poll.ID, closeButtonValue), closeButtonValue, slack.NewTextBlockObject("plain_text", "Close voting", false, false)), deleteButton))
-		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Created by <@%s>", poll.Creator), false, false)))
+		actions := make([]slack.BlockElement, 0)
+		if poll.Features.OpenOptions {
+			actions = append(actions, slack.NewButtonBlockElement(formatButtonID(poll.ID, addOptionButtonValue), addOptionButtonValue, slack.NewTextBlockObject("plain_text", "Add option", false, false)))
+		}
+
+		if poll.Features.HiddenResults {
+			actions = append(actions, slack.NewButtonBlockElement(formatButtonID(poll.ID, myVoteButtonValue), myVoteButtonValue, slack.NewTextBlockObject("plain_text", "My vote", false, false)))
+		}
+
+		actions = append(actions, slack.NewButtonBlockElement(formatButtonID(poll.ID, removeVoteButtonValue), removeVoteButtonValue, slack.NewTextBlockObject("plain_text", "Remove my vote", false, false)))
+		actions = append(actions, slack.NewButtonBlockElement(formatButtonID(poll.ID, editButtonValue), editButtonValue, slack.NewTextBlockObject("plain_text", "Edit", false, false)))
+		actions = append(actions, slack.NewButtonBlockElement(formatButtonID(poll.ID, closeButtonValue), closeButtonValue, slack.NewTextBlockObject("plain_text", "Close voting", false, false)), deleteButton)
+		blocks = append(blocks, slack.NewActionBlock(poll.ID, actions...))
+
+		if poll.Features.Deadline != 0 {
+			blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Created by <@%s> (voting closes %s)", poll.Creator, formatSlackDate(poll.Features.DeadlineTime())), false, false)))
+		} else {
+			blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Created by <@%s>", poll.Creator), false, false)))
+		}
 	} else {
+		if poll.Features.RankedChoice {
+			blocks = append(blocks, renderRunoff(poll, rounds)...)
+		}
+
+		if weighted && !poll.Features.RankedChoice {
+			blocks = append(blocks, renderWeightedResults(poll, votes)...)
+		}
+
 		blocks = append(blocks, slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("Created by <@%s> (voting closed)", poll.Creator), false, false)))
 	}
 
 	return blocks
 }
 
+// formatSlackDate formats a time using the slack date formatting which renders it in the reader's timezone
+func formatSlackDate(t time.Time) (formatted string) {
+	return fmt.Sprintf("<!date^%d^{date_short_pretty} at {time}|%s>", t.Unix(), t.UTC().Format(time.RFC1123))
+}
+
+// formatVoteCount formats a number of votes for display
+func formatVoteCount(count int) (formatted string) {
+	if count == 1 {
+		return "`1 vote`"
+	}
+
+	return fmt.Sprintf("`%d votes`", count)
+}
+
+// formatVoteLimit formats the maximum number of votes per user for display
+func formatVoteLimit(maxVotes int) (formatted string) {
+	if maxVotes == 1 {
+		return "Vote for 1 option"
+	}
+
+	return fmt.Sprintf("Vote for up to %d options", maxVotes)
+}
+
 // formatButtonID formats a button action ID
 func formatButtonID(pollID string, action string) (buttonID string) {
 	return fmt.Sprintf("%s%s%s", pollID, buttonIDPartDelimiter, action)
 }
 
-// createInteractivePollPrompt renders the content of a new poll dialog
-func createInteractivePollPrompt() (viewRequest slack.ModalViewRequest) {
+// createInteractivePollPrompt renders the content of a new poll dialog. When templates are enabled, the dialog starts with
+// a picker of the saved templates and allows saving the poll as a template. A selected template prefills the poll fields
+func createInteractivePollPrompt(templates []PollTemplate, selected *PollTemplate, templatesEnabled bool) (viewRequest slack.ModalViewRequest) {
 	blocks := make([]slack.Block, 0)
 
+	if len(templates) > 0 {
+		blocks = append(blocks, createTemplatePicker(templates, selected))
+	}
+
+	// The selected template is kept in the view metadata and in the block IDs of the prefilled inputs since slack keeps
+	// the values entered in inputs when a view is updated with the same block IDs
+	templateKey := ""
+	if selected != nil {
+		templateKey = normalizeTemplateName(selected.Name)
+	}
+
 	conversationSelect := slack.NewOptionsSelectBlockElement(slack.OptTypeConversations, nil, pollConversationSelectActionID)
 	conversationSelect.DefaultToCurrentConversation = true
 	conversationSelect.ResponseURLEnabled = true
 
+	questionInput := slack.NewPlainTextInputBlockElement(slack.NewTextBlockObject("plain_text", "What's your favorite color?", false, false), pollQuestionActionID)
+
 	blocks = append(blocks, slack.NewInputBlock(pollConversationInputBlockID, slack.NewTextBlockObject("plain_text", "Where do you want to send your poll?", false, false), conversationSelect))
-	blocks = append(blocks, slack.NewInputBlock(pollQuestionInputBlockID, slack.NewTextBlockObject("plain_text", "What's your poll about?", false, false), slack.NewPlainTextInputBlockElement(slack.NewTextBlockObject("plain_text", "What's your favorite color?", false, false), pollQuestionActionID)))
+	blocks = append(blocks, slack.NewInputBlock(templateBlockID(pollQuestionInputBlockID, templateKey), slack.NewTextBlockObject("plain_text", "What's your poll about?", false, false), questionInput))
 
 	answerOptionsInput := slack.NewPlainTextInputBlockElement(slack.NewTextBlockObject("plain_text", "All the color options (one per line)", false, false), pollOptionsActionID)
 	answerOptionsInput.Multiline = true
-	answerOptionsBlock := slack.NewInputBlock(pollOptionsInputBlockID, slack.NewTextBlockObject("plain_text", "Answer Options", false, false), answerOptionsInput)
-	answerOptionsBlock.Hint = slack.NewTextBlockObject("plain_text", "Enter the answer options (one per line)", false, false)
+	answerOptionsBlock := slack.NewInputBlock(templateBlockID(pollOptionsInputBlockID, templateKey), slack.NewTextBlockObject("plain_text", "Answer Options", false, false), answerOptionsInput)
+	answerOptionsBlock.Hint = slack.NewTextBlockObject("plain_text", "Enter the answer options (one per line). Add an emoji, a description and a link with :emoji: Option | Description | https://link", false, false)
 	blocks = append(blocks, answerOptionsBlock)
 
-	featuresInputBlock := slack.NewInputBlock(pollFeaturesInputBlockID, slack.NewTextBlockObject("plain_text", "Options", false, false), slack.NewCheckboxGroupsBlockElement(pollFeaturesActionID, slack.NewOptionBlockObject(multiAnswerOptionID, slack.NewTextBlockObject("plain_text", multiAnswerFeatureValue, false, false), nil)))
+	featureOptions := []*slack.OptionBlockObject{
+		slack.NewOptionBlockObject(multiAnswerOptionID, slack.NewTextBlockObject("plain_text", multiAnswerFeatureValue, false, false), nil),
+		slack.NewOptionBlockObject(anonymousOptionID, slack.NewTextBlockObject("plain_text", anonymousFeatureValue, false, false), nil),
+		slack.NewOptionBlockObject(rankedChoiceOptionID, slack.NewTextBlockObject("plain_text", rankedChoiceFeatureValue, false, false), nil),
+		slack.NewOptionBlockObject(keepResultsOptionID, slack.NewTextBlockObject("plain_text", keepResultsFeatureValue, false, false), nil),
+		slack.NewOptionBlockObject(openOptionsOptionID, slack.NewTextBlockObject("plain_text", openOptionsFeatureValue, false, false), nil),
+		slack.NewOptionBlockObject(hiddenResultsOptionID, slack.NewTextBlockObject("plain_text", hiddenResultsFeatureValue, false, false), nil),
+		slack.NewOptionBlockObject(channelMembersOptionID, slack.NewTextBlockObject("plain_text", channelMembersFeatureValue, false, false), nil),
+		slack.NewOptionBlockObject(barChartOptionID, slack.NewTextBlockObject("plain_text", barChartFeatureValue, false, false), nil)}
+	featuresInput := slack.NewCheckboxGroupsBlockElement(pollFeaturesActionID, featureOptions...)
+
+	featuresInputBlock := slack.NewInputBlock(templateBlockID(pollFeaturesInputBlockID, templateKey), slack.NewTextBlockObject("plain_text", "Options", false, false), featuresInput)
 	featuresInputBlock.Optional = true
 	blocks = append(blocks, featuresInputBlock)
 
+	deadlineInput := slack.NewPlainTextInputBlockElement(slack.NewTextBlockObject("plain_text", "2h", false, false), pollDeadlineActionID)
+	deadlineInputBlock := slack.NewInputBlock(templateBlockID(pollDeadlineInputBlockID, templateKey), slack.NewTextBlockObject("plain_text", "Close voting automatically", false, false), deadlineInput)
+	deadlineInputBlock.Hint = slack.NewTextBlockObject("plain_text", "Enter a duration (i.e. 30m, 2h) or a time (i.e. 2020-10-20T15:00:00-07:00)", false, false)
+	deadlineInputBlock.Optional = true
+	blocks = append(blocks, deadlineInputBlock)
+
+	maxVotesInput := slack.NewPlainTextInputBlockElement(slack.NewTextBlockObject("plain_text", "3", false, false), pollMaxVotesActionID)
+	maxVotesInput.MaxLength = 3
+	maxVotesInputBlock := slack.NewInputBlock(templateBlockID(pollMaxVotesInputBlockID, templateKey), slack.NewTextBlockObject("plain_text", "Maximum number of votes per voter", false, false), maxVotesInput)
+	maxVotesInputBlock.Hint = slack.NewTextBlockObject("plain_text", "Enter a number to limit how many options voters can vote for (only when voting for many options is allowed)", false, false)
+	maxVotesInputBlock.Optional = true
+	blocks = append(blocks, maxVotesInputBlock)
+
+	if selected != nil {
+		questionInput.InitialValue = selected.Question
+		answerOptionsInput.InitialValue = formatOptionLines(selected.Options)
+		featuresInput.InitialOptions = selectedFeatureOptions(selected.Features, featureOptions)
+		if selected.Duration > 0 {
+			deadlineInput.InitialValue = formatTemplateDuration(selected.Duration)
+		}
+		if selected.Features.MaxVotesPerUser > 0 {
+			maxVotesInput.InitialValue = strconv.Itoa(selected.Features.MaxVotesPerUser)
+		}
+	}
+
+	if templatesEnabled {
+		blocks = append(blocks, createTemplateNameInput())
+	}
+
 	viewRequest.Type = slack.VTModal
 	viewRequest.Title = slack.NewTextBlockObject("plain_text", friendlyName, false, false)
 	viewRequest.Close = slack.NewTextBlockObject("plain_text", "Cancel", false, false)
 	viewRequest.Submit = slack.NewTextBlockObject("plain_text", "Create Poll", false, false)
 	viewRequest.CallbackID = interactivePollCallbackID
+	viewRequest.PrivateMetadata = templateKey
 	viewRequest.Blocks = slack.Blocks{BlockSet: blocks}
 
 	return viewRequest
@@ -707,11 +1158,35 @@ func (mp *MarcoPoller) HandleInteractions(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// View submissions can be answered with a response action (i.e. validation errors) which slack expects as json
+	if callback.Type == "view_submission" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+
 	// Request accepted so we send back the 200 OK to slack to avoid timeouts
 	w.WriteHeader(http.StatusOK)
 
-	if callback.Type == "block_actions" {
+	mp, err = mp.forTeam(callback.Team.ID)
+	if err != nil {
+		log.Printf("Error loading team [%s]: %v", callback.Team.ID, err)
+		showErrorToUser(callback.ResponseURL, fmt.Sprintf(":warning: %s isn't installed on this workspace. Please ask an admin to install it.", friendlyName))
+		return
+	}
+
+	if callback.Type == "block_actions" && callback.View.CallbackID == interactivePollCallbackID {
+		mp.handleTemplateSelection(callback)
+
+		return
+	} else if callback.Type == "block_actions" {
 		mp.handlePollInteractions(callback, w)
+		return
+	} else if callback.Type == "view_submission" && callback.View.CallbackID == addOptionCallbackID {
+		mp.handleAddOptionSubmission(callback, w)
+
+		return
+	} else if callback.Type == "view_submission" && (callback.View.CallbackID == editPollCallbackID || callback.View.CallbackID == confirmEditCallbackID) {
+		mp.handlePollEditSubmission(callback, w)
+
 		return
 	} else if callback.Type == "view_submission" {
 		mp.handleInteractivePollSubmission(callback, w)
@@ -768,48 +1243,107 @@ func (mp *MarcoPoller) handlePollInteractions(callback InteractionCallback, w ht
 	} else if vote == closeButtonValue {
 		mp.handlePollClosure(poll, callback, w)
 		return
+	} else if vote == addOptionButtonValue {
+		mp.handleAddOptionRequest(poll, callback, w)
+		return
+	} else if vote == editButtonValue {
+		mp.handlePollEditRequest(poll, callback, w)
+		return
+	} else if vote == myVoteButtonValue {
+		mp.handleMyVoteRequest(poll, callback, w)
+		return
+	} else if vote == removeVoteButtonValue {
+		mp.handleRemoveVoteRequest(poll, callback, w)
+		return
+	} else if isSeeVotersValue(vote) {
+		mp.handleSeeVotersRequest(poll, callback, w)
+		return
 	}
 
-	// If poll supports multiple answers, read back the existing votes for the user and toggle the vote
-	if poll.Features.MultiAnswers {
-		userVotes, err := mp.storer.GetSiloString(poll.ID, callback.User.ID)
-
-		if err != nil && err != datastore.ErrNoSuchEntity {
-			log.Printf("Error getting existing votes for user [%s] on poll id [%s]: %v", callback.User.ID, pollID, err)
-			showErrorToUser(callback.ResponseURL, ":warning: Error loading existing votes. Please try again.")
-			return
-		}
-
-		vote = toggleVoteForValue(userVotes, vote)
+	if poll.Closed || poll.Features.isDue(actionTime(callback)) {
+		showErrorToUser(callback.ResponseURL, ":warning: Sorry, voting on this poll is closed")
+		return
 	}
 
-	err = mp.storer.PutSiloString(poll.ID, callback.User.ID, vote)
+	eligible, err := mp.checkEligibility(poll, callback.User.ID, callback.Channel.ID)
 	if err != nil {
-		log.Printf("Error storing vote [%s] for user [%s] for poll [%s]: %v", vote, callback.User.ID, poll.ID, err)
-		showErrorToUser(callback.ResponseURL, ":warning: Error persisting vote. Please try again.")
+		log.Printf("Error checking if user [%s] can vote on poll [%s]: %v", callback.User.ID, poll.ID, err)
+		showErrorToUser(callback.ResponseURL, ":warning: Error checking who can vote on this poll. Please try again.")
 		return
 	}
 
-	votes, err := mp.listVotes(poll.ID)
-	if err != nil {
-		log.Printf("Error listing votes for poll [%s]: %v", poll.ID, err)
-		showErrorToUser(callback.ResponseURL, ":warning: Error listing votes for poll. Please try again.")
+	if !eligible {
+		showErrorToUser(callback.ResponseURL, fmt.Sprintf(":warning: Sorry, you can't vote on this poll. %s.", formatEligibility(*poll.Features.Eligibility)))
 		return
 	}
 
-	resp, err := req.Post(callback.ResponseURL, req.BodyJSON(&UpdateMessage{ActionResponse: ActionResponse{Blocks: renderPoll(poll, votes, false), ReplaceOriginal: true}}))
-	if err != nil || resp.Response().StatusCode != 200 {
+	// If poll supports multiple answers or ranking, read back the existing votes for the user and toggle the vote. When
+	// the storer supports it, the votes are only replaced if they haven't changed since they were read (i.e. by quick
+	// successive clicks) and the toggle is retried otherwise
+	newUserVotes := vote
+	if poll.Features.MultiAnswers || poll.Features.RankedChoice {
+		for attempt := 1; ; attempt++ {
+			userVotes, err := mp.storer.GetSiloString(poll.ID, callback.User.ID)
+
+			if err != nil && !isNotFound(err) {
+				log.Printf("Error getting existing votes for user [%s] on poll id [%s]: %v", callback.User.ID, pollID, err)
+				showErrorToUser(callback.ResponseURL, ":warning: Error loading existing votes. Please try again.")
+				return
+			}
+
+			if poll.Features.RankedChoice {
+				newUserVotes, err = toggleRankForValue(userVotes, vote, poll.Features.MaxVotesPerUser)
+			} else {
+				newUserVotes, err = toggleVoteForValue(userVotes, vote, poll.Features.MaxVotesPerUser)
+			}
+
+			if err != nil {
+				showErrorToUser(callback.ResponseURL, fmt.Sprintf(":warning: %s. Remove one of your votes to vote for another option.", err.Error()))
+				return
+			}
+
+			swapped, err := mp.compareAndSwapVotes(poll.ID, callback.User.ID, userVotes, newUserVotes)
+			if err != nil {
+				log.Printf("Error storing vote [%s] for user [%s] for poll [%s]: %v", newUserVotes, callback.User.ID, poll.ID, err)
+				showErrorToUser(callback.ResponseURL, ":warning: Error persisting vote. Please try again.")
+				return
+			}
+
+			if swapped {
+				break
+			}
+
+			if attempt >= maxVoteAttempts {
+				log.Printf("Error storing vote [%s] for user [%s] for poll [%s]: votes changed concurrently on all %d attempts", newUserVotes, callback.User.ID, poll.ID, attempt)
+				showErrorToUser(callback.ResponseURL, ":warning: Error persisting vote. Please try again.")
+				return
+			}
+		}
+	} else {
+		err = mp.storer.PutSiloString(poll.ID, callback.User.ID, vote)
 		if err != nil {
-			log.Printf("Error updating poll [%s] message : %v", poll.ID, err)
-			showErrorToUser(callback.ResponseURL, ":warning: Error updating slack message for poll. Please try again.")
-		} else {
-			log.Printf("Error updating poll [%s] message : %s", poll.ID, resp.String())
-			showErrorToUser(callback.ResponseURL, ":warning: Error updating slack message for poll. Please try again.")
+			log.Printf("Error storing vote [%s] for user [%s] for poll [%s]: %v", vote, callback.User.ID, poll.ID, err)
+			showErrorToUser(callback.ResponseURL, ":warning: Error persisting vote. Please try again.")
+			return
 		}
+	}
 
+	votes, err := mp.listVotes(poll)
+	if err != nil {
+		log.Printf("Error listing votes for poll [%s]: %v", poll.ID, err)
+		showErrorToUser(callback.ResponseURL, ":warning: Error listing votes for poll. Please try again.")
 		return
 	}
 
+	err = mp.updatePollMessage(poll, callback.ResponseURL, renderPoll(poll, votes, false, mp.maxVoterAvatars))
+	if err != nil {
+		log.Printf("Error updating poll [%s] message : %v", poll.ID, err)
+		showErrorToUser(callback.ResponseURL, ":warning: Error updating slack message for poll. Please try again.")
+		return
+	}
+
+	showMessageToUser(callback.ResponseURL, formatVoteConfirmation(poll, newUserVotes))
+
 	ctx := context.Background()
 	mp.instruments.votingCount.Add(ctx, 1)
 }
@@ -829,17 +1363,56 @@ func (mp *MarcoPoller) handleInteractivePollSubmission(callback InteractionCallb
 	}
 
 	values := callback.View.State.Values
+	templateKey := callback.View.PrivateMetadata
 
-	question := values[pollQuestionInputBlockID][pollQuestionActionID].Value
-	rawOptions := values[pollOptionsInputBlockID][pollOptionsActionID].Value
-	rawSelectedOptions := values[pollFeaturesInputBlockID][pollFeaturesActionID].SelectedOptions
+	question := values[templateBlockID(pollQuestionInputBlockID, templateKey)][pollQuestionActionID].Value
+	rawOptions := values[templateBlockID(pollOptionsInputBlockID, templateKey)][pollOptionsActionID].Value
+	rawSelectedOptions := values[templateBlockID(pollFeaturesInputBlockID, templateKey)][pollFeaturesActionID].SelectedOptions
 
 	selectedOptionsAsMap := make(map[string]bool)
 	for _, o := range rawSelectedOptions {
 		selectedOptionsAsMap[o.Value] = true
 	}
 
-	multiAnswer := selectedOptionsAsMap[multiAnswerOptionID]
+	features := PollFeatures{MultiAnswers: selectedOptionsAsMap[multiAnswerOptionID], Anonymous: selectedOptionsAsMap[anonymousOptionID], RankedChoice: selectedOptionsAsMap[rankedChoiceOptionID], KeepResults: selectedOptionsAsMap[keepResultsOptionID], OpenOptions: selectedOptionsAsMap[openOptionsOptionID]}
+
+	if selectedOptionsAsMap[channelMembersOptionID] {
+		features.Eligibility = &Eligibility{ChannelMembers: true}
+	}
+
+	if selectedOptionsAsMap[hiddenResultsOptionID] {
+		features.HiddenResults = true
+	}
+
+	if selectedOptionsAsMap[barChartOptionID] {
+		features.RenderStyle = BarsRenderStyle
+	}
+
+	rawDeadline := strings.TrimSpace(values[templateBlockID(pollDeadlineInputBlockID, templateKey)][pollDeadlineActionID].Value)
+	if rawDeadline != "" {
+		deadline, err := parseDeadline(rawDeadline, time.Now())
+		if err != nil {
+			writeViewSubmissionResponse(w, slack.NewErrorsViewSubmissionResponse(map[string]string{templateBlockID(pollDeadlineInputBlockID, templateKey): err.Error()}))
+			return
+		}
+
+		features.Deadline = deadline.Unix()
+	}
+
+	rawMaxVotes := strings.TrimSpace(values[templateBlockID(pollMaxVotesInputBlockID, templateKey)][pollMaxVotesActionID].Value)
+	if rawMaxVotes != "" {
+		maxVotes, err := parseMaxVotes(rawMaxVotes)
+		if err == nil && !features.MultiAnswers && !features.RankedChoice {
+			err = fmt.Errorf("A vote limit can only be set on polls allowing votes for many options")
+		}
+
+		if err != nil {
+			writeViewSubmissionResponse(w, slack.NewErrorsViewSubmissionResponse(map[string]string{templateBlockID(pollMaxVotesInputBlockID, templateKey): err.Error()}))
+			return
+		}
+
+		features.MaxVotesPerUser = maxVotes
+	}
 
 	if len(callback.ResponseURLs) < 1 {
 		errMsg := "Invalid view submission missing response_urls"
@@ -847,15 +1420,24 @@ func (mp *MarcoPoller) handleInteractivePollSubmission(callback InteractionCallb
 		showErrorToUser(callback.ResponseURL, fmt.Sprintf(":warning: %s. Please report this at https://github.com/alexandre-normand/marcopoller.", errMsg))
 	}
 
-	pollOptions := strings.Split(rawOptions, "\n")
-	validOptions := make([]string, 0)
-	for _, o := range pollOptions {
+	if templateName := strings.TrimSpace(values[pollTemplateNameInputBlockID][pollTemplateNameActionID].Value); templateName != "" && mp.templateStorer != nil {
+		template := newPollTemplate(templateName, question, parseOptions(splitOptions(rawOptions)), features, rawDeadline, callback.User.ID)
+		mp.saveTemplate(template, callback.ResponseURLs[0].ResponseURL)
+	}
+
+	mp.createNewPoll(question, parseOptions(splitOptions(rawOptions)), callback.User.ID, features, callback.ResponseURLs[0].ChannelID, callback.ResponseURLs[0].ResponseURL, w)
+}
+
+// splitOptions splits the answer options entered in a dialog (one per line) and skips empty lines
+func splitOptions(rawOptions string) (options []string) {
+	options = make([]string, 0)
+	for _, o := range strings.Split(rawOptions, "\n") {
 		if o != "" {
-			validOptions = append(validOptions, o)
+			options = append(options, o)
 		}
 	}
 
-	mp.createNewPoll(question, validOptions, callback.User.ID, PollFeatures{MultiAnswers: multiAnswer}, callback.ResponseURLs[0].ResponseURL, w)
+	return options
 }
 
 // handlePollDeletion handles a request to delete a poll
@@ -876,16 +1458,10 @@ func (mp *MarcoPoller) handlePollDeletion(poll Poll, callback InteractionCallbac
 			return
 		}
 
-		resp, err := req.Post(callback.ResponseURL, req.BodyJSON(&DeleteMessage{DeleteOriginal: true}))
-		if err != nil || resp.Response().StatusCode != 200 {
-			if err != nil {
-				log.Printf("Error deleting message: %v", err)
-				showErrorToUser(callback.ResponseURL, ":warning: Error deleting message from slack")
-			} else {
-				log.Printf("Error deleting message: %s", resp.String())
-				showErrorToUser(callback.ResponseURL, ":warning: Error deleting message from slack")
-			}
-
+		err = mp.deletePollMessage(poll, callback.ResponseURL)
+		if err != nil {
+			log.Printf("Error deleting message: %v", err)
+			showErrorToUser(callback.ResponseURL, ":warning: Error deleting message from slack")
 			return
 		}
 
@@ -906,7 +1482,7 @@ func (mp *MarcoPoller) handlePollClosure(poll Poll, callback InteractionCallback
 	}
 
 	if poll.Creator == callback.User.ID {
-		votes, err := mp.listVotes(poll.ID)
+		votes, err := mp.listVotes(poll)
 		if err != nil {
 			log.Printf("Error listing votes for poll [%s]: %v", poll.ID, err)
 			showErrorToUser(callback.ResponseURL, ":warning: Error listing votes for poll. Please try again")
@@ -914,23 +1490,16 @@ func (mp *MarcoPoller) handlePollClosure(poll Poll, callback InteractionCallback
 		}
 
 		// Post the final poll update to slack
-		resp, err := req.Post(callback.ResponseURL, req.BodyJSON(&UpdateMessage{ActionResponse: ActionResponse{Blocks: renderPoll(poll, votes, true), ReplaceOriginal: true}}))
-		if err != nil || resp.Response().StatusCode != 200 {
-			if err != nil {
-				log.Printf("Error updating poll [%s] message : %v", poll.ID, err)
-				showErrorToUser(callback.ResponseURL, ":warning: Error updating poll message. Please try again")
-			} else {
-				log.Printf("Error updating poll [%s] message : %s", poll.ID, resp.String())
-				showErrorToUser(callback.ResponseURL, ":warning: Error updating poll message. Please try again")
-			}
-
+		err = mp.updatePollMessage(poll, callback.ResponseURL, renderPoll(poll, votes, true, mp.maxVoterAvatars))
+		if err != nil {
+			log.Printf("Error updating poll [%s] message : %v", poll.ID, err)
+			showErrorToUser(callback.ResponseURL, ":warning: Error updating poll message. Please try again")
 			return
 		}
 
-		// Delete poll and votes from storage
-		err = mp.deletePoll(pollID)
+		err = mp.closePoll(poll, time.Now())
 		if err != nil {
-			log.Printf("Error deleting poll [%s]: %s", pollID, err.Error())
+			log.Printf("Error closing poll [%s]: %s", pollID, err.Error())
 			return
 		}
 
@@ -941,8 +1510,44 @@ func (mp *MarcoPoller) handlePollClosure(poll Poll, callback InteractionCallback
 	return
 }
 
-// toggleVoteForValue toggles a vote from an existing delimited string of all of a user's votes
-func toggleVoteForValue(userVotes string, voteToToggle string) (newUserVotes string) {
+// closePoll archives the final results of a closed poll and removes the poll and its votes from storage unless the poll
+// keeps its results after closing. In that case, the poll is marked as closed and its data is kept (for exports) until it expires
+func (mp *MarcoPoller) closePoll(poll Poll, closedAt time.Time) (err error) {
+	values, err := mp.storer.ScanSilo(poll.ID)
+	if err != nil {
+		return err
+	}
+
+	err = mp.archivePoll(poll, values, closedAt)
+	if err != nil {
+		return errors.Wrapf(err, "Error archiving poll [%s]", poll.ID)
+	}
+
+	if !poll.Features.KeepResults {
+		return mp.deletePoll(poll.ID)
+	}
+
+	poll.Closed = true
+	encoded, err := encodePoll(poll)
+	if err != nil {
+		return err
+	}
+
+	return mp.storer.PutSiloString(poll.ID, pollInfoKey, encoded)
+}
+
+// writeViewSubmissionResponse writes a response action to a view submission. If there's an error
+// writing the response, we log the error but can't do anything more
+func writeViewSubmissionResponse(w http.ResponseWriter, response *slack.ViewSubmissionResponse) {
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("Error writing view submission response [%v]: %s", response, err.Error())
+	}
+}
+
+// toggleVoteForValue toggles a vote from an existing delimited string of all of a user's votes. If maxVotes is
+// greater than 0 and adding the vote would go over that limit, an error is returned
+func toggleVoteForValue(userVotes string, voteToToggle string, maxVotes int) (newUserVotes string, err error) {
 	voteMap := make(map[string]bool)
 
 	if userVotes != "" {
@@ -955,6 +1560,8 @@ func toggleVoteForValue(userVotes string, voteToToggle string) (newUserVotes str
 
 	if _, exists := voteMap[voteToToggle]; exists {
 		delete(voteMap, voteToToggle)
+	} else if maxVotes > 0 && len(voteMap) >= maxVotes {
+		return userVotes, newVoteLimitError(maxVotes)
 	} else {
 		voteMap[voteToToggle] = true
 	}
@@ -965,13 +1572,42 @@ func toggleVoteForValue(userVotes string, voteToToggle string) (newUserVotes str
 	}
 
 	sort.Strings(allVotes)
-	return strings.Join(allVotes, voteDelimiter)
+	return strings.Join(allVotes, voteDelimiter), nil
 }
 
-// listVotes returns the list of votes: a map of vote values for a poll ID to the array of voters. If an error occurs
-// getting the votes or the voter info, that error is returned.
-func (mp *MarcoPoller) listVotes(pollID string) (votes map[string][]Voter, err error) {
-	values, err := mp.storer.ScanSilo(pollID)
+// compareAndSwapVotes replaces a user's votes if they're still the votes read before the toggle. Storers that
+// can't compare-and-swap always replace the votes
+func (mp *MarcoPoller) compareAndSwapVotes(pollID string, userID string, userVotes string, newUserVotes string) (swapped bool, err error) {
+	if cas, ok := mp.storer.(CompareAndSwapper); ok {
+		return cas.CompareAndSwapSiloString(pollID, userID, userVotes, newUserVotes)
+	}
+
+	return true, mp.storer.PutSiloString(pollID, userID, newUserVotes)
+}
+
+// compareAndSwapPollInfo replaces a poll's info if it's still the info read before the change. Storers that can't
+// compare-and-swap always replace the info
+func (mp *MarcoPoller) compareAndSwapPollInfo(pollID string, encodedPoll string, newEncodedPoll string) (swapped bool, err error) {
+	if cas, ok := mp.storer.(CompareAndSwapper); ok {
+		return cas.CompareAndSwapSiloString(pollID, pollInfoKey, encodedPoll, newEncodedPoll)
+	}
+
+	return true, mp.storer.PutSiloString(pollID, pollInfoKey, newEncodedPoll)
+}
+
+// newVoteLimitError returns the error for a vote that would go over the limit of votes per user
+func newVoteLimitError(maxVotes int) (err error) {
+	if maxVotes == 1 {
+		return fmt.Errorf("You can only vote for 1 option on this poll")
+	}
+
+	return fmt.Errorf("You can only vote for up to %d options on this poll", maxVotes)
+}
+
+// listVotes returns the list of votes: a map of vote values for a poll to the array of voters along with the weight of
+// their votes. If an error occurs getting the votes or the voter info, that error is returned.
+func (mp *MarcoPoller) listVotes(poll Poll) (votes map[string][]Voter, err error) {
+	values, err := mp.storer.ScanSilo(poll.ID)
 	if err != nil {
 		return votes, err
 	}
@@ -984,6 +1620,11 @@ func (mp *MarcoPoller) listVotes(pollID string) (votes map[string][]Voter, err e
 		}
 	}
 
+	weights := make(map[string]float64)
+	if len(poll.Features.Weights) > 0 {
+		weights = mp.voterWeights(poll)
+	}
+
 	votes = make(map[string][]Voter)
 	for userID, userVoting := range voteValues {
 		user, err := mp.userFinder.GetUserInfo(userID)
@@ -992,12 +1633,17 @@ func (mp *MarcoPoller) listVotes(pollID string) (votes map[string][]Voter, err e
 		}
 
 		userVotes := strings.Split(userVoting, voteDelimiter)
-		for _, value := range userVotes {
+		for rank, value := range userVotes {
+			// A user that toggled off all of their votes has an empty value
+			if value == "" {
+				continue
+			}
+
 			if _, ok := votes[value]; !ok {
 				votes[value] = make([]Voter, 0)
 			}
 
-			voter := Voter{userID: userID, avatarURL: user.Profile.Image24, name: user.RealName}
+			voter := Voter{userID: userID, avatarURL: user.Profile.Image24, name: user.RealName, rank: rank, weight: weightOf(weights, userID)}
 
 			votes[value] = append(votes[value], voter)
 		}
@@ -1020,10 +1666,15 @@ func (mp *MarcoPoller) deletePoll(pollID string) (err error) {
 		}
 	}
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	return mp.forgetReminders(pollID)
 }
 
-// decodePoll decodes a poll from a encoded string value.
+// decodePoll decodes a poll from a encoded string value. Options of polls stored before options had more than a text
+// are decoded from plain strings (see PollOption.UnmarshalJSON)
 func decodePoll(encoded string) (poll Poll, err error) {
 	err = json.Unmarshal([]byte(encoded), &poll)
 
@@ -1052,15 +1703,18 @@ func actionTime(callback InteractionCallback) (actionTime time.Time) {
 	return slackTimestampToTime(timestamp)
 }
 
-// parsePollParams parses poll parameters. The expected format is: "Some question" "Option 1" "Option 2" "Option 3"
-func parsePollParams(rawPoll string) (interactiveReq bool, pollQuestion string, options []string, err error) {
+// parsePollParams parses poll parameters. The expected format is: "Some question" "Option 1" "Option 2" "Option 3". Unquoted
+// parameters starting with -- are treated as poll flags (i.e. --anonymous) and set the matching poll features. Relative
+// flag values (like a deadline duration) are relative to the given current time
+func parsePollParams(rawPoll string, now time.Time) (interactiveReq bool, pollQuestion string, options []string, features PollFeatures, err error) {
 	inQuote := false
 	params := make([]string, 0)
+	flags := make([]string, 0)
 	var strBuilder strings.Builder
 
 	// If no parameters provided, this means it's going to be a request for an interactive poll dialog
 	if len(strings.TrimSpace(rawPoll)) == 0 {
-		return true, "", nil, nil
+		return true, "", nil, features, nil
 	}
 
 	// Sacrifice some fidelity for convenience by normalizing smart quotes to standard quotes before parsing so that people
@@ -1084,7 +1738,9 @@ func parsePollParams(rawPoll string) (interactiveReq bool, pollQuestion string,
 		case unicode.IsSpace(r) && !inQuote:
 			{
 				param := strBuilder.String()
-				if len(param) > 0 {
+				if strings.HasPrefix(param, flagPrefix) {
+					flags = append(flags, param)
+				} else if len(param) > 0 {
 					params = append(params, param)
 				}
 
@@ -1098,20 +1754,143 @@ func parsePollParams(rawPoll string) (interactiveReq bool, pollQuestion string,
 	}
 
 	param := strBuilder.String()
-	if len(param) > 0 {
+	if strings.HasPrefix(param, flagPrefix) && !inQuote {
+		flags = append(flags, param)
+	} else if len(param) > 0 {
 		params = append(params, param)
 	}
 
+	features, err = parsePollFlags(flags, now)
+	if err != nil {
+		return false, "", nil, features, err
+	}
+
 	if len(params) < 2 {
-		return false, "", nil, fmt.Errorf("Missing parameters in string [%s]", rawPoll)
+		return false, "", nil, features, fmt.Errorf("Missing parameters in string [%s]", rawPoll)
+	}
+
+	return false, params[0], params[1:], features, nil
+}
+
+// parsePollFlags returns the poll features enabled by the given flags. Flags with values are
+// formatted as --flag=value. An error is returned if any of the flags is unknown or has an invalid value
+func parsePollFlags(flags []string, now time.Time) (features PollFeatures, err error) {
+	for _, rawFlag := range flags {
+		flag, value := rawFlag, ""
+		if i := strings.Index(rawFlag, flagValueDelimiter); i != -1 {
+			flag, value = rawFlag[:i], rawFlag[i+1:]
+		}
+
+		switch flag {
+		case anonymousFlag:
+			features.Anonymous = true
+		case rankedChoiceFlag:
+			features.RankedChoice = true
+		case keepResultsFlag:
+			features.KeepResults = true
+		case openOptionsFlag:
+			features.OpenOptions = true
+		case hiddenResultsFlag:
+			features.HiddenResults = true
+		case deadlineFlag:
+			deadline, err := parseDeadline(value, now)
+			if err != nil {
+				return features, errors.Wrapf(err, "Invalid value for flag [%s]", flag)
+			}
+
+			features.Deadline = deadline.Unix()
+		case votersFlag:
+			voters, err := parseVoters(value)
+			if err != nil {
+				return features, errors.Wrapf(err, "Invalid value for flag [%s]", flag)
+			}
+
+			if features.Eligibility == nil {
+				features.Eligibility = &Eligibility{}
+			}
+
+			features.Eligibility.Voters = append(features.Eligibility.Voters, voters...)
+		case channelMembersFlag:
+			if features.Eligibility == nil {
+				features.Eligibility = &Eligibility{}
+			}
+
+			features.Eligibility.ChannelMembers = true
+		case remindFlag:
+			reminders, err := parseReminders(value)
+			if err != nil {
+				return features, errors.Wrapf(err, "Invalid value for flag [%s]", flag)
+			}
+
+			features.Reminders = reminders
+		case renderStyleFlag:
+			style, err := parseRenderStyle(value)
+			if err != nil {
+				return features, errors.Wrapf(err, "Invalid value for flag [%s]", flag)
+			}
+
+			features.RenderStyle = style
+		case weightFlag:
+			id, weight, err := parseWeight(value)
+			if err != nil {
+				return features, errors.Wrapf(err, "Invalid value for flag [%s]", flag)
+			}
+
+			if features.Weights == nil {
+				features.Weights = make(map[string]float64)
+			}
+
+			features.Weights[id] = weight
+		default:
+			return features, fmt.Errorf("Unknown flag [%s]", rawFlag)
+		}
+	}
+
+	if features.RankedChoice && len(features.Weights) > 0 {
+		return features, fmt.Errorf("Weights can't be used on ranked choice polls")
+	}
+
+	// Weighted scores along with the list of weighted users would reveal how weighted users voted
+	if features.Anonymous && len(features.Weights) > 0 {
+		return features, fmt.Errorf("Weights can't be used on anonymous polls")
+	}
+
+	if len(features.Reminders) > 0 && features.Deadline == 0 {
+		return features, fmt.Errorf("Reminders need a deadline (i.e. --deadline=2h --remind=1h)")
+	}
+
+	return features, nil
+}
+
+// parseDeadline parses a deadline expressed either as a duration relative to now (i.e. 2h) or
+// as an absolute RFC3339 time (i.e. 2020-10-20T15:00:00-07:00). The deadline must be in the future
+func parseDeadline(rawDeadline string, now time.Time) (deadline time.Time, err error) {
+	if d, err := time.ParseDuration(rawDeadline); err == nil {
+		deadline = now.Add(d)
+	} else if deadline, err = time.Parse(time.RFC3339, rawDeadline); err != nil {
+		return deadline, fmt.Errorf("Invalid deadline [%s], expected a duration (i.e. 2h) or a time (i.e. 2020-10-20T15:00:00-07:00)", rawDeadline)
+	}
+
+	if !deadline.After(now) {
+		return deadline, fmt.Errorf("Deadline [%s] is not in the future", rawDeadline)
+	}
+
+	return deadline, nil
+}
+
+// parseMaxVotes parses the maximum number of votes per user which must be a positive number
+func parseMaxVotes(rawMaxVotes string) (maxVotes int, err error) {
+	maxVotes, err = strconv.Atoi(rawMaxVotes)
+	if err != nil || maxVotes < 1 {
+		return 0, fmt.Errorf("Invalid vote limit [%s], expected a positive number (i.e. 3)", rawMaxVotes)
 	}
 
-	return false, params[0], params[1:], nil
+	return maxVotes, nil
 }
 
 // normalizePollRequest applies a few operation to normalize a polling request prior to parsing:
-//  * Replace opening curly quotes by the standard quote character
-//  * Replace closing curly quotes by the standard quote character
+//   - Replace opening curly quotes by the standard quote character
+//   - Replace closing curly quotes by the standard quote character
 func normalizePollRequest(rawRequest string) (normalizedReq string) {
 	normalizedPoll := rawRequest
 	normalizedPoll = strings.Replace(normalizedPoll, "“", "\"", -1)
@@ -1128,25 +1907,141 @@ func (mp *MarcoPoller) debugf(format string, v ...interface{}) {
 }
 
 // DeleteExpiredPolls removes all poll data (content and associated votes) without deleting
-// the slack message holding the most recent snapshot of the poll. The deletionTime should
+// the slack message holding the most recent snapshot of the poll. The results of expired polls that
+// weren't closed are archived first. Polls that can't be archived or deleted are skipped (and retried on the
+// next run) and their errors are returned together once all other expired polls are deleted. The deletionTime should
 // be the current time except for synthetic scenarios like tests
 func (mp *MarcoPoller) DeleteExpiredPolls(deletionTime time.Time) (count int, err error) {
 	count = 0
-	polls, err := mp.storer.GlobalScan()
+	entries, err := mp.storer.GlobalScan()
 	if err != nil {
 		return 0, err
 	}
 
-	for pollID := range polls {
-		if mp.pollVerifier.Verify(pollID, deletionTime) != nil {
-			err := mp.deletePoll(pollID)
+	errs := make([]error, 0)
+
+	for teamID, polls := range mp.pollsByTeam(entries) {
+		tp := mp.forTeamStorage(teamID)
+
+		for pollID, values := range polls {
+			if mp.pollVerifier.Verify(pollID, deletionTime) != nil {
+				err := tp.archiveExpiredPoll(pollID, values, deletionTime)
+				if err != nil {
+					log.Printf("Error archiving expired poll [%s]: %v", pollID, err)
+					errs = append(errs, err)
+					continue
+				}
+
+				err = tp.deletePoll(pollID)
+				if err != nil {
+					log.Printf("Error deleting expired poll [%s]: %v", pollID, err)
+					errs = append(errs, errors.Wrapf(err, "Error deleting poll [%s]", pollID))
+					continue
+				}
+
+				count++
+			}
+		}
+	}
+
+	return count, combineErrors(errs)
+}
+
+// CloseDuePolls closes all polls with a deadline at or before closingTime. Closing a poll posts its
+// final state to slack and removes all poll data (content and associated votes) unless the poll keeps
+// its results after closing. Polls that can't be closed are skipped and their errors are returned
+// together once all other due polls are closed. The closingTime should
+// be the current time except for synthetic scenarios like tests
+func (mp *MarcoPoller) CloseDuePolls(closingTime time.Time) (count int, err error) {
+	count = 0
+	entries, err := mp.storer.GlobalScan()
+	if err != nil {
+		return 0, err
+	}
+
+	errs := make([]error, 0)
+
+	for teamID, polls := range mp.pollsByTeam(entries) {
+		tp := mp
+		if teamID != "" {
+			tp, err = mp.forTeam(teamID)
 			if err != nil {
-				return count, err
+				// Due polls are still closed, falling back on their response url to update slack
+				log.Printf("Error loading team [%s]: %v", teamID, err)
+				tp = mp.forTeamStorage(teamID)
 			}
+		}
+
+		closed, err := tp.closeDuePolls(polls, closingTime)
+		count += closed
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return count, combineErrors(errs)
+}
 
-			count++
+// closeDuePolls closes the polls with a deadline at or before closingTime given the polls' stored values keyed by poll ID.
+// A poll that can't be closed doesn't stop the others from being closed
+func (mp *MarcoPoller) closeDuePolls(polls map[string]map[string]string, closingTime time.Time) (count int, err error) {
+	errs := make([]error, 0)
+	for pollID, values := range polls {
+		encodedPoll, ok := values[pollInfoKey]
+		if !ok {
+			continue
 		}
+
+		poll, err := decodePoll(encodedPoll)
+		if err != nil {
+			log.Printf("Error decoding poll [%s]: %v", pollID, err)
+			errs = append(errs, errors.Wrapf(err, "Error decoding poll [%s]", pollID))
+			continue
+		}
+
+		if poll.Closed || !poll.Features.isDue(closingTime) {
+			continue
+		}
+
+		votes, err := mp.listVotes(poll)
+		if err != nil {
+			log.Printf("Error listing votes for poll [%s]: %v", pollID, err)
+			errs = append(errs, errors.Wrapf(err, "Error listing votes for poll [%s]", pollID))
+			continue
+		}
+
+		// The deadline is authoritative so the poll data is deleted even if slack can't be updated
+		err = mp.updatePollMessage(poll, poll.ResponseURL, renderPoll(poll, votes, true, mp.maxVoterAvatars))
+		if err != nil {
+			log.Printf("Error updating poll [%s] message : %v", pollID, err)
+		}
+
+		err = mp.closePoll(poll, closingTime)
+		if err != nil {
+			log.Printf("Error closing poll [%s]: %v", pollID, err)
+			errs = append(errs, err)
+			continue
+		}
+
+		count++
+	}
+
+	return count, combineErrors(errs)
+}
+
+// combineErrors combines errors into a single error. It returns nil when there are no errors
+func combineErrors(errs []error) (err error) {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+
+	msgs := make([]string, 0, len(errs))
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
 	}
 
-	return count, nil
+	return fmt.Errorf("%d errors: %s", len(errs), strings.Join(msgs, "; "))
 }
@@ -2,31 +2,33 @@ package marcopoller
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestRenderPollNoVotes(t *testing.T) {
-	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []string{"Ishmael", "Story of B", "My Ishmael", "Paradise Built in Hell"}, Creator: "marco"}
-	blocks := renderPoll(poll, map[string][]Voter{}, false)
+	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []PollOption{{Text: "Ishmael"}, {Text: "Story of B"}, {Text: "My Ishmael"}, {Text: "Paradise Built in Hell"}}, Creator: "marco"}
+	blocks := renderPoll(poll, map[string][]Voter{}, false, defaultMaxVoterAvatars)
 	render, err := json.Marshal(blocks)
 	require.NoError(t, err)
 
-	assert.Equal(t, "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*What's your favorite book?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"0\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Story of B\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"1\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • My Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"2\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Paradise Built in Hell\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"3\",\"style\":\"primary\"}},{\"type\":\"actions\",\"block_id\":\"un\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Close voting\"},\"action_id\":\"un,close\",\"value\":\"close\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Delete poll\"},\"action_id\":\"un,delete\",\"value\":\"delete\",\"style\":\"danger\"}]},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e\"}]}]", string(render))
+	assert.Equal(t, "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*What's your favorite book?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"0\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Story of B\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"1\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • My Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"2\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Paradise Built in Hell\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"3\",\"style\":\"primary\"}},{\"type\":\"actions\",\"block_id\":\"un\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Remove my vote\"},\"action_id\":\"un,removevote\",\"value\":\"removevote\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Edit\"},\"action_id\":\"un,edit\",\"value\":\"edit\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Close voting\"},\"action_id\":\"un,close\",\"value\":\"close\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Delete poll\"},\"action_id\":\"un,delete\",\"value\":\"delete\",\"style\":\"danger\"}]},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e\"}]}]", string(render))
 }
 
 func TestRenderPollOneVote(t *testing.T) {
-	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []string{"Ishmael", "Story of B", "My Ishmael", "Paradise Built in Hell"}, Creator: "marco"}
-	blocks := renderPoll(poll, map[string][]Voter{"0": []Voter{Voter{userID: "marco", avatarURL: "https://avatar.me", name: "Marco Poller"}}}, false)
+	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []PollOption{{Text: "Ishmael"}, {Text: "Story of B"}, {Text: "My Ishmael"}, {Text: "Paradise Built in Hell"}}, Creator: "marco"}
+	blocks := renderPoll(poll, map[string][]Voter{"0": []Voter{Voter{userID: "marco", avatarURL: "https://avatar.me", name: "Marco Poller"}}}, false, defaultMaxVoterAvatars)
 	render, err := json.Marshal(blocks)
 	require.NoError(t, err)
 
-	assert.Equal(t, "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*What's your favorite book?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"0\",\"style\":\"primary\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar.me\",\"alt_text\":\"Marco Poller\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Story of B\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"1\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • My Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"2\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Paradise Built in Hell\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"3\",\"style\":\"primary\"}},{\"type\":\"actions\",\"block_id\":\"un\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Close voting\"},\"action_id\":\"un,close\",\"value\":\"close\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Delete poll\"},\"action_id\":\"un,delete\",\"value\":\"delete\",\"style\":\"danger\"}]},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e\"}]}]", string(render))
+	assert.Equal(t, "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*What's your favorite book?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"0\",\"style\":\"primary\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar.me\",\"alt_text\":\"Marco Poller\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Story of B\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"1\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • My Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"2\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Paradise Built in Hell\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"3\",\"style\":\"primary\"}},{\"type\":\"actions\",\"block_id\":\"un\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Remove my vote\"},\"action_id\":\"un,removevote\",\"value\":\"removevote\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Edit\"},\"action_id\":\"un,edit\",\"value\":\"edit\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Close voting\"},\"action_id\":\"un,close\",\"value\":\"close\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Delete poll\"},\"action_id\":\"un,delete\",\"value\":\"delete\",\"style\":\"danger\"}]},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e\"}]}]", string(render))
 }
 
 func TestRenderPollElevenVoters(t *testing.T) {
-	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []string{"Ishmael", "Story of B", "My Ishmael", "Paradise Built in Hell"}, Creator: "marco"}
+	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []PollOption{{Text: "Ishmael"}, {Text: "Story of B"}, {Text: "My Ishmael"}, {Text: "Paradise Built in Hell"}}, Creator: "marco"}
 	blocks := renderPoll(poll, map[string][]Voter{"0": []Voter{Voter{userID: "user1", avatarURL: "https://avatar1.me", name: "User1"},
 		Voter{userID: "user2", avatarURL: "https://avatar2.me", name: "User2"},
 		Voter{userID: "user3", avatarURL: "https://avatar3.me", name: "User3"},
@@ -38,16 +40,16 @@ func TestRenderPollElevenVoters(t *testing.T) {
 		Voter{userID: "user9", avatarURL: "https://avatar9.me", name: "User9"},
 		Voter{userID: "user10", avatarURL: "https://avatar10.me", name: "User10"},
 		Voter{userID: "user11", avatarURL: "https://avatar11.me", name: "User11"},
-	}}, false)
+	}}, false, defaultMaxVoterAvatars)
 
 	render, err := json.Marshal(blocks)
 	require.NoError(t, err)
 
-	assert.Equal(t, "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*What's your favorite book?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"0\",\"style\":\"primary\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar1.me\",\"alt_text\":\"User1\"},{\"type\":\"image\",\"image_url\":\"https://avatar2.me\",\"alt_text\":\"User2\"},{\"type\":\"image\",\"image_url\":\"https://avatar3.me\",\"alt_text\":\"User3\"},{\"type\":\"image\",\"image_url\":\"https://avatar4.me\",\"alt_text\":\"User4\"},{\"type\":\"image\",\"image_url\":\"https://avatar5.me\",\"alt_text\":\"User5\"},{\"type\":\"image\",\"image_url\":\"https://avatar6.me\",\"alt_text\":\"User6\"},{\"type\":\"image\",\"image_url\":\"https://avatar7.me\",\"alt_text\":\"User7\"},{\"type\":\"image\",\"image_url\":\"https://avatar8.me\",\"alt_text\":\"User8\"},{\"type\":\"image\",\"image_url\":\"https://avatar9.me\",\"alt_text\":\"User9\"},{\"type\":\"mrkdwn\",\"text\":\"`+ 2`\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Story of B\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"1\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • My Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"2\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Paradise Built in Hell\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"3\",\"style\":\"primary\"}},{\"type\":\"actions\",\"block_id\":\"un\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Close voting\"},\"action_id\":\"un,close\",\"value\":\"close\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Delete poll\"},\"action_id\":\"un,delete\",\"value\":\"delete\",\"style\":\"danger\"}]},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e\"}]}]", string(render))
+	assert.Equal(t, "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*What's your favorite book?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"0\",\"style\":\"primary\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar1.me\",\"alt_text\":\"User1\"},{\"type\":\"image\",\"image_url\":\"https://avatar2.me\",\"alt_text\":\"User2\"},{\"type\":\"image\",\"image_url\":\"https://avatar3.me\",\"alt_text\":\"User3\"},{\"type\":\"image\",\"image_url\":\"https://avatar4.me\",\"alt_text\":\"User4\"},{\"type\":\"image\",\"image_url\":\"https://avatar5.me\",\"alt_text\":\"User5\"},{\"type\":\"image\",\"image_url\":\"https://avatar6.me\",\"alt_text\":\"User6\"},{\"type\":\"image\",\"image_url\":\"https://avatar7.me\",\"alt_text\":\"User7\"},{\"type\":\"image\",\"image_url\":\"https://avatar8.me\",\"alt_text\":\"User8\"},{\"type\":\"image\",\"image_url\":\"https://avatar9.me\",\"alt_text\":\"User9\"},{\"type\":\"mrkdwn\",\"text\":\"`+ 2`\"}]},{\"type\":\"actions\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"See all 11 voters\"},\"action_id\":\"un,seevoters\",\"value\":\"seevoters:0\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Story of B\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"1\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • My Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"2\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Paradise Built in Hell\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"3\",\"style\":\"primary\"}},{\"type\":\"actions\",\"block_id\":\"un\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Remove my vote\"},\"action_id\":\"un,removevote\",\"value\":\"removevote\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Edit\"},\"action_id\":\"un,edit\",\"value\":\"edit\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Close voting\"},\"action_id\":\"un,close\",\"value\":\"close\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Delete poll\"},\"action_id\":\"un,delete\",\"value\":\"delete\",\"style\":\"danger\"}]},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e\"}]}]", string(render))
 }
 
 func TestRenderPollTenVoters(t *testing.T) {
-	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []string{"Ishmael", "Story of B", "My Ishmael", "Paradise Built in Hell"}, Creator: "marco"}
+	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []PollOption{{Text: "Ishmael"}, {Text: "Story of B"}, {Text: "My Ishmael"}, {Text: "Paradise Built in Hell"}}, Creator: "marco"}
 	blocks := renderPoll(poll, map[string][]Voter{"0": []Voter{Voter{userID: "user1", avatarURL: "https://avatar1.me", name: "User1"},
 		Voter{userID: "user2", avatarURL: "https://avatar2.me", name: "User2"},
 		Voter{userID: "user3", avatarURL: "https://avatar3.me", name: "User3"},
@@ -58,16 +60,16 @@ func TestRenderPollTenVoters(t *testing.T) {
 		Voter{userID: "user8", avatarURL: "https://avatar8.me", name: "User8"},
 		Voter{userID: "user9", avatarURL: "https://avatar9.me", name: "User9"},
 		Voter{userID: "user10", avatarURL: "https://avatar10.me", name: "User10"},
-	}}, false)
+	}}, false, defaultMaxVoterAvatars)
 
 	render, err := json.Marshal(blocks)
 	require.NoError(t, err)
 
-	assert.Equal(t, "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*What's your favorite book?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"0\",\"style\":\"primary\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar1.me\",\"alt_text\":\"User1\"},{\"type\":\"image\",\"image_url\":\"https://avatar2.me\",\"alt_text\":\"User2\"},{\"type\":\"image\",\"image_url\":\"https://avatar3.me\",\"alt_text\":\"User3\"},{\"type\":\"image\",\"image_url\":\"https://avatar4.me\",\"alt_text\":\"User4\"},{\"type\":\"image\",\"image_url\":\"https://avatar5.me\",\"alt_text\":\"User5\"},{\"type\":\"image\",\"image_url\":\"https://avatar6.me\",\"alt_text\":\"User6\"},{\"type\":\"image\",\"image_url\":\"https://avatar7.me\",\"alt_text\":\"User7\"},{\"type\":\"image\",\"image_url\":\"https://avatar8.me\",\"alt_text\":\"User8\"},{\"type\":\"image\",\"image_url\":\"https://avatar9.me\",\"alt_text\":\"User9\"},{\"type\":\"mrkdwn\",\"text\":\"`+ 1`\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Story of B\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"1\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • My Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"2\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Paradise Built in Hell\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"3\",\"style\":\"primary\"}},{\"type\":\"actions\",\"block_id\":\"un\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Close voting\"},\"action_id\":\"un,close\",\"value\":\"close\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Delete poll\"},\"action_id\":\"un,delete\",\"value\":\"delete\",\"style\":\"danger\"}]},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e\"}]}]", string(render))
+	assert.Equal(t, "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*What's your favorite book?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"0\",\"style\":\"primary\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar1.me\",\"alt_text\":\"User1\"},{\"type\":\"image\",\"image_url\":\"https://avatar2.me\",\"alt_text\":\"User2\"},{\"type\":\"image\",\"image_url\":\"https://avatar3.me\",\"alt_text\":\"User3\"},{\"type\":\"image\",\"image_url\":\"https://avatar4.me\",\"alt_text\":\"User4\"},{\"type\":\"image\",\"image_url\":\"https://avatar5.me\",\"alt_text\":\"User5\"},{\"type\":\"image\",\"image_url\":\"https://avatar6.me\",\"alt_text\":\"User6\"},{\"type\":\"image\",\"image_url\":\"https://avatar7.me\",\"alt_text\":\"User7\"},{\"type\":\"image\",\"image_url\":\"https://avatar8.me\",\"alt_text\":\"User8\"},{\"type\":\"image\",\"image_url\":\"https://avatar9.me\",\"alt_text\":\"User9\"},{\"type\":\"mrkdwn\",\"text\":\"`+ 1`\"}]},{\"type\":\"actions\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"See all 10 voters\"},\"action_id\":\"un,seevoters\",\"value\":\"seevoters:0\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Story of B\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"1\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • My Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"2\",\"style\":\"primary\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Paradise Built in Hell\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"3\",\"style\":\"primary\"}},{\"type\":\"actions\",\"block_id\":\"un\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Remove my vote\"},\"action_id\":\"un,removevote\",\"value\":\"removevote\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Edit\"},\"action_id\":\"un,edit\",\"value\":\"edit\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Close voting\"},\"action_id\":\"un,close\",\"value\":\"close\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Delete poll\"},\"action_id\":\"un,delete\",\"value\":\"delete\",\"style\":\"danger\"}]},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e\"}]}]", string(render))
 }
 
 func TestRenderClosedPoll(t *testing.T) {
-	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []string{"Ishmael", "Story of B", "My Ishmael", "Paradise Built in Hell"}, Creator: "marco"}
+	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []PollOption{{Text: "Ishmael"}, {Text: "Story of B"}, {Text: "My Ishmael"}, {Text: "Paradise Built in Hell"}}, Creator: "marco"}
 	blocks := renderPoll(poll, map[string][]Voter{"0": []Voter{Voter{userID: "user1", avatarURL: "https://avatar1.me", name: "User1"},
 		Voter{userID: "user2", avatarURL: "https://avatar2.me", name: "User2"},
 		Voter{userID: "user3", avatarURL: "https://avatar3.me", name: "User3"},
@@ -79,7 +81,7 @@ func TestRenderClosedPoll(t *testing.T) {
 		Voter{userID: "user9", avatarURL: "https://avatar9.me", name: "User9"},
 		Voter{userID: "user10", avatarURL: "https://avatar10.me", name: "User10"},
 		Voter{userID: "user11", avatarURL: "https://avatar11.me", name: "User11"},
-	}}, true)
+	}}, true, defaultMaxVoterAvatars)
 
 	render, err := json.Marshal(blocks)
 	require.NoError(t, err)
@@ -88,7 +90,7 @@ func TestRenderClosedPoll(t *testing.T) {
 }
 
 func TestRenderClosedPollWithMultiVoting(t *testing.T) {
-	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []string{"Ishmael", "Story of B", "My Ishmael", "Paradise Built in Hell"}, Creator: "marco"}
+	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []PollOption{{Text: "Ishmael"}, {Text: "Story of B"}, {Text: "My Ishmael"}, {Text: "Paradise Built in Hell"}}, Creator: "marco"}
 	blocks := renderPoll(poll, map[string][]Voter{"0": []Voter{Voter{userID: "user1", avatarURL: "https://avatar1.me", name: "User1"},
 		Voter{userID: "user2", avatarURL: "https://avatar2.me", name: "User2"},
 		Voter{userID: "user3", avatarURL: "https://avatar3.me", name: "User3"},
@@ -105,7 +107,7 @@ func TestRenderClosedPollWithMultiVoting(t *testing.T) {
 			Voter{userID: "user2", avatarURL: "https://avatar2.me", name: "User2"},
 			Voter{userID: "user3", avatarURL: "https://avatar3.me", name: "User3"},
 			Voter{userID: "user4", avatarURL: "https://avatar4.me", name: "User4"},
-		}}, true)
+		}}, true, defaultMaxVoterAvatars)
 
 	render, err := json.Marshal(blocks)
 	require.NoError(t, err)
@@ -113,6 +115,41 @@ func TestRenderClosedPollWithMultiVoting(t *testing.T) {
 	assert.Equal(t, "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*What's your favorite book?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Ishmael\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar1.me\",\"alt_text\":\"User1\"},{\"type\":\"image\",\"image_url\":\"https://avatar2.me\",\"alt_text\":\"User2\"},{\"type\":\"image\",\"image_url\":\"https://avatar3.me\",\"alt_text\":\"User3\"},{\"type\":\"image\",\"image_url\":\"https://avatar4.me\",\"alt_text\":\"User4\"},{\"type\":\"image\",\"image_url\":\"https://avatar5.me\",\"alt_text\":\"User5\"},{\"type\":\"image\",\"image_url\":\"https://avatar6.me\",\"alt_text\":\"User6\"},{\"type\":\"image\",\"image_url\":\"https://avatar7.me\",\"alt_text\":\"User7\"},{\"type\":\"image\",\"image_url\":\"https://avatar8.me\",\"alt_text\":\"User8\"},{\"type\":\"image\",\"image_url\":\"https://avatar9.me\",\"alt_text\":\"User9\"},{\"type\":\"mrkdwn\",\"text\":\"`+ 2`\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Story of B\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar1.me\",\"alt_text\":\"User1\"},{\"type\":\"image\",\"image_url\":\"https://avatar2.me\",\"alt_text\":\"User2\"},{\"type\":\"image\",\"image_url\":\"https://avatar3.me\",\"alt_text\":\"User3\"},{\"type\":\"image\",\"image_url\":\"https://avatar4.me\",\"alt_text\":\"User4\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • My Ishmael\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Paradise Built in Hell\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e (voting closed)\"}]}]", string(render))
 }
 
+func TestRenderAnonymousPoll(t *testing.T) {
+	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []PollOption{{Text: "Ishmael"}, {Text: "Story of B"}}, Creator: "marco", Features: PollFeatures{Anonymous: true}}
+	blocks := renderPoll(poll, map[string][]Voter{"0": []Voter{Voter{userID: "user1", avatarURL: "https://avatar1.me", name: "User1"},
+		Voter{userID: "user2", avatarURL: "https://avatar2.me", name: "User2"},
+	},
+		"1": []Voter{Voter{userID: "user3", avatarURL: "https://avatar3.me", name: "User3"}}}, false, defaultMaxVoterAvatars)
+
+	render, err := json.Marshal(blocks)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*What's your favorite book?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Ishmael\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"0\",\"style\":\"primary\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"`2 votes`\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Story of B\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"un,vote\",\"value\":\"1\",\"style\":\"primary\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"`1 vote`\"}]},{\"type\":\"actions\",\"block_id\":\"un\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Remove my vote\"},\"action_id\":\"un,removevote\",\"value\":\"removevote\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Edit\"},\"action_id\":\"un,edit\",\"value\":\"edit\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Close voting\"},\"action_id\":\"un,close\",\"value\":\"close\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Delete poll\"},\"action_id\":\"un,delete\",\"value\":\"delete\",\"style\":\"danger\"}]},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e\"}]}]", string(render))
+}
+
+func TestRenderClosedAnonymousPoll(t *testing.T) {
+	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []PollOption{{Text: "Ishmael"}, {Text: "Story of B"}}, Creator: "marco", Features: PollFeatures{Anonymous: true}}
+	blocks := renderPoll(poll, map[string][]Voter{"0": []Voter{Voter{userID: "marco", avatarURL: "https://avatar1.me", name: "Marco"}}}, true, defaultMaxVoterAvatars)
+
+	render, err := json.Marshal(blocks)
+	require.NoError(t, err)
+
+	assert.NotContains(t, string(render), "avatar1.me")
+	assert.NotContains(t, string(render), "Marco\"")
+	assert.Equal(t, "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*What's your favorite book?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Ishmael\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"`1 vote`\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Story of B\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e (voting closed)\"}]}]", string(render))
+}
+
+func TestRenderPollWithDeadline(t *testing.T) {
+	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []PollOption{{Text: "Ishmael"}}, Creator: "marco", Features: PollFeatures{Deadline: 1566579600}}
+	blocks := renderPoll(poll, map[string][]Voter{}, false, defaultMaxVoterAvatars)
+
+	render, err := json.Marshal(blocks)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(render), "{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e (voting closes \\u003c!date^1566579600^{date_short_pretty} at {time}|Fri, 23 Aug 2019 17:00:00 UTC\\u003e)\"}]}")
+}
+
 func TestParsePollParams(t *testing.T) {
 	testCases := []struct {
 		text     string
@@ -126,7 +163,7 @@ func TestParsePollParams(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		_, question, options, err := parsePollParams(tc.text)
+		_, question, options, _, err := parsePollParams(tc.text, time.Now())
 		require.NoError(t, err)
 
 		assert.Equal(t, tc.question, question)
@@ -135,17 +172,92 @@ func TestParsePollParams(t *testing.T) {
 }
 
 func TestParsePollMissingParams(t *testing.T) {
-	_, _, _, err := parsePollParams("\"Question but no options?\"")
+	_, _, _, _, err := parsePollParams("\"Question but no options?\"", time.Now())
 	assert.EqualError(t, err, "Missing parameters in string [\"Question but no options?\"]")
 }
 
+func TestParsePollParamsWithFlags(t *testing.T) {
+	testCases := []struct {
+		text     string
+		question string
+		options  []string
+		features PollFeatures
+	}{
+		{"\"Favorite thing?\" \"Reading\" \"Running\"", "Favorite thing?", []string{"Reading", "Running"}, PollFeatures{}},
+		{"--anonymous \"Favorite thing?\" \"Reading\" \"Running\"", "Favorite thing?", []string{"Reading", "Running"}, PollFeatures{Anonymous: true}},
+		{"\"Favorite thing?\" \"Reading\" \"Running\" --anonymous", "Favorite thing?", []string{"Reading", "Running"}, PollFeatures{Anonymous: true}},
+		{"\"Favorite thing?\" \"--anonymous\" \"Running\"", "Favorite thing?", []string{"--anonymous", "Running"}, PollFeatures{}},
+		{"--ranked --anonymous \"Favorite thing?\" \"Reading\" \"Running\"", "Favorite thing?", []string{"Reading", "Running"}, PollFeatures{Anonymous: true, RankedChoice: true}},
+		{"\"Favorite thing?\" \"Reading\" \"Running\" --keep-results", "Favorite thing?", []string{"Reading", "Running"}, PollFeatures{KeepResults: true}},
+		{"--open \"Lunch?\" \"Pizza\" \"Tacos\"", "Lunch?", []string{"Pizza", "Tacos"}, PollFeatures{OpenOptions: true}},
+		{"--hidden-results \"Lunch?\" \"Pizza\" \"Tacos\"", "Lunch?", []string{"Pizza", "Tacos"}, PollFeatures{HiddenResults: true}},
+		{"--weight=<@U123|marco>:2 --weight=<!subteam^S456|@leads>:1.5 \"Lunch?\" \"Pizza\" \"Tacos\"", "Lunch?", []string{"Pizza", "Tacos"}, PollFeatures{Weights: map[string]float64{"U123": 2, "S456": 1.5}}},
+		{"--voters=<@U123|marco>,<!subteam^S456|@leads> --channel-members \"Lunch?\" \"Pizza\" \"Tacos\"", "Lunch?", []string{"Pizza", "Tacos"}, PollFeatures{Eligibility: &Eligibility{Voters: []string{"U123", "S456"}, ChannelMembers: true}}},
+	}
+
+	for _, tc := range testCases {
+		_, question, options, features, err := parsePollParams(tc.text, time.Now())
+		require.NoError(t, err)
+
+		assert.Equal(t, tc.question, question)
+		assert.Equal(t, tc.options, options)
+		assert.Equal(t, tc.features, features)
+	}
+}
+
+func TestParsePollParamsWithDeadline(t *testing.T) {
+	now := time.Unix(1566576557, 0)
+
+	_, _, _, features, err := parsePollParams("--deadline=2h \"Favorite thing?\" \"Reading\" \"Running\"", now)
+	require.NoError(t, err)
+	assert.Equal(t, PollFeatures{Deadline: 1566583757}, features)
+
+	_, _, _, features, err = parsePollParams("--anonymous --deadline=2019-08-23T17:00:00Z \"Favorite thing?\" \"Reading\" \"Running\"", now)
+	require.NoError(t, err)
+	assert.Equal(t, PollFeatures{Anonymous: true, Deadline: 1566579600}, features)
+}
+
+func TestParseDeadline(t *testing.T) {
+	now := time.Unix(1566576557, 0)
+
+	testCases := []struct {
+		name             string
+		rawDeadline      string
+		expectedDeadline time.Time
+		expectedErr      string
+	}{
+		{"Duration", "30m", time.Unix(1566578357, 0), ""},
+		{"Absolute time", "2019-08-23T10:00:00-07:00", time.Unix(1566579600, 0), ""},
+		{"Past time", "2019-08-23T10:00:00Z", time.Time{}, "Deadline [2019-08-23T10:00:00Z] is not in the future"},
+		{"Negative duration", "-1h", time.Time{}, "Deadline [-1h] is not in the future"},
+		{"Invalid", "tomorrow", time.Time{}, "Invalid deadline [tomorrow], expected a duration (i.e. 2h) or a time (i.e. 2020-10-20T15:00:00-07:00)"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			deadline, err := parseDeadline(tc.rawDeadline, now)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+				assert.True(t, tc.expectedDeadline.Equal(deadline))
+			}
+		})
+	}
+}
+
+func TestParsePollParamsWithUnknownFlag(t *testing.T) {
+	_, _, _, _, err := parsePollParams("--secret \"Favorite thing?\" \"Reading\" \"Running\"", time.Now())
+	assert.EqualError(t, err, "Unknown flag [--secret]")
+}
+
 func TestInteractivePollRequestRendering(t *testing.T) {
-	viewRequest := createInteractivePollPrompt()
+	viewRequest := createInteractivePollPrompt(nil, nil, false)
 
 	render, err := json.Marshal(viewRequest)
 	require.NoError(t, err)
 
-	assert.Equal(t, "{\"type\":\"modal\",\"title\":{\"type\":\"plain_text\",\"text\":\"Marco Poller\"},\"blocks\":[{\"type\":\"input\",\"block_id\":\"poll_conversation_select\",\"label\":{\"type\":\"plain_text\",\"text\":\"Where do you want to send your poll?\"},\"element\":{\"type\":\"conversations_select\",\"action_id\":\"poll_conversation_select\",\"default_to_current_conversation\":true,\"response_url_enabled\":true}},{\"type\":\"input\",\"block_id\":\"poll_question\",\"label\":{\"type\":\"plain_text\",\"text\":\"What's your poll about?\"},\"element\":{\"type\":\"plain_text_input\",\"action_id\":\"poll_question\",\"placeholder\":{\"type\":\"plain_text\",\"text\":\"What's your favorite color?\"}}},{\"type\":\"input\",\"block_id\":\"poll_answer_options\",\"label\":{\"type\":\"plain_text\",\"text\":\"Answer Options\"},\"element\":{\"type\":\"plain_text_input\",\"action_id\":\"poll_answer_options\",\"placeholder\":{\"type\":\"plain_text\",\"text\":\"All the color options (one per line)\"},\"multiline\":true},\"hint\":{\"type\":\"plain_text\",\"text\":\"Enter the answer options (one per line)\"}},{\"type\":\"input\",\"block_id\":\"poll_features\",\"label\":{\"type\":\"plain_text\",\"text\":\"Options\"},\"element\":{\"type\":\"checkboxes\",\"action_id\":\"poll_features\",\"options\":[{\"text\":{\"type\":\"plain_text\",\"text\":\"Allow voters to vote for many options\"},\"value\":\"multivoting\"}]},\"optional\":true}],\"close\":{\"type\":\"plain_text\",\"text\":\"Cancel\"},\"submit\":{\"type\":\"plain_text\",\"text\":\"Create Poll\"},\"callback_id\":\"interactive-poll-create\"}", string(render))
+	assert.Equal(t, "{\"type\":\"modal\",\"title\":{\"type\":\"plain_text\",\"text\":\"Marco Poller\"},\"blocks\":[{\"type\":\"input\",\"block_id\":\"poll_conversation_select\",\"label\":{\"type\":\"plain_text\",\"text\":\"Where do you want to send your poll?\"},\"element\":{\"type\":\"conversations_select\",\"action_id\":\"poll_conversation_select\",\"default_to_current_conversation\":true,\"response_url_enabled\":true}},{\"type\":\"input\",\"block_id\":\"poll_question\",\"label\":{\"type\":\"plain_text\",\"text\":\"What's your poll about?\"},\"element\":{\"type\":\"plain_text_input\",\"action_id\":\"poll_question\",\"placeholder\":{\"type\":\"plain_text\",\"text\":\"What's your favorite color?\"}}},{\"type\":\"input\",\"block_id\":\"poll_answer_options\",\"label\":{\"type\":\"plain_text\",\"text\":\"Answer Options\"},\"element\":{\"type\":\"plain_text_input\",\"action_id\":\"poll_answer_options\",\"placeholder\":{\"type\":\"plain_text\",\"text\":\"All the color options (one per line)\"},\"multiline\":true},\"hint\":{\"type\":\"plain_text\",\"text\":\"Enter the answer options (one per line). Add an emoji, a description and a link with :emoji: Option | Description | https://link\"}},{\"type\":\"input\",\"block_id\":\"poll_features\",\"label\":{\"type\":\"plain_text\",\"text\":\"Options\"},\"element\":{\"type\":\"checkboxes\",\"action_id\":\"poll_features\",\"options\":[{\"text\":{\"type\":\"plain_text\",\"text\":\"Allow voters to vote for many options\"},\"value\":\"multivoting\"},{\"text\":{\"type\":\"plain_text\",\"text\":\"Anonymous voting (only show vote counts)\"},\"value\":\"anonymous\"},{\"text\":{\"type\":\"plain_text\",\"text\":\"Ranked choice voting (vote for options in order of preference)\"},\"value\":\"rankedchoice\"},{\"text\":{\"type\":\"plain_text\",\"text\":\"Keep results available for export after voting closes\"},\"value\":\"keepresults\"},{\"text\":{\"type\":\"plain_text\",\"text\":\"Allow voters to add options\"},\"value\":\"openoptions\"},{\"text\":{\"type\":\"plain_text\",\"text\":\"Hide results until voting closes\"},\"value\":\"hiddenresults\"},{\"text\":{\"type\":\"plain_text\",\"text\":\"Only members of the channel can vote\"},\"value\":\"channelmembers\"},{\"text\":{\"type\":\"plain_text\",\"text\":\"Show results as a bar chart\"},\"value\":\"barchart\"}]},\"optional\":true},{\"type\":\"input\",\"block_id\":\"poll_deadline\",\"label\":{\"type\":\"plain_text\",\"text\":\"Close voting automatically\"},\"element\":{\"type\":\"plain_text_input\",\"action_id\":\"poll_deadline\",\"placeholder\":{\"type\":\"plain_text\",\"text\":\"2h\"}},\"hint\":{\"type\":\"plain_text\",\"text\":\"Enter a duration (i.e. 30m, 2h) or a time (i.e. 2020-10-20T15:00:00-07:00)\"},\"optional\":true},{\"type\":\"input\",\"block_id\":\"poll_max_votes\",\"label\":{\"type\":\"plain_text\",\"text\":\"Maximum number of votes per voter\"},\"element\":{\"type\":\"plain_text_input\",\"action_id\":\"poll_max_votes\",\"placeholder\":{\"type\":\"plain_text\",\"text\":\"3\"},\"max_length\":3},\"hint\":{\"type\":\"plain_text\",\"text\":\"Enter a number to limit how many options voters can vote for (only when voting for many options is allowed)\"},\"optional\":true}],\"close\":{\"type\":\"plain_text\",\"text\":\"Cancel\"},\"submit\":{\"type\":\"plain_text\",\"text\":\"Create Poll\"},\"callback_id\":\"interactive-poll-create\"}", string(render))
 }
 
 func TestToggleVoteForValue(t *testing.T) {
@@ -164,12 +276,54 @@ func TestToggleVoteForValue(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			output := toggleVoteForValue(tc.existingVotes, tc.voteToToggle)
+			output, err := toggleVoteForValue(tc.existingVotes, tc.voteToToggle, 0)
+			require.NoError(t, err)
 			assert.Equal(t, tc.expectedOutput, output)
 		})
 	}
 }
 
+func TestToggleVoteForValueWithLimit(t *testing.T) {
+	testCases := []struct {
+		name           string
+		existingVotes  string
+		voteToToggle   string
+		maxVotes       int
+		expectedOutput string
+		expectedErr    string
+	}{
+		{"Vote under limit", "1", "2", 3, "1,2", ""},
+		{"Vote reaching limit", "1,2", "4", 3, "1,2,4", ""},
+		{"Vote over limit", "1,2,4", "3", 3, "1,2,4", "You can only vote for up to 3 options on this poll"},
+		{"Remove vote at limit", "1,2,4", "2", 3, "1,4", ""},
+		{"Vote over limit of 1", "1", "2", 1, "1", "You can only vote for 1 option on this poll"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := toggleVoteForValue(tc.existingVotes, tc.voteToToggle, tc.maxVotes)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tc.expectedOutput, output)
+		})
+	}
+}
+
+func TestParseMaxVotes(t *testing.T) {
+	maxVotes, err := parseMaxVotes("3")
+	require.NoError(t, err)
+	assert.Equal(t, 3, maxVotes)
+
+	for _, invalid := range []string{"0", "-1", "three", "2.5"} {
+		_, err := parseMaxVotes(invalid)
+		assert.EqualError(t, err, fmt.Sprintf("Invalid vote limit [%s], expected a positive number (i.e. 3)", invalid))
+	}
+}
+
 func TestParseCallbackParsesResponseURLs(t *testing.T) {
 	input := `
 	{
@@ -238,7 +392,7 @@ func TestParseCallbackParsesResponseURLs(t *testing.T) {
 			  },
 			  "hint": {
 				"type": "plain_text",
-				"text": "Enter the answer options (one per line)",
+				"text": "Enter the answer options (one per line). Add an emoji, a description and a link with :emoji: Option | Description | https://link",
 				"emoji": true
 			  },
 			  "optional": false,
@@ -526,3 +680,19 @@ func TestParseCallbackParsesState(t *testing.T) {
 
 	assert.NotNil(t, callback.State)
 }
+
+func TestRenderPollWithVoteLimit(t *testing.T) {
+	poll := Poll{ID: "un", Question: "What's your favorite book?", Options: []PollOption{{Text: "Ishmael"}, {Text: "Story of B"}, {Text: "My Ishmael"}}, Creator: "marco", Features: PollFeatures{MultiAnswers: true, MaxVotesPerUser: 2}}
+	blocks := renderPoll(poll, map[string][]Voter{}, false, defaultMaxVoterAvatars)
+
+	render, err := json.Marshal(blocks)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(render), "{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*What's your favorite book?*\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Vote for up to 2 options\"}]},{\"type\":\"divider\"}")
+}
+
+func TestCombineErrors(t *testing.T) {
+	assert.NoError(t, combineErrors([]error{}))
+	assert.EqualError(t, combineErrors([]error{fmt.Errorf("boom")}), "boom")
+	assert.EqualError(t, combineErrors([]error{fmt.Errorf("boom"), fmt.Errorf("bang")}), "2 errors: boom; bang")
+}
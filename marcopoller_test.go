@@ -382,12 +382,7 @@ func TestNewVoteWithInvalidSlackSignature(t *testing.T) {
 }
 
 func TestValidVoteUpdate(t *testing.T) {
-	slackRequest := ""
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		reqBody, _ := ioutil.ReadAll(r.Body)
-		slackRequest = string(reqBody)
-		fmt.Fprintln(w, "OK")
-	}))
+	server := newSlackServer()
 	defer server.Close()
 
 	callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "marco"}, ResponseURL: server.URL, ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,vote", Value: "1"}}}}
@@ -430,7 +425,10 @@ func TestValidVoteUpdate(t *testing.T) {
 	assert.Equal(t, "", string(rbody))
 	assert.Equal(t, 200, resp.StatusCode)
 
-	assert.Regexp(t, regexp.MustCompile("\\{\"blocks\".*,\"replace_original\":true}"), slackRequest)
+	slackRequests := server.Requests()
+	require.Len(t, slackRequests, 2)
+	assert.Regexp(t, regexp.MustCompile("\\{\"blocks\".*,\"replace_original\":true}"), slackRequests[0])
+	assert.Equal(t, "{\"response_type\":\"ephemeral\",\"text\":\"Your vote on *To do or not to do?*: Not Do\",\"replace_original\":false}", slackRequests[1])
 }
 
 func TestVoteOnExpiredPoll(t *testing.T) {
@@ -481,6 +479,55 @@ func TestVoteOnExpiredPoll(t *testing.T) {
 	assert.Equal(t, "{\"response_type\":\"ephemeral\",\"text\":\":warning: Sorry, the poll is expired and is now read-only\",\"replace_original\":false}", slackRequest)
 }
 
+func TestVoteOnClosedPoll(t *testing.T) {
+	slackRequest := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := ioutil.ReadAll(r.Body)
+		slackRequest = string(reqBody)
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "marco"}, ResponseURL: server.URL, ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,vote", Value: "1", ActionTs: "1566580158"}}}}
+	callback.Channel.ID = "myLittleChannel"
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Add("X-Slack-Signature", "8e9fe980e2b36c7a7accab28bd8e315667cf9122c3f01c3b7230bb9587627ccb")
+	r.Header.Add("X-Slack-Request-Timestamp", "1531431954")
+
+	userFinder := &UserFinder{}
+	defer userFinder.AssertExpectations(t)
+
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return("{\"id\":\"1566576557-poll1\",\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false,\"keepResults\":true},\"creator\":\"marco\",\"closed\":true}", nil)
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	resp := w.Result()
+	rbody, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, "", string(rbody))
+	assert.Equal(t, 200, resp.StatusCode)
+
+	assert.Equal(t, "{\"response_type\":\"ephemeral\",\"text\":\":warning: Sorry, voting on this poll is closed\",\"replace_original\":false}", slackRequest)
+}
+
 func TestVoteOnPollUsingOldIdentifierFormat(t *testing.T) {
 	slackRequest := ""
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -579,6 +626,55 @@ func TestErrorLoadingUserInfoOnVoteRegistration(t *testing.T) {
 }
 
 func TestValidNewVote(t *testing.T) {
+	server := newSlackServer()
+	defer server.Close()
+
+	callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "marco"}, ResponseURL: server.URL, ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,vote", Value: "1"}}}}
+	callback.Channel.ID = "myLittleChannel"
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Add("X-Slack-Signature", "8e9fe980e2b36c7a7accab28bd8e315667cf9122c3f01c3b7230bb9587627ccb")
+	r.Header.Add("X-Slack-Request-Timestamp", "1531431954")
+
+	userFinder := &UserFinder{}
+	defer userFinder.AssertExpectations(t)
+
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return("{\"id\":\"1566576557-poll1\",\"msgID\":{\"channelID\":\"myLittleChannel\",\"timestamp\":\"1566576557.354007\"},\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\"}", nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": "{\"id\":\"1566576557-poll1\",\"msgID\":{\"channelID\":\"myLittleChannel\",\"timestamp\":\"1566576557.354007\"},\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\"}"}, nil)
+	storer.On("PutSiloString", "1566576557-poll1", "marco", "1").Return(nil)
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	resp := w.Result()
+	rbody, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, "", string(rbody))
+	assert.Equal(t, 200, resp.StatusCode)
+
+	slackRequests := server.Requests()
+	require.Len(t, slackRequests, 2)
+	assert.Regexp(t, regexp.MustCompile("\\{\"blocks\".*,\"replace_original\":true}"), slackRequests[0])
+	assert.Equal(t, "{\"response_type\":\"ephemeral\",\"text\":\"Your vote on *To do or not to do?*: Not Do\",\"replace_original\":false}", slackRequests[1])
+}
+
+func TestNewVoteOverVoteLimit(t *testing.T) {
 	slackRequest := ""
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		reqBody, _ := ioutil.ReadAll(r.Body)
@@ -601,9 +697,8 @@ func TestValidNewVote(t *testing.T) {
 	defer userFinder.AssertExpectations(t)
 
 	storer := &mocks.Storer{}
-	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return("{\"id\":\"1566576557-poll1\",\"msgID\":{\"channelID\":\"myLittleChannel\",\"timestamp\":\"1566576557.354007\"},\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\"}", nil)
-	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": "{\"id\":\"1566576557-poll1\",\"msgID\":{\"channelID\":\"myLittleChannel\",\"timestamp\":\"1566576557.354007\"},\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\"}"}, nil)
-	storer.On("PutSiloString", "1566576557-poll1", "marco", "1").Return(nil)
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return("{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\",\"Rome\",\"Oslo\"],\"features\":{\"multianswers\":true,\"maxVotesPerUser\":2},\"creator\":\"UID\"}", nil)
+	storer.On("GetSiloString", "1566576557-poll1", "marco").Return("0,2", nil)
 	defer storer.AssertExpectations(t)
 
 	verifier := &Verifier{}
@@ -626,7 +721,7 @@ func TestValidNewVote(t *testing.T) {
 	assert.Equal(t, "", string(rbody))
 	assert.Equal(t, 200, resp.StatusCode)
 
-	assert.Regexp(t, regexp.MustCompile("\\{\"blocks\".*,\"replace_original\":true}"), slackRequest)
+	assert.Equal(t, "{\"response_type\":\"ephemeral\",\"text\":\":warning: You can only vote for up to 2 options on this poll. Remove one of your votes to vote for another option.\",\"replace_original\":false}", slackRequest)
 }
 
 func TestValidNewVoteFailureToLoadPoll(t *testing.T) {
@@ -1613,3 +1708,375 @@ func TestCloseVoting(t *testing.T) {
 
 	assert.Equal(t, "{\"blocks\":[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*To do or not to do?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Do\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"http://image.me\",\"alt_text\":\"\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Not Do\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e (voting closed)\"}]}],\"replace_original\":true}", slackRequest)
 }
+
+func TestCloseVotingKeepsResults(t *testing.T) {
+	slackRequest := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := ioutil.ReadAll(r.Body)
+		slackRequest = string(reqBody)
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "marco"}, ResponseURL: server.URL, ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,close", Value: "close"}}}}
+	callback.Channel.ID = "myLittleChannel"
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Header.Add("X-Slack-Signature", "8e9fe980e2b36c7a7accab28bd8e315667cf9122c3f01c3b7230bb9587627ccb")
+	r.Header.Add("X-Slack-Request-Timestamp", "1531431954")
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "marco").Return(&slack.User{ID: "marco", Profile: slack.UserProfile{Image24: "http://image.me", RealName: "Marco Poller"}}, nil)
+	defer userFinder.AssertExpectations(t)
+
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return("{\"id\":\"1566576557-poll1\",\"msgID\":{\"channelID\":\"myLittleChannel\",\"timestamp\":\"1566576557.354007\"},\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false,\"keepResults\":true},\"creator\":\"marco\"}", nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": "{\"id\":\"1566576557-poll1\",\"msgID\":{\"channelID\":\"myLittleChannel\",\"timestamp\":\"1566576557.354007\"},\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\"}", "marco": "0"}, nil)
+	storer.On("PutSiloString", "1566576557-poll1", "pollInfo", "{\"id\":\"1566576557-poll1\",\"msgID\":{\"channelID\":\"myLittleChannel\",\"timestamp\":\"1566576557.354007\"},\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false,\"keepResults\":true},\"creator\":\"marco\",\"closed\":true}").Return(nil)
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	resp := w.Result()
+	rbody, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, "", string(rbody))
+	assert.Equal(t, 200, resp.StatusCode)
+
+	assert.Equal(t, "{\"blocks\":[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*To do or not to do?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Do\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"http://image.me\",\"alt_text\":\"\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Not Do\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e (voting closed)\"}]}],\"replace_original\":true}", slackRequest)
+}
+
+func TestCloseDuePolls(t *testing.T) {
+	slackRequest := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := ioutil.ReadAll(r.Body)
+		slackRequest = string(reqBody)
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "marco").Return(&slack.User{ID: "marco", Profile: slack.UserProfile{Image24: "http://image.me", RealName: "Marco Poller"}}, nil)
+	defer userFinder.AssertExpectations(t)
+
+	duePoll := fmt.Sprintf("{\"id\":\"1566576557-duePoll\",\"responseURL\":\"%s\",\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false,\"deadline\":1566580000},\"creator\":\"UID\"}", server.URL)
+	storer := &mocks.Storer{}
+	// Set up 1 due poll, 1 poll with a later deadline and 1 without deadline
+	storer.On("GlobalScan").Return(map[string]map[string]string{"1566576557-duePoll": {"pollInfo": duePoll, "marco": "0"},
+		"1566576557-laterPoll": {"pollInfo": "{\"id\":\"1566576557-laterPoll\",\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false,\"deadline\":1566590000},\"creator\":\"UID\"}"},
+		"1566576557-openPoll":  {"pollInfo": "{\"id\":\"1566576557-openPoll\",\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\"}"}}, nil)
+	storer.On("ScanSilo", "1566576557-duePoll").Return(map[string]string{"pollInfo": duePoll, "marco": "0"}, nil)
+	storer.On("DeleteSiloString", "1566576557-duePoll", "pollInfo").Return(nil)
+	storer.On("DeleteSiloString", "1566576557-duePoll", "marco").Return(nil)
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	closed, err := mp.CloseDuePolls(time.Unix(1566580158, 0))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, closed)
+	assert.Equal(t, "{\"blocks\":[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*To do or not to do?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Do\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"http://image.me\",\"alt_text\":\"\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Not Do\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@UID\\u003e (voting closed)\"}]}],\"replace_original\":true}", slackRequest)
+}
+
+func TestCloseDuePollsSkipsInvalidPolls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "marco").Return(&slack.User{ID: "marco", Profile: slack.UserProfile{Image24: "http://image.me"}}, nil)
+
+	storer := marcopoller.NewMemoryStorer()
+	require.NoError(t, storer.PutSiloString("1566576557-brokenPoll", "pollInfo", "{\"id\":\"1566576557-brokenPoll\",\"options\":"))
+	require.NoError(t, storer.PutSiloString("1566576557-duePoll", "pollInfo", fmt.Sprintf("{\"id\":\"1566576557-duePoll\",\"responseURL\":\"%s\",\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false,\"deadline\":1566580000},\"creator\":\"UID\"}", server.URL)))
+	require.NoError(t, storer.PutSiloString("1566576557-duePoll", "marco", "0"))
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	closed, err := mp.CloseDuePolls(time.Unix(1566580158, 0))
+	assert.EqualError(t, err, "Error decoding poll [1566576557-brokenPoll]: unexpected end of JSON input")
+	assert.Equal(t, 1, closed)
+
+	// The due poll is closed even though the broken poll couldn't be decoded
+	_, err = storer.GetSiloString("1566576557-duePoll", "pollInfo")
+	assert.Error(t, err)
+}
+
+func TestInteractivePollSubmissionWithInvalidDeadline(t *testing.T) {
+	callback := marcopoller.InteractionCallback{Type: "view_submission",
+		User:         slack.User{ID: "marco"},
+		ResponseURLs: []marcopoller.ResponseURL{marcopoller.ResponseURL{ResponseURL: "https://hooks.slack.com/app/bla"}},
+		View: slack.View{CallbackID: "interactive-poll-create",
+			State: &slack.ViewState{Values: map[string]map[string]slack.BlockAction{
+				"poll_question":       map[string]slack.BlockAction{"poll_question": slack.BlockAction{Value: "To do or not to do?"}},
+				"poll_answer_options": map[string]slack.BlockAction{"poll_answer_options": slack.BlockAction{Value: "Do\nNot Do\n"}},
+				"poll_deadline":       map[string]slack.BlockAction{"poll_deadline": slack.BlockAction{Value: "whenever"}},
+			}}}}
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	userFinder := &UserFinder{}
+	defer userFinder.AssertExpectations(t)
+
+	storer := &mocks.Storer{}
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	resp := w.Result()
+	rbody, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "{\"response_action\":\"errors\",\"errors\":{\"poll_deadline\":\"Invalid deadline [whenever], expected a duration (i.e. 2h) or a time (i.e. 2020-10-20T15:00:00-07:00)\"}}\n", string(rbody))
+}
+
+func TestInteractivePollSubmissionWithVoteLimitOnSingleAnswerPoll(t *testing.T) {
+	callback := marcopoller.InteractionCallback{Type: "view_submission",
+		User:         slack.User{ID: "marco"},
+		ResponseURLs: []marcopoller.ResponseURL{marcopoller.ResponseURL{ResponseURL: "https://hooks.slack.com/app/bla"}},
+		View: slack.View{CallbackID: "interactive-poll-create",
+			State: &slack.ViewState{Values: map[string]map[string]slack.BlockAction{
+				"poll_question":       map[string]slack.BlockAction{"poll_question": slack.BlockAction{Value: "To do or not to do?"}},
+				"poll_answer_options": map[string]slack.BlockAction{"poll_answer_options": slack.BlockAction{Value: "Do\nNot Do\n"}},
+				"poll_max_votes":      map[string]slack.BlockAction{"poll_max_votes": slack.BlockAction{Value: "2"}},
+			}}}}
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	userFinder := &UserFinder{}
+	defer userFinder.AssertExpectations(t)
+
+	storer := &mocks.Storer{}
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	resp := w.Result()
+	rbody, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "{\"response_action\":\"errors\",\"errors\":{\"poll_max_votes\":\"A vote limit can only be set on polls allowing votes for many options\"}}\n", string(rbody))
+}
+
+func TestValidNewPollPostedWithMessenger(t *testing.T) {
+	body := "token=sometoken&team_id=TEAMID3&team_domain=test-workspace&channel_id=CID&channel_name=testchannel&user_id=UID&user_name=marco&command=%2Fpoll&text=%22To%20do%20or%20not%20to%20do%3F%22%20%22Do%22%20%22Not%20Do%22&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2Fbla%2Fbleh%2Fblo&trigger_id=someTriggerID"
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	userFinder := &UserFinder{}
+	defer userFinder.AssertExpectations(t)
+
+	storer := &mocks.Storer{}
+	storer.On("PutSiloString", mock.Anything, "pollInfo", mock.MatchedBy(func(val string) bool {
+		match, _ := regexp.MatchString("^{\"id\":\"[^\"]*\",\"responseURL\":\"https://hooks.slack.com/commands/bla/bleh/blo\",\"question\":\"To do or not to do\\?\",\"options\":\\[\"Do\",\"Not Do\"\\],\"features\":{\"multianswers\":false},\"creator\":\"UID\"}$", val)
+		return match
+	})).Return(nil).Once()
+	storer.On("PutSiloString", mock.Anything, "pollInfo", mock.MatchedBy(func(val string) bool {
+		match, _ := regexp.MatchString("^{\"id\":\"[^\"]*\",\"msgID\":{\"channelID\":\"CID\",\"timestamp\":\"1566576557.354007\"},\"responseURL\":\"https://hooks.slack.com/commands/bla/bleh/blo\",\"question\":\"To do or not to do\\?\",", val)
+		return match
+	})).Return(nil).Once()
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	messenger := &mmocks.Messenger{}
+	messenger.On("PostMessage", "CID", mock.Anything, mock.Anything).Return("CID", "1566576557.354007", nil)
+	defer messenger.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionMessenger(messenger), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.StartPoll(w, r)
+
+	resp := w.Result()
+
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+func TestNewPollFallsBackOnResponseURLWhenMessengerFails(t *testing.T) {
+	slackRequest := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := ioutil.ReadAll(r.Body)
+		slackRequest = string(reqBody)
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	body := "token=sometoken&team_id=TEAMID3&team_domain=test-workspace&channel_id=CID&channel_name=testchannel&user_id=UID&user_name=marco&command=%2Fpoll&text=%22To%20do%20or%20not%20to%20do%3F%22%20%22Do%22%20%22Not%20Do%22&response_url=" + server.URL + "&trigger_id=someTriggerID"
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	userFinder := &UserFinder{}
+	defer userFinder.AssertExpectations(t)
+
+	storer := &mocks.Storer{}
+	storer.On("PutSiloString", mock.Anything, "pollInfo", mock.Anything).Return(nil).Once()
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	messenger := &mmocks.Messenger{}
+	messenger.On("PostMessage", "CID", mock.Anything, mock.Anything).Return("", "", fmt.Errorf("not_in_channel"))
+	defer messenger.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionMessenger(messenger), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.StartPoll(w, r)
+
+	resp := w.Result()
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Regexp(t, regexp.MustCompile("\\{\"response_type\":\"in_channel\",\"blocks\":.*,\"replace_original\":false}"), slackRequest)
+}
+
+func TestValidNewVoteUpdatedWithMessenger(t *testing.T) {
+	callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "marco"}, ResponseURL: "https://hooks.slack.com/actions/bla", ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,vote", Value: "1"}}}}
+	callback.Channel.ID = "myLittleChannel"
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "marco").Return(&slack.User{ID: "marco", Profile: slack.UserProfile{Image24: "http://image.me", RealName: "Marco Poller"}}, nil)
+	defer userFinder.AssertExpectations(t)
+
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return("{\"id\":\"1566576557-poll1\",\"msgID\":{\"channelID\":\"myLittleChannel\",\"timestamp\":\"1566576557.354007\"},\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\"}", nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": "{\"id\":\"1566576557-poll1\",\"msgID\":{\"channelID\":\"myLittleChannel\",\"timestamp\":\"1566576557.354007\"},\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\"}", "marco": "1"}, nil)
+	storer.On("PutSiloString", "1566576557-poll1", "marco", "1").Return(nil)
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	messenger := &mmocks.Messenger{}
+	messenger.On("UpdateMessage", "myLittleChannel", "1566576557.354007", mock.Anything, mock.Anything).Return("myLittleChannel", "1566576557.354007", "", nil)
+	defer messenger.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionMessenger(messenger), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	resp := w.Result()
+
+	assert.Equal(t, 200, resp.StatusCode)
+}
+
+func TestDeletePollWithMessenger(t *testing.T) {
+	callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "UID"}, ResponseURL: "https://hooks.slack.com/actions/bla", ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,delete", Value: "delete"}}}}
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	userFinder := &UserFinder{}
+	defer userFinder.AssertExpectations(t)
+
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return("{\"id\":\"1566576557-poll1\",\"msgID\":{\"channelID\":\"myLittleChannel\",\"timestamp\":\"1566576557.354007\"},\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\"}", nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": "{}"}, nil)
+	storer.On("DeleteSiloString", "1566576557-poll1", "pollInfo").Return(nil)
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	messenger := &mmocks.Messenger{}
+	messenger.On("DeleteMessage", "myLittleChannel", "1566576557.354007").Return("myLittleChannel", "1566576557.354007", nil)
+	defer messenger.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionMessenger(messenger), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	resp := w.Result()
+
+	assert.Equal(t, 200, resp.StatusCode)
+}
@@ -34,3 +34,26 @@ func (_m *Dialoguer) OpenView(triggerID string, view slack.ModalViewRequest) (*s
 
 	return r0, r1
 }
+
+// UpdateView provides a mock function with given fields: view, externalID, hash, viewID
+func (_m *Dialoguer) UpdateView(view slack.ModalViewRequest, externalID string, hash string, viewID string) (*slack.ViewResponse, error) {
+	ret := _m.Called(view, externalID, hash, viewID)
+
+	var r0 *slack.ViewResponse
+	if rf, ok := ret.Get(0).(func(slack.ModalViewRequest, string, string, string) *slack.ViewResponse); ok {
+		r0 = rf(view, externalID, hash, viewID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*slack.ViewResponse)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(slack.ModalViewRequest, string, string, string) error); ok {
+		r1 = rf(view, externalID, hash, viewID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,230 @@
+package marcopoller
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// Add option prompt identifiers
+const (
+	addOptionCallbackID = "poll-add-option"
+
+	newOptionInputBlockID = "poll_new_option"
+	newOptionActionID     = "poll_new_option"
+)
+
+// Poll message size
+const (
+	// maxMessageBlocks is the maximum number of blocks of a slack message
+	maxMessageBlocks = 50
+
+	// maxOptionBlocks is the most blocks an option renders as: its section (with its bar or weighted count), its voter
+	// avatars and the button to see all of its voters
+	maxOptionBlocks = 3
+
+	// maxPollBlocks is the most blocks a poll renders as besides its options: the question, the notes of its ranked
+	// choice, vote limit, eligibility and weights features, the divider, the hidden results note, the actions and the
+	// creator. Closed polls render fewer (i.e. the instant-runoff results take a divider and two sections)
+	maxPollBlocks = 9
+)
+
+// maxOptions is the maximum number of options a poll can have once voters add options or the poll is edited. It keeps
+// polls within the limit of blocks in a slack message however they're rendered
+const maxOptions = (maxMessageBlocks - maxPollBlocks) / maxOptionBlocks
+
+// pollViewMetadata holds the private metadata of a modal opened from a poll message. It identifies the poll
+// and keeps the response url needed to update the poll message when the modal is submitted. Edit prompts also
+// keep the number of options they were opened with and edits waiting for confirmation keep the edit
+type pollViewMetadata struct {
+	PollID        string    `json:"pollID"`
+	ResponseURL   string    `json:"responseURL"`
+	EditedOptions int       `json:"editedOptions,omitempty"`
+	Edit          *pollEdit `json:"edit,omitempty"`
+}
+
+// handleAddOptionRequest handles a request to add an option to a poll by opening up the add option prompt
+func (mp *MarcoPoller) handleAddOptionRequest(poll Poll, callback InteractionCallback, w http.ResponseWriter) {
+	if !poll.Features.OpenOptions {
+		showErrorToUser(callback.ResponseURL, ":warning: Sorry, adding options isn't allowed on this poll")
+		return
+	}
+
+	if poll.Closed || poll.Features.isDue(actionTime(callback)) {
+		showErrorToUser(callback.ResponseURL, ":warning: Sorry, voting on this poll is closed")
+		return
+	}
+
+	if len(poll.Options) >= maxOptions {
+		showErrorToUser(callback.ResponseURL, fmt.Sprintf(":warning: Sorry, this poll already has the maximum number of options (%d)", maxOptions))
+		return
+	}
+
+	metadata, err := encodeViewMetadata(pollViewMetadata{PollID: poll.ID, ResponseURL: callback.ResponseURL})
+	if err != nil {
+		log.Printf("Error encoding view metadata for poll [%s]: %v", poll.ID, err)
+		showErrorToUser(callback.ResponseURL, ":warning: Error opening up the prompt to add an option. Please report this at https://github.com/alexandre-normand/marcopoller.")
+		return
+	}
+
+	_, err = mp.dialoguer.OpenView(callback.TriggerID, createAddOptionPrompt(poll, metadata))
+	if err != nil {
+		log.Printf("Error opening up add option prompt for trigger id [%s]: %s", callback.TriggerID, err.Error())
+		showErrorToUser(callback.ResponseURL, ":warning: Error opening up the prompt to add an option. Try again, maybe?")
+		return
+	}
+}
+
+// createAddOptionPrompt renders the content of the dialog to add an option to a poll
+func createAddOptionPrompt(poll Poll, metadata string) (viewRequest slack.ModalViewRequest) {
+	blocks := make([]slack.Block, 0)
+
+	blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*%s*", poll.Question), false, false), nil, nil))
+	blocks = append(blocks, slack.NewInputBlock(newOptionInputBlockID, slack.NewTextBlockObject("plain_text", "New option", false, false), slack.NewPlainTextInputBlockElement(slack.NewTextBlockObject("plain_text", "Your suggestion", false, false), newOptionActionID)))
+
+	viewRequest.Type = slack.VTModal
+	viewRequest.Title = slack.NewTextBlockObject("plain_text", friendlyName, false, false)
+	viewRequest.Close = slack.NewTextBlockObject("plain_text", "Cancel", false, false)
+	viewRequest.Submit = slack.NewTextBlockObject("plain_text", "Add option", false, false)
+	viewRequest.CallbackID = addOptionCallbackID
+	viewRequest.PrivateMetadata = metadata
+	viewRequest.Blocks = slack.Blocks{BlockSet: blocks}
+
+	return viewRequest
+}
+
+// handleAddOptionSubmission handles a submission of the add option dialog by adding the option to the
+// poll and updating the poll message
+func (mp *MarcoPoller) handleAddOptionSubmission(callback InteractionCallback, w http.ResponseWriter) {
+	metadata, err := decodeViewMetadata(callback.View.PrivateMetadata)
+	if err != nil {
+		log.Printf("Error decoding view metadata [%s]: %v", callback.View.PrivateMetadata, err)
+		return
+	}
+
+	option := PollOption{}
+	if callback.View.State != nil {
+		option = parseOption(callback.View.State.Values[newOptionInputBlockID][newOptionActionID].Value)
+	}
+
+	// The option is added to the poll as it was read and added again to the poll as it is now if the poll changed
+	// in the meantime so that concurrent changes to the poll aren't overwritten
+	var poll Poll
+	for attempt := 1; ; attempt++ {
+		encodedPoll, err := mp.storer.GetSiloString(metadata.PollID, pollInfoKey)
+		if err != nil {
+			log.Printf("Error getting existing poll info for id [%s]: %v", metadata.PollID, err)
+			showErrorToUser(metadata.ResponseURL, ":warning: Error getting existing poll info. Please try again.")
+			return
+		}
+
+		poll, err = decodePoll(encodedPoll)
+		if err != nil {
+			log.Printf("Error parsing existing poll [%s] for id [%s]: %v", encodedPoll, metadata.PollID, err)
+			showErrorToUser(metadata.ResponseURL, ":warning: Error parsing existing poll info. Please report this issue at https://github.com/alexandre-normand/marcopoller")
+			return
+		}
+
+		err = validateNewOption(poll, option, time.Now())
+		if err != nil {
+			writeViewSubmissionResponse(w, slack.NewErrorsViewSubmissionResponse(map[string]string{newOptionInputBlockID: err.Error()}))
+			return
+		}
+
+		poll.Options = append(poll.Options, option)
+
+		newEncodedPoll, err := encodePoll(poll)
+		if err != nil {
+			log.Printf("Error encoding poll [%v]: %v", poll, err)
+			showErrorToUser(metadata.ResponseURL, ":warning: Error adding option. Please report this issue at https://github.com/alexandre-normand/marcopoller")
+			return
+		}
+
+		swapped, err := mp.compareAndSwapPollInfo(poll.ID, encodedPoll, newEncodedPoll)
+		if err != nil {
+			log.Printf("Error storing poll info [%s] for poll [%s]: %v", newEncodedPoll, poll.ID, err)
+			showErrorToUser(metadata.ResponseURL, ":warning: Error persisting new option. Please try again.")
+			return
+		}
+
+		if swapped {
+			break
+		}
+
+		if attempt >= maxVoteAttempts {
+			log.Printf("Error storing poll info [%s] for poll [%s]: poll changed concurrently on all %d attempts", newEncodedPoll, poll.ID, attempt)
+			showErrorToUser(metadata.ResponseURL, ":warning: Error persisting new option. Please try again.")
+			return
+		}
+	}
+
+	votes, err := mp.listVotes(poll)
+	if err != nil {
+		log.Printf("Error listing votes for poll [%s]: %v", poll.ID, err)
+		showErrorToUser(metadata.ResponseURL, ":warning: Error listing votes for poll. Please try again.")
+		return
+	}
+
+	err = mp.updatePollMessage(poll, metadata.ResponseURL, renderPoll(poll, votes, false, mp.maxVoterAvatars))
+	if err != nil {
+		log.Printf("Error updating poll [%s] message : %v", poll.ID, err)
+		showErrorToUser(metadata.ResponseURL, ":warning: Error updating slack message for poll. Please try again.")
+		return
+	}
+}
+
+// validateNewOption returns an error if the option can't be added to the poll because the poll doesn't
+// allow it, the poll is full or the option is empty or already on the poll (ignoring case)
+func validateNewOption(poll Poll, option PollOption, now time.Time) (err error) {
+	if !poll.Features.OpenOptions {
+		return fmt.Errorf("Adding options isn't allowed on this poll")
+	}
+
+	if poll.Closed || poll.Features.isDue(now) {
+		return fmt.Errorf("Voting on this poll is closed")
+	}
+
+	return validateOption(poll.Options, option)
+}
+
+// validateOption returns an error if the option can't be added to the options because there are already
+// maxOptions options or the option is empty or already one of the options (ignoring case)
+func validateOption(options []PollOption, option PollOption) (err error) {
+	if option.Text == "" {
+		return fmt.Errorf("Enter an option")
+	}
+
+	if len(options) >= maxOptions {
+		return fmt.Errorf("This poll already has the maximum number of options (%d)", maxOptions)
+	}
+
+	for _, existing := range options {
+		if strings.EqualFold(strings.TrimSpace(existing.Text), option.Text) {
+			return fmt.Errorf("[%s] is already an option", existing.Text)
+		}
+	}
+
+	return nil
+}
+
+// encodeViewMetadata encodes the private metadata of a modal opened from a poll message
+func encodeViewMetadata(metadata pollViewMetadata) (encoded string, err error) {
+	m, err := json.Marshal(metadata)
+	if err != nil {
+		return "", err
+	}
+
+	return string(m), nil
+}
+
+// decodeViewMetadata decodes the private metadata of a modal opened from a poll message
+func decodeViewMetadata(encoded string) (metadata pollViewMetadata, err error) {
+	err = json.Unmarshal([]byte(encoded), &metadata)
+
+	return metadata, err
+}
@@ -0,0 +1,109 @@
+package marcopoller
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateNewOption(t *testing.T) {
+	now := time.Unix(1566576557, 0)
+	openPoll := Poll{ID: "un", Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos "}}, Features: PollFeatures{OpenOptions: true}}
+
+	fullPoll := openPoll
+	fullPoll.Options = make([]PollOption, maxOptions)
+	for i := range fullPoll.Options {
+		fullPoll.Options[i] = PollOption{Text: fmt.Sprintf("Option %d", i)}
+	}
+
+	closedPoll := openPoll
+	closedPoll.Closed = true
+
+	duePoll := openPoll
+	duePoll.Features.Deadline = now.Unix()
+
+	testCases := []struct {
+		name        string
+		poll        Poll
+		option      string
+		expectedErr string
+	}{
+		{"Valid option", openPoll, "Sushi", ""},
+		{"Options not allowed", Poll{Options: []PollOption{{Text: "Pizza"}}}, "Sushi", "Adding options isn't allowed on this poll"},
+		{"Closed poll", closedPoll, "Sushi", "Voting on this poll is closed"},
+		{"Due poll", duePoll, "Sushi", "Voting on this poll is closed"},
+		{"Empty option", openPoll, "", "Enter an option"},
+		{"Full poll", fullPoll, "Sushi", fmt.Sprintf("This poll already has the maximum number of options (%d)", maxOptions)},
+		{"Duplicate option", openPoll, "pizza", "[Pizza] is already an option"},
+		{"Duplicate option with spaces", openPoll, "Tacos", "[Tacos ] is already an option"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateNewOption(tc.poll, parseOption(tc.option), now)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestRenderPollWithOpenOptions(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}}, Creator: "marco", Features: PollFeatures{OpenOptions: true}}
+	blocks := renderPoll(poll, map[string][]Voter{}, false, defaultMaxVoterAvatars)
+
+	render, err := json.Marshal(blocks)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(render), "{\"type\":\"actions\",\"block_id\":\"un\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Add option\"},\"action_id\":\"un,addoption\",\"value\":\"addoption\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Remove my vote\"},\"action_id\":\"un,removevote\",\"value\":\"removevote\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Edit\"},\"action_id\":\"un,edit\",\"value\":\"edit\"},{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Close voting\"},\"action_id\":\"un,close\",\"value\":\"close\"}")
+}
+
+func TestViewMetadataRoundTrip(t *testing.T) {
+	encoded, err := encodeViewMetadata(pollViewMetadata{PollID: "un", ResponseURL: "https://hooks.slack.com/actions/bla"})
+	require.NoError(t, err)
+
+	metadata, err := decodeViewMetadata(encoded)
+	require.NoError(t, err)
+
+	assert.Equal(t, pollViewMetadata{PollID: "un", ResponseURL: "https://hooks.slack.com/actions/bla"}, metadata)
+}
+
+func TestRenderPollWithMaxOptionsFitsInMessage(t *testing.T) {
+	testCases := []struct {
+		name     string
+		features PollFeatures
+	}{
+		{"Avatars", PollFeatures{MultiAnswers: true, MaxVotesPerUser: 2, Eligibility: &Eligibility{Voters: []string{"U1"}}, Weights: map[string]float64{"U1": 2}}},
+		{"Bars", PollFeatures{MultiAnswers: true, MaxVotesPerUser: 2, Eligibility: &Eligibility{Voters: []string{"U1"}}, Weights: map[string]float64{"U1": 2}, RenderStyle: BarsRenderStyle}},
+		{"Ranked choice", PollFeatures{RankedChoice: true, MaxVotesPerUser: 2, Eligibility: &Eligibility{Voters: []string{"U1"}}, Weights: map[string]float64{"U1": 2}, KeepResults: true}},
+		{"Hidden results", PollFeatures{MultiAnswers: true, HiddenResults: true, MaxVotesPerUser: 2, Eligibility: &Eligibility{Voters: []string{"U1"}}, Weights: map[string]float64{"U1": 2}, KeepResults: true}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			poll := Poll{ID: "un", Question: "Lunch?", Creator: "marco", Features: tc.features}
+			votes := make(map[string][]Voter)
+			for i := 0; i < maxOptions; i++ {
+				optionID := fmt.Sprintf("%d", i)
+				poll.Options = append(poll.Options, PollOption{Text: fmt.Sprintf("Option %d", i), Description: "Description"})
+
+				// Every option has more voters than avatars and a distinct first choice count so the runoff has a round per option
+				for _, voter := range newVoters(defaultMaxVoterAvatars + i + 1) {
+					voter.userID = fmt.Sprintf("%s-%d", voter.userID, i)
+					voter.weight = 1
+					votes[optionID] = append(votes[optionID], voter)
+				}
+			}
+
+			for _, votingClosed := range []bool{false, true} {
+				assert.True(t, len(renderPoll(poll, votes, votingClosed, defaultMaxVoterAvatars)) <= maxMessageBlocks)
+			}
+		})
+	}
+}
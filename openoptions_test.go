@@ -0,0 +1,194 @@
+package marcopoller_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/marcopoller"
+	mmocks "github.com/alexandre-normand/marcopoller/mocks"
+	"github.com/alexandre-normand/slackscot/store/mocks"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const openPoll = "{\"id\":\"1566576557-poll1\",\"question\":\"Lunch?\",\"options\":[\"Pizza\",\"Tacos\"],\"features\":{\"multianswers\":false,\"openOptions\":true},\"creator\":\"marco\"}"
+
+func TestAddOptionOpensPrompt(t *testing.T) {
+	callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "polo"}, TriggerID: "someTriggerID", ResponseURL: "https://hooks.slack.com/actions/bla", ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,addoption", Value: "addoption"}}}}
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	userFinder := &UserFinder{}
+	defer userFinder.AssertExpectations(t)
+
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(openPoll, nil)
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	dialoguer.On("OpenView", "someTriggerID", mock.MatchedBy(func(view slack.ModalViewRequest) bool {
+		return view.CallbackID == "poll-add-option" && view.PrivateMetadata == "{\"pollID\":\"1566576557-poll1\",\"responseURL\":\"https://hooks.slack.com/actions/bla\"}"
+	})).Return(&slack.ViewResponse{}, nil)
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+}
+
+func TestAddOptionSubmission(t *testing.T) {
+	slackRequest := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := ioutil.ReadAll(r.Body)
+		slackRequest = string(reqBody)
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	callback := marcopoller.InteractionCallback{Type: "view_submission",
+		User: slack.User{ID: "polo"},
+		View: slack.View{CallbackID: "poll-add-option",
+			PrivateMetadata: fmt.Sprintf("{\"pollID\":\"1566576557-poll1\",\"responseURL\":\"%s\"}", server.URL),
+			State: &slack.ViewState{Values: map[string]map[string]slack.BlockAction{
+				"poll_new_option": map[string]slack.BlockAction{"poll_new_option": slack.BlockAction{Value: " Sushi "}},
+			}}}}
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "marco").Return(&slack.User{ID: "marco", Profile: slack.UserProfile{Image24: "http://image.me", RealName: "Marco Poller"}}, nil)
+	defer userFinder.AssertExpectations(t)
+
+	updatedPoll := "{\"id\":\"1566576557-poll1\",\"question\":\"Lunch?\",\"options\":[\"Pizza\",\"Tacos\",\"Sushi\"],\"features\":{\"multianswers\":false,\"openOptions\":true},\"creator\":\"marco\"}"
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(openPoll, nil)
+	storer.On("PutSiloString", "1566576557-poll1", "pollInfo", updatedPoll).Return(nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": updatedPoll, "marco": "1"}, nil)
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	resp := w.Result()
+	rbody, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "", string(rbody))
+	assert.Contains(t, slackRequest, "{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Sushi\"},\"accessory\":{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"Vote\"},\"action_id\":\"1566576557-poll1,vote\",\"value\":\"2\",\"style\":\"primary\"}}")
+}
+
+func TestAddDuplicateOptionSubmission(t *testing.T) {
+	callback := marcopoller.InteractionCallback{Type: "view_submission",
+		User: slack.User{ID: "polo"},
+		View: slack.View{CallbackID: "poll-add-option",
+			PrivateMetadata: "{\"pollID\":\"1566576557-poll1\",\"responseURL\":\"https://hooks.slack.com/actions/bla\"}",
+			State: &slack.ViewState{Values: map[string]map[string]slack.BlockAction{
+				"poll_new_option": map[string]slack.BlockAction{"poll_new_option": slack.BlockAction{Value: "tacos"}},
+			}}}}
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	userFinder := &UserFinder{}
+	defer userFinder.AssertExpectations(t)
+
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(openPoll, nil)
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	dialoguer := &mmocks.Dialoguer{}
+	defer dialoguer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	resp := w.Result()
+	rbody, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "{\"response_action\":\"errors\",\"errors\":{\"poll_new_option\":\"[Tacos] is already an option\"}}\n", string(rbody))
+}
+
+func TestAddOptionSubmissionKeepsConcurrentlyAddedOption(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	callback := marcopoller.InteractionCallback{Type: "view_submission",
+		User: slack.User{ID: "polo"},
+		View: slack.View{CallbackID: "poll-add-option",
+			PrivateMetadata: fmt.Sprintf("{\"pollID\":\"1566576557-poll1\",\"responseURL\":\"%s\"}", server.URL),
+			State: &slack.ViewState{Values: map[string]map[string]slack.BlockAction{
+				"poll_new_option": map[string]slack.BlockAction{"poll_new_option": slack.BlockAction{Value: "Sushi"}},
+			}}}}
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	pollWithAddedOption := "{\"id\":\"1566576557-poll1\",\"question\":\"Lunch?\",\"options\":[\"Pizza\",\"Tacos\",\"Ramen\"],\"features\":{\"multianswers\":false,\"openOptions\":true},\"creator\":\"marco\"}"
+	storer := &racingPollStorer{MemoryStorer: marcopoller.NewMemoryStorer(), concurrentPoll: pollWithAddedOption}
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", openPoll))
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	encodedPoll, err := storer.GetSiloString("1566576557-poll1", "pollInfo")
+	require.NoError(t, err)
+	assert.Equal(t, "{\"id\":\"1566576557-poll1\",\"question\":\"Lunch?\",\"options\":[\"Pizza\",\"Tacos\",\"Ramen\",\"Sushi\"],\"features\":{\"multianswers\":false,\"openOptions\":true},\"creator\":\"marco\"}", encodedPoll)
+}
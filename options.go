@@ -0,0 +1,179 @@
+package marcopoller
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// optionPartDelimiter separates the text of an option from its description and link when entering options (i.e.
+// ":page_facing_up: Design A | Keeps the current schema | https://docs.example.com/a")
+const optionPartDelimiter = "|"
+
+// escapedOptionPartDelimiter is a | that's part of an option (i.e. "A \| B" is the option named "A | B"). Options are
+// prefilled in prompts with their delimiters escaped so they're entered again unchanged
+const escapedOptionPartDelimiter = `\|`
+
+// escapedDelimiterPlaceholder stands in for escaped delimiters while an option is split into its parts
+const escapedDelimiterPlaceholder = "\x00"
+
+// optionEmojiPattern matches an emoji (with an optional skin tone) at the start of an option's text
+var optionEmojiPattern = regexp.MustCompile(`^(:[^:\s]+:(?::skin-tone-\d:)?)\s*(.+)$`)
+
+// slackLinkPattern matches links formatted by slack (i.e. <https://example.com|example.com>) whose delimiter would
+// otherwise be taken for the delimiter of an option's parts
+var slackLinkPattern = regexp.MustCompile(`<(https?://[^|>\s]+)(?:\|[^>]*)?>`)
+
+// PollOption represents an answer option of a poll. Besides its text, an option can have an emoji, a description
+// shown below it and a link (i.e. to a design doc). Options with only a text are encoded as a plain string like
+// polls stored before options had more than a text
+type PollOption struct {
+	Text        string `json:"text"`
+	Emoji       string `json:"emoji,omitempty"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url,omitempty"`
+}
+
+// plainPollOption has the fields of a PollOption without its json encoding methods
+type plainPollOption PollOption
+
+// MarshalJSON encodes an option as a plain string if it only has a text and as an object otherwise
+func (po PollOption) MarshalJSON() (encoded []byte, err error) {
+	if po.Emoji == "" && po.Description == "" && po.URL == "" {
+		return json.Marshal(po.Text)
+	}
+
+	return json.Marshal(plainPollOption(po))
+}
+
+// UnmarshalJSON decodes an option encoded as a plain string or as an object
+func (po *PollOption) UnmarshalJSON(encoded []byte) (err error) {
+	var text string
+	if err := json.Unmarshal(encoded, &text); err == nil {
+		*po = PollOption{Text: text}
+		return nil
+	}
+
+	var option plainPollOption
+	err = json.Unmarshal(encoded, &option)
+	if err != nil {
+		return err
+	}
+
+	*po = PollOption(option)
+	return nil
+}
+
+// String returns the option's text with its emoji, if any
+func (po PollOption) String() (s string) {
+	if po.Emoji == "" {
+		return po.Text
+	}
+
+	return fmt.Sprintf("%s %s", po.Emoji, po.Text)
+}
+
+// parseOption parses an option entered as "[:emoji:] text [| description] [| link]". The description and link can be in
+// any order since links are recognized by their http(s) scheme
+func parseOption(raw string) (option PollOption) {
+	raw = strings.Replace(raw, escapedOptionPartDelimiter, escapedDelimiterPlaceholder, -1)
+	parts := strings.Split(slackLinkPattern.ReplaceAllString(raw, "$1"), optionPartDelimiter)
+	for i := range parts {
+		parts[i] = strings.Replace(parts[i], escapedDelimiterPlaceholder, optionPartDelimiter, -1)
+	}
+
+	option.Text = strings.TrimSpace(parts[0])
+
+	descriptions := make([]string, 0)
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if url := parseOptionURL(part); url != "" && option.URL == "" {
+			option.URL = url
+		} else if part != "" {
+			descriptions = append(descriptions, part)
+		}
+	}
+
+	option.Description = strings.Join(descriptions, fmt.Sprintf(" %s ", optionPartDelimiter))
+
+	// A leading emoji is kept apart from the text unless it's the whole text
+	if m := optionEmojiPattern.FindStringSubmatch(option.Text); m != nil {
+		option.Emoji, option.Text = m[1], strings.TrimSpace(m[2])
+	}
+
+	return option
+}
+
+// parseOptionURL returns the link of an option part or an empty string if the part isn't a link
+func parseOptionURL(part string) (url string) {
+	if strings.HasPrefix(part, "https://") || strings.HasPrefix(part, "http://") {
+		return part
+	}
+
+	return ""
+}
+
+// literalOptions returns the options of a slash command as they were typed. Unlike options entered in a prompt, they're
+// never split into a description or a link so existing usages with a | in an option keep the same option text
+func literalOptions(rawOptions []string) (options []PollOption) {
+	options = make([]PollOption, 0, len(rawOptions))
+	for _, raw := range rawOptions {
+		options = append(options, PollOption{Text: raw})
+	}
+
+	return options
+}
+
+// parseOptions parses options entered as text in a prompt
+func parseOptions(rawOptions []string) (options []PollOption) {
+	options = make([]PollOption, 0, len(rawOptions))
+	for _, raw := range rawOptions {
+		options = append(options, parseOption(raw))
+	}
+
+	return options
+}
+
+// formatOptionLine formats an option the way it's entered so that it can be edited
+func formatOptionLine(option PollOption) (line string) {
+	parts := []string{escapeOptionPart(option.String())}
+	if option.Description != "" {
+		parts = append(parts, escapeOptionPart(option.Description))
+	}
+
+	if option.URL != "" {
+		parts = append(parts, escapeOptionPart(option.URL))
+	}
+
+	return strings.Join(parts, fmt.Sprintf(" %s ", optionPartDelimiter))
+}
+
+// escapeOptionPart escapes the delimiters in a part of an option so it isn't split when it's entered again
+func escapeOptionPart(part string) (escaped string) {
+	return strings.Replace(part, optionPartDelimiter, escapedOptionPartDelimiter, -1)
+}
+
+// formatOptionLines formats options the way they're entered in a prompt (one per line)
+func formatOptionLines(options []PollOption) (lines string) {
+	formatted := make([]string, 0, len(options))
+	for _, option := range options {
+		formatted = append(formatted, formatOptionLine(option))
+	}
+
+	return strings.Join(formatted, "\n")
+}
+
+// formatOptionLabel formats an option's emoji and text for display in a poll message. The text links to the option's URL, if any
+func formatOptionLabel(option PollOption) (label string) {
+	label = option.Text
+	if option.URL != "" {
+		label = fmt.Sprintf("<%s|%s>", option.URL, option.Text)
+	}
+
+	if option.Emoji != "" {
+		label = fmt.Sprintf("%s %s", option.Emoji, label)
+	}
+
+	return label
+}
@@ -0,0 +1,108 @@
+package marcopoller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOption(t *testing.T) {
+	testCases := []struct {
+		name     string
+		raw      string
+		expected PollOption
+	}{
+		{"Text only", " Pizza ", PollOption{Text: "Pizza"}},
+		{"With emoji", ":pizza: Pizza", PollOption{Text: "Pizza", Emoji: ":pizza:"}},
+		{"With skin tone emoji", ":+1::skin-tone-3: Yes", PollOption{Text: "Yes", Emoji: ":+1::skin-tone-3:"}},
+		{"Emoji only", ":pizza:", PollOption{Text: ":pizza:"}},
+		{"With description", "Design A | Keeps the current schema", PollOption{Text: "Design A", Description: "Keeps the current schema"}},
+		{"With link", "Design A | https://docs.example.com/a", PollOption{Text: "Design A", URL: "https://docs.example.com/a"}},
+		{"With slack formatted link", "Design A | <https://docs.example.com/a|docs.example.com/a>", PollOption{Text: "Design A", URL: "https://docs.example.com/a"}},
+		{"With slack formatted link only", "Design A | <https://docs.example.com/a>", PollOption{Text: "Design A", URL: "https://docs.example.com/a"}},
+		{"With everything", ":a: Design A | Keeps the current schema | https://docs.example.com/a", PollOption{Text: "Design A", Emoji: ":a:", Description: "Keeps the current schema", URL: "https://docs.example.com/a"}},
+		{"With link before description", "Design A | http://docs.example.com/a | Keeps the current schema", PollOption{Text: "Design A", Description: "Keeps the current schema", URL: "http://docs.example.com/a"}},
+		{"With empty parts", "Design A | | ", PollOption{Text: "Design A"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, parseOption(tc.raw))
+		})
+	}
+}
+
+func TestLiteralOptions(t *testing.T) {
+	options := literalOptions([]string{"A|B", ":a: Design A | https://docs.example.com/a"})
+
+	assert.Equal(t, []PollOption{{Text: "A|B"}, {Text: ":a: Design A | https://docs.example.com/a"}}, options)
+
+	encoded, err := json.Marshal(options)
+	require.NoError(t, err)
+
+	var decoded []PollOption
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Equal(t, options, decoded)
+
+	// Options prefilled in the edit prompt are entered again unchanged
+	assert.Equal(t, "A\\|B", formatOptionLine(options[0]))
+	assert.Equal(t, options[0], parseOption(formatOptionLine(options[0])))
+}
+
+func TestParseOptionWithEscapedDelimiters(t *testing.T) {
+	option := PollOption{Text: "A | B", Description: "Either | or", URL: "https://docs.example.com/a"}
+
+	assert.Equal(t, "A \\| B | Either \\| or | https://docs.example.com/a", formatOptionLine(option))
+	assert.Equal(t, option, parseOption(formatOptionLine(option)))
+}
+
+func TestFormatOptionLines(t *testing.T) {
+	options := parseOptions([]string{"Pizza", ":a: Design A | Keeps the current schema | https://docs.example.com/a", "Design B | https://docs.example.com/b"})
+
+	assert.Equal(t, "Pizza\n:a: Design A | Keeps the current schema | https://docs.example.com/a\nDesign B | https://docs.example.com/b", formatOptionLines(options))
+	assert.Equal(t, options, parseOptions([]string{formatOptionLine(options[0]), formatOptionLine(options[1]), formatOptionLine(options[2])}))
+}
+
+func TestFormatOptionLabel(t *testing.T) {
+	assert.Equal(t, "Pizza", formatOptionLabel(PollOption{Text: "Pizza"}))
+	assert.Equal(t, ":a: <https://docs.example.com/a|Design A>", formatOptionLabel(PollOption{Text: "Design A", Emoji: ":a:", URL: "https://docs.example.com/a"}))
+	assert.Equal(t, ":a: Design A", PollOption{Text: "Design A", Emoji: ":a:"}.String())
+}
+
+func TestPollOptionEncoding(t *testing.T) {
+	encoded, err := json.Marshal([]PollOption{{Text: "Pizza"}, {Text: "Design A", Emoji: ":a:", URL: "https://docs.example.com/a"}})
+	require.NoError(t, err)
+
+	assert.Equal(t, "[\"Pizza\",{\"text\":\"Design A\",\"emoji\":\":a:\",\"url\":\"https://docs.example.com/a\"}]", string(encoded))
+}
+
+func TestDecodePollWithLegacyOptions(t *testing.T) {
+	poll, err := decodePoll("{\"id\":\"un\",\"question\":\"Lunch?\",\"options\":[\"Pizza\",\"Tacos\"],\"creator\":\"marco\"}")
+	require.NoError(t, err)
+
+	assert.Equal(t, []PollOption{{Text: "Pizza"}, {Text: "Tacos"}}, poll.Options)
+}
+
+func TestDecodePollWithRichOptions(t *testing.T) {
+	poll, err := decodePoll("{\"id\":\"un\",\"question\":\"Which design?\",\"options\":[\"Neither\",{\"text\":\"Design A\",\"emoji\":\":a:\",\"description\":\"Keeps the current schema\",\"url\":\"https://docs.example.com/a\"}],\"creator\":\"marco\"}")
+	require.NoError(t, err)
+
+	assert.Equal(t, []PollOption{{Text: "Neither"}, {Text: "Design A", Emoji: ":a:", Description: "Keeps the current schema", URL: "https://docs.example.com/a"}}, poll.Options)
+}
+
+func TestDecodePollWithInvalidOptions(t *testing.T) {
+	_, err := decodePoll("{\"id\":\"un\",\"question\":\"Lunch?\",\"options\":[42]}")
+
+	assert.Error(t, err)
+}
+
+func TestRenderPollWithRichOptions(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Which design?", Options: []PollOption{{Text: "Design A", Emoji: ":a:", Description: "Keeps the current schema", URL: "https://docs.example.com/a"}, {Text: "Neither"}}, Creator: "marco", Features: PollFeatures{Anonymous: true}}
+	render, err := json.Marshal(renderPoll(poll, map[string][]Voter{"0": []Voter{Voter{userID: "U1"}}}, false, defaultMaxVoterAvatars))
+	require.NoError(t, err)
+
+	assert.Contains(t, string(render), "{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • :a: \\u003chttps://docs.example.com/a|Design A\\u003e\\nKeeps the current schema\"}")
+	assert.Contains(t, string(render), "{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Neither\"}")
+}
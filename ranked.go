@@ -0,0 +1,274 @@
+package marcopoller
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// runoffRound represents a round of an instant-runoff tally
+type runoffRound struct {
+	// counts holds the number of ballots for each option still in the running
+	counts map[string]int
+
+	// eliminated holds the options eliminated at the end of the round
+	eliminated []string
+
+	// winner is set when an option has a majority of the ballots in this round
+	winner string
+}
+
+// RunoffRound represents a round of the instant-runoff tally of a ranked choice poll, with options by text
+type RunoffRound struct {
+	// Counts holds the number of ballots for each option still in the running, in the poll's order
+	Counts []OptionResult `json:"counts"`
+
+	// Eliminated holds the options eliminated at the end of the round
+	Eliminated []string `json:"eliminated,omitempty"`
+}
+
+// toggleRankForValue toggles a vote from a user's ranking (the delimited string of a user's votes in order of preference). A new
+// vote is ranked last while an existing vote is removed from the ranking, moving all lower-ranked votes up by one. If maxVotes
+// is greater than 0 and the ranking already has that many votes, an error is returned when adding a vote
+func toggleRankForValue(userRanking string, voteToToggle string, maxVotes int) (newUserRanking string, err error) {
+	ranking := make([]string, 0)
+	found := false
+
+	if userRanking != "" {
+		for _, v := range strings.Split(userRanking, voteDelimiter) {
+			if v == voteToToggle {
+				found = true
+			} else {
+				ranking = append(ranking, v)
+			}
+		}
+	}
+
+	if !found {
+		if maxVotes > 0 && len(ranking) >= maxVotes {
+			return userRanking, newVoteLimitError(maxVotes)
+		}
+
+		ranking = append(ranking, voteToToggle)
+	}
+
+	return strings.Join(ranking, voteDelimiter), nil
+}
+
+// ballotsFromVotes rebuilds the ranked ballots (option identifiers in order of preference) from the votes on a poll. Ballots
+// are sorted by user identifier for a deterministic tally
+func ballotsFromVotes(votes map[string][]Voter) (ballots [][]string) {
+	rankings := make(map[string]map[int]string)
+	for optionID, voters := range votes {
+		for _, voter := range voters {
+			if _, ok := rankings[voter.userID]; !ok {
+				rankings[voter.userID] = make(map[int]string)
+			}
+
+			rankings[voter.userID][voter.rank] = optionID
+		}
+	}
+
+	userIDs := make([]string, 0, len(rankings))
+	for userID := range rankings {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Strings(userIDs)
+
+	ballots = make([][]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		ranks := make([]int, 0, len(rankings[userID]))
+		for rank := range rankings[userID] {
+			ranks = append(ranks, rank)
+		}
+		sort.Ints(ranks)
+
+		ballot := make([]string, 0, len(ranks))
+		for _, rank := range ranks {
+			ballot = append(ballot, rankings[userID][rank])
+		}
+
+		ballots = append(ballots, ballot)
+	}
+
+	return ballots
+}
+
+// ballotsFromValues rebuilds the ranked ballots from all of a poll's stored values (poll info and votes). Ballots are
+// sorted by user identifier for a deterministic tally
+func ballotsFromValues(values map[string]string) (ballots [][]string) {
+	userIDs := make([]string, 0, len(values))
+	for key, userVotes := range values {
+		if key != pollInfoKey && userVotes != "" {
+			userIDs = append(userIDs, key)
+		}
+	}
+	sort.Strings(userIDs)
+
+	ballots = make([][]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		ballots = append(ballots, strings.Split(values[userID], voteDelimiter))
+	}
+
+	return ballots
+}
+
+// runoffFromValues runs the instant-runoff tally of a ranked choice poll given all of its stored values (poll info and
+// votes). It returns the rounds with options by text along with the text of the winning option, if any
+func runoffFromValues(poll Poll, values map[string]string) (rounds []RunoffRound, winner string) {
+	optionIDs := make([]string, 0, len(poll.Options))
+	for i := range poll.Options {
+		optionIDs = append(optionIDs, strconv.Itoa(i))
+	}
+
+	return newRunoffRounds(poll, instantRunoff(optionIDs, ballotsFromValues(values)))
+}
+
+// newRunoffRounds returns the rounds of an instant-runoff tally with options by text along with the text of the
+// winning option, if any
+func newRunoffRounds(poll Poll, tally []runoffRound) (rounds []RunoffRound, winner string) {
+	rounds = make([]RunoffRound, 0, len(tally))
+	for _, round := range tally {
+		counts := make([]OptionResult, 0, len(round.counts))
+		for i, opt := range poll.Options {
+			if count, ok := round.counts[strconv.Itoa(i)]; ok {
+				counts = append(counts, OptionResult{Option: opt.Text, Votes: count})
+			}
+		}
+
+		eliminated := make([]string, 0, len(round.eliminated))
+		for _, optionID := range round.eliminated {
+			i, _ := strconv.Atoi(optionID)
+			eliminated = append(eliminated, poll.Options[i].Text)
+		}
+
+		rounds = append(rounds, RunoffRound{Counts: counts, Eliminated: eliminated})
+		if round.winner != "" {
+			i, _ := strconv.Atoi(round.winner)
+			winner = poll.Options[i].Text
+		}
+	}
+
+	return rounds, winner
+}
+
+// formatRunoffRound formats a round of an instant-runoff tally for display, rounds starting at 1
+func formatRunoffRound(number int, round RunoffRound) (formatted string) {
+	counts := make([]string, 0, len(round.Counts))
+	for _, count := range round.Counts {
+		counts = append(counts, fmt.Sprintf("%s: %d", count.Option, count.Votes))
+	}
+
+	formatted = fmt.Sprintf("Round %d: %s", number, strings.Join(counts, ", "))
+	if len(round.Eliminated) > 0 {
+		formatted = fmt.Sprintf("%s (eliminated: %s)", formatted, strings.Join(round.Eliminated, ", "))
+	}
+
+	return formatted
+}
+
+// formatRunoffWinner formats the outcome of an instant-runoff tally for display
+func formatRunoffWinner(winner string) (formatted string) {
+	if winner == "" {
+		return "No winner"
+	}
+
+	return fmt.Sprintf(":trophy: Winner: *%s*", winner)
+}
+
+// instantRunoff runs an instant-runoff tally of the ballots. Each round counts every ballot for its highest-ranked option
+// still in the running. An option with more than half of the counted ballots wins. Otherwise, the options with the
+// fewest ballots are eliminated and the tally moves on to the next round. The tally ends without a winner if there are no
+// ballots left or if all remaining options are tied
+func instantRunoff(optionIDs []string, ballots [][]string) (rounds []runoffRound) {
+	running := make(map[string]bool)
+	for _, optionID := range optionIDs {
+		running[optionID] = true
+	}
+
+	rounds = make([]runoffRound, 0)
+	for len(running) > 0 {
+		round := runoffRound{counts: make(map[string]int), eliminated: make([]string, 0)}
+		for optionID := range running {
+			round.counts[optionID] = 0
+		}
+
+		total := 0
+		for _, ballot := range ballots {
+			for _, optionID := range ballot {
+				if running[optionID] {
+					round.counts[optionID]++
+					total++
+					break
+				}
+			}
+		}
+
+		if total == 0 {
+			rounds = append(rounds, round)
+			return rounds
+		}
+
+		min, max, leader := total, 0, ""
+		for _, optionID := range optionIDs {
+			if !running[optionID] {
+				continue
+			}
+
+			count := round.counts[optionID]
+			if count > max {
+				max, leader = count, optionID
+			}
+
+			if count < min {
+				min = count
+			}
+		}
+
+		if max*2 > total {
+			round.winner = leader
+			rounds = append(rounds, round)
+			return rounds
+		}
+
+		for _, optionID := range optionIDs {
+			if running[optionID] && round.counts[optionID] == min {
+				round.eliminated = append(round.eliminated, optionID)
+			}
+		}
+
+		rounds = append(rounds, round)
+
+		// All remaining options are tied so there's nothing left to eliminate
+		if len(round.eliminated) == len(running) {
+			return rounds
+		}
+
+		for _, optionID := range round.eliminated {
+			delete(running, optionID)
+		}
+	}
+
+	return rounds
+}
+
+// renderRunoff renders the rounds of an instant-runoff tally to slack blocks
+func renderRunoff(poll Poll, tally []runoffRound) (blocks []slack.Block) {
+	rounds, winner := newRunoffRounds(poll, tally)
+
+	// Rounds share a section since a tally can have as many rounds as the poll has options
+	lines := []string{"*Instant-runoff results*"}
+	for i, round := range rounds {
+		lines = append(lines, formatRunoffRound(i+1, round))
+	}
+
+	blocks = make([]slack.Block, 0)
+	blocks = append(blocks, slack.NewDividerBlock())
+	blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", strings.Join(lines, "\n"), false, false), nil, nil))
+	blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", formatRunoffWinner(winner), false, false), nil, nil))
+
+	return blocks
+}
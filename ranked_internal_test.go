@@ -0,0 +1,97 @@
+package marcopoller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToggleRankForValue(t *testing.T) {
+	testCases := []struct {
+		name           string
+		existingRanks  string
+		voteToToggle   string
+		expectedOutput string
+	}{
+		{"First choice", "", "2", "2"},
+		{"Second choice ranked last", "2", "0", "2,0"},
+		{"Ranking order is kept", "2,0", "1", "2,0,1"},
+		{"Remove first choice", "2,0,1", "2", "0,1"},
+		{"Remove middle choice", "2,0,1", "0", "2,1"},
+		{"Remove only choice", "1", "1", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			output, err := toggleRankForValue(tc.existingRanks, tc.voteToToggle, 0)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedOutput, output)
+		})
+	}
+}
+
+func TestToggleRankForValueWithLimit(t *testing.T) {
+	output, err := toggleRankForValue("2,0", "1", 2)
+	assert.EqualError(t, err, "You can only vote for up to 2 options on this poll")
+	assert.Equal(t, "2,0", output)
+
+	output, err = toggleRankForValue("2,0", "2", 2)
+	require.NoError(t, err)
+	assert.Equal(t, "0", output)
+}
+
+func TestBallotsFromVotes(t *testing.T) {
+	votes := map[string][]Voter{
+		"0": []Voter{Voter{userID: "user2", rank: 1}, Voter{userID: "user1", rank: 0}},
+		"1": []Voter{Voter{userID: "user1", rank: 1}},
+		"2": []Voter{Voter{userID: "user2", rank: 0}, Voter{userID: "user1", rank: 2}},
+	}
+
+	assert.Equal(t, [][]string{[]string{"0", "1", "2"}, []string{"2", "0"}}, ballotsFromVotes(votes))
+}
+
+func TestInstantRunoff(t *testing.T) {
+	testCases := []struct {
+		name               string
+		ballots            [][]string
+		expectedRounds     int
+		expectedWinner     string
+		expectedEliminated [][]string
+	}{
+		{"First round majority", [][]string{{"0", "1"}, {"0"}, {"1", "0"}}, 1, "0", [][]string{{}}},
+		{"Eliminations transfer votes", [][]string{{"0"}, {"0"}, {"1"}, {"1"}, {"2", "1"}}, 2, "1", [][]string{{"2"}, {}}},
+		{"Options without first choices are eliminated together", [][]string{{"0"}, {"0"}, {"1"}, {"1"}, {"2", "1"}, {"1", "2"}, {"0", "2"}}, 2, "1", [][]string{{"2"}, {}}},
+		{"Complete tie", [][]string{{"0"}, {"1"}}, 2, "", [][]string{{"2"}, {"0", "1"}}},
+		{"No ballots", [][]string{}, 1, "", [][]string{{}}},
+		{"Exhausted ballots aren't counted", [][]string{{"2"}, {"0"}, {"0"}, {"0"}, {"1"}, {"1"}}, 2, "0", [][]string{{"2"}, {}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rounds := instantRunoff([]string{"0", "1", "2"}, tc.ballots)
+			require.Len(t, rounds, tc.expectedRounds)
+
+			for i, round := range rounds {
+				assert.Equal(t, tc.expectedEliminated[i], round.eliminated)
+			}
+
+			assert.Equal(t, tc.expectedWinner, rounds[len(rounds)-1].winner)
+		})
+	}
+}
+
+func TestRenderClosedRankedChoicePoll(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Where to?", Options: []PollOption{{Text: "Paris"}, {Text: "Rome"}, {Text: "Oslo"}}, Creator: "marco", Features: PollFeatures{RankedChoice: true, Anonymous: true}}
+	blocks := renderPoll(poll, map[string][]Voter{
+		"0": []Voter{Voter{userID: "user1", rank: 0}, Voter{userID: "user2", rank: 0}},
+		"1": []Voter{Voter{userID: "user3", rank: 0}, Voter{userID: "user4", rank: 0}, Voter{userID: "user5", rank: 1}},
+		"2": []Voter{Voter{userID: "user5", rank: 0}},
+	}, true, defaultMaxVoterAvatars)
+
+	render, err := json.Marshal(blocks)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*Where to?*\"}},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Paris\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"`2 votes`\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Rome\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"`3 votes`\"}]},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\" • Oslo\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"`1 vote`\"}]},{\"type\":\"divider\"},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*Instant-runoff results*\\nRound 1: Paris: 2, Rome: 2, Oslo: 1 (eliminated: Oslo)\\nRound 2: Paris: 2, Rome: 3\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\":trophy: Winner: *Rome*\"}},{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Created by \\u003c@marco\\u003e (voting closed)\"}]}]", string(render))
+}
@@ -0,0 +1,273 @@
+package marcopoller
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexandre-normand/slackscot/store"
+	"github.com/alexandre-normand/slackscot/store/datastoredb"
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+	otel "go.opentelemetry.io/otel/metric/global"
+	"google.golang.org/api/option"
+)
+
+// Reminder flags and persistence
+const (
+	remindFlag         = "--remind"
+	remindersDelimiter = ","
+
+	remindersKindName = "marcoPollerReminders"
+)
+
+// PermalinkFinder is implemented by any value that has the GetPermalink method. Reminders link to the poll message
+// when the Messenger is also a PermalinkFinder (like a slack-go/slack.Client)
+type PermalinkFinder interface {
+	// GetPermalink returns the permalink of a message. See https://pkg.go.dev/github.com/slack-go/slack?tab=doc#Client.GetPermalink
+	GetPermalink(params *slack.PermalinkParameters) (permalink string, err error)
+}
+
+// OptionReminderStorer sets the storer keeping track of the reminders sent to users. It should be dedicated to
+// reminders since polls are found by scanning all silos of the poll storer
+func OptionReminderStorer(storer store.GlobalSiloStringStorer) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.reminderStorer = storer
+		return nil
+	}
+}
+
+// OptionDatastoreReminderStorer sets a datastoredb storer as the storer keeping track of the reminders sent to users
+func OptionDatastoreReminderStorer(datastoreProjectID string, gcloudClientOpts ...option.ClientOption) Option {
+	return func(mp *MarcoPoller) (err error) {
+		meter := otel.GetMeterProvider().Meter("github.com/alexandre-normand/marcopoller")
+
+		mp.reminderStorer, err = datastoredb.NewWithTelemetry(appName, meter, remindersKindName, datastoreProjectID, gcloudClientOpts...)
+		if err != nil {
+			return errors.Wrapf(err, "Error initializing datastore reminder storer on project [%s]", datastoreProjectID)
+		}
+
+		return nil
+	}
+}
+
+// parseReminders parses the value of a remind flag: a comma-delimited list of durations before the deadline. The
+// reminders are returned in seconds before the deadline, from the earliest reminder to the latest
+func parseReminders(value string) (reminders []int64, err error) {
+	seen := make(map[int64]bool)
+	reminders = make([]int64, 0)
+	for _, rawReminder := range strings.Split(value, remindersDelimiter) {
+		d, err := time.ParseDuration(strings.TrimSpace(rawReminder))
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("Invalid reminder [%s], expected a duration before the deadline (i.e. 1h)", rawReminder)
+		}
+
+		if seconds := int64(d / time.Second); !seen[seconds] {
+			seen[seconds] = true
+			reminders = append(reminders, seconds)
+		}
+	}
+
+	sort.Slice(reminders, func(i, j int) bool { return reminders[i] > reminders[j] })
+
+	return reminders, nil
+}
+
+// reminderStep returns the index of the latest reminder due at a time or -1 if no reminder is due yet
+func (pf PollFeatures) reminderStep(now time.Time) (step int) {
+	step = -1
+	for i, seconds := range pf.Reminders {
+		if !now.Before(pf.DeadlineTime().Add(-time.Duration(seconds) * time.Second)) {
+			step = i
+		}
+	}
+
+	return step
+}
+
+// SendReminders sends a direct message to the channel members who haven't voted on open polls with a due reminder. Each
+// user is reminded at most once per reminder of a poll (when many reminders are due at once, only one message is sent). It
+// returns the number of reminders sent. Polls whose reminders can't be sent are skipped and their errors are returned
+// together. The now time should be the current time except for synthetic scenarios like tests
+func (mp *MarcoPoller) SendReminders(now time.Time) (count int, err error) {
+	if mp.reminderStorer == nil {
+		return 0, fmt.Errorf("A reminder storer is needed to send reminders")
+	}
+
+	// Teams get their MemberFinder and Messenger from their token when many teams are supported
+	if mp.tokenStore == nil && (mp.memberFinder == nil || mp.messenger == nil) {
+		return 0, fmt.Errorf("A MemberFinder and a Messenger are needed to send reminders")
+	}
+
+	entries, err := mp.storer.GlobalScan()
+	if err != nil {
+		return 0, err
+	}
+
+	errs := make([]error, 0)
+	for teamID, polls := range mp.pollsByTeam(entries) {
+		tp := mp
+		if teamID != "" {
+			tp, err = mp.forTeam(teamID)
+			if err != nil {
+				log.Printf("Error loading team [%s]: %v", teamID, err)
+				errs = append(errs, errors.Wrapf(err, "Error loading team [%s]", teamID))
+				continue
+			}
+		}
+
+		sent, err := tp.sendReminders(polls, now)
+		count += sent
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return count, combineErrors(errs)
+}
+
+// sendReminders sends the due reminders of polls given the polls' stored values keyed by poll ID. A poll whose reminders
+// can't be sent doesn't stop the reminders of the other polls
+func (mp *MarcoPoller) sendReminders(polls map[string]map[string]string, now time.Time) (count int, err error) {
+	errs := make([]error, 0)
+	for pollID, values := range polls {
+		encodedPoll, ok := values[pollInfoKey]
+		if !ok {
+			continue
+		}
+
+		poll, err := decodePoll(encodedPoll)
+		if err != nil {
+			log.Printf("Error decoding poll [%s]: %v", pollID, err)
+			errs = append(errs, errors.Wrapf(err, "Error decoding poll [%s]", pollID))
+			continue
+		}
+
+		step := poll.Features.reminderStep(now)
+		if poll.Closed || poll.Features.Deadline == 0 || poll.Features.isDue(now) || step == -1 {
+			continue
+		}
+
+		// Without its message, there's no way to know the poll's channel
+		if poll.MsgID == nil {
+			log.Printf("Skipping reminders of poll [%s] posted without a known channel", poll.ID)
+			continue
+		}
+
+		sent, err := mp.remindNonVoters(poll, step)
+		count += sent
+		if err != nil {
+			log.Printf("Error sending reminders of poll [%s]: %v", pollID, err)
+			errs = append(errs, err)
+		}
+	}
+
+	return count, combineErrors(errs)
+}
+
+// remindNonVoters sends a reminder to the channel members who haven't voted on a poll and haven't been sent this
+// reminder step yet. Users who can't vote aren't reminded
+func (mp *MarcoPoller) remindNonVoters(poll Poll, step int) (count int, err error) {
+	values, err := mp.storer.ScanSilo(poll.ID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Error listing votes for poll [%s]", poll.ID)
+	}
+
+	members, err := mp.listChannelMembers(poll.MsgID.ChannelID)
+	if err != nil {
+		return 0, err
+	}
+
+	msg := mp.formatReminder(poll)
+	for _, userID := range members {
+		if values[userID] != "" {
+			continue
+		}
+
+		reminded, err := mp.reminderStorer.GetSiloString(poll.ID, userID)
+		if err != nil && !isNotFound(err) {
+			return count, errors.Wrapf(err, "Error getting reminders of user [%s] on poll [%s]", userID, poll.ID)
+		}
+
+		if lastStep, err := strconv.Atoi(reminded); err == nil && lastStep >= step {
+			continue
+		}
+
+		if eligibility := poll.Features.Eligibility; eligibility != nil && len(eligibility.Voters) > 0 {
+			listed, err := mp.isListedVoter(eligibility.Voters, userID)
+			if err != nil {
+				return count, err
+			}
+
+			if !listed {
+				continue
+			}
+		}
+
+		user, err := mp.userFinder.GetUserInfo(userID)
+		if err != nil {
+			log.Printf("Error getting user info for [%s], skipping reminder of poll [%s]: %v", userID, poll.ID, err)
+			continue
+		}
+
+		if user.IsBot || user.Deleted {
+			continue
+		}
+
+		_, _, err = mp.messenger.PostMessage(userID, slack.MsgOptionText(msg, false))
+		if err != nil {
+			// The reminder isn't recorded so that it's sent on the next run
+			log.Printf("Error sending reminder of poll [%s] to user [%s]: %v", poll.ID, userID, err)
+			continue
+		}
+
+		err = mp.reminderStorer.PutSiloString(poll.ID, userID, strconv.Itoa(step))
+		if err != nil {
+			return count, errors.Wrapf(err, "Error recording reminder of user [%s] on poll [%s]", userID, poll.ID)
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// formatReminder formats the reminder of a poll. It links to the poll message when the Messenger can find its permalink
+// and mentions the poll's channel otherwise
+func (mp *MarcoPoller) formatReminder(poll Poll) (formatted string) {
+	link := fmt.Sprintf("Vote in <#%s>.", poll.MsgID.ChannelID)
+	if permalinkFinder, ok := mp.messenger.(PermalinkFinder); ok {
+		permalink, err := permalinkFinder.GetPermalink(&slack.PermalinkParameters{Channel: poll.MsgID.ChannelID, Ts: poll.MsgID.Timestamp})
+		if err != nil {
+			log.Printf("Error getting permalink of poll [%s], mentioning its channel instead: %v", poll.ID, err)
+		} else {
+			link = fmt.Sprintf("<%s|Vote now>", permalink)
+		}
+	}
+
+	return fmt.Sprintf(":wave: You haven't voted on *%s* yet. Voting closes %s. %s", poll.Question, formatSlackDate(poll.Features.DeadlineTime()), link)
+}
+
+// forgetReminders deletes the reminders sent for a poll
+func (mp *MarcoPoller) forgetReminders(pollID string) (err error) {
+	if mp.reminderStorer == nil {
+		return nil
+	}
+
+	reminders, err := mp.reminderStorer.ScanSilo(pollID)
+	if err != nil {
+		return err
+	}
+
+	for userID := range reminders {
+		// If we see an error, we keep it but still continue deleting reminders
+		if deleteErr := mp.reminderStorer.DeleteSiloString(pollID, userID); err == nil {
+			err = deleteErr
+		}
+	}
+
+	return err
+}
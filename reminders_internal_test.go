@@ -0,0 +1,72 @@
+package marcopoller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// permalinkMessenger is a Messenger that only finds permalinks
+type permalinkMessenger struct {
+	Messenger
+	err error
+}
+
+func (pm permalinkMessenger) GetPermalink(params *slack.PermalinkParameters) (permalink string, err error) {
+	return fmt.Sprintf("https://marcopoller.slack.com/archives/%s/p%s", params.Channel, params.Ts), pm.err
+}
+
+func TestParseReminders(t *testing.T) {
+	reminders, err := parseReminders("1h,24h,30m,1h")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{86400, 3600, 1800}, reminders)
+
+	_, err = parseReminders("1h,tomorrow")
+	assert.EqualError(t, err, "Invalid reminder [tomorrow], expected a duration before the deadline (i.e. 1h)")
+
+	_, err = parseReminders("-1h")
+	assert.EqualError(t, err, "Invalid reminder [-1h], expected a duration before the deadline (i.e. 1h)")
+}
+
+func TestParsePollFlagsWithRemindersWithoutDeadline(t *testing.T) {
+	_, err := parsePollFlags([]string{"--remind=1h"}, time.Now())
+	assert.EqualError(t, err, "Reminders need a deadline (i.e. --deadline=2h --remind=1h)")
+
+	features, err := parsePollFlags([]string{"--deadline=2h", "--remind=1h"}, time.Unix(1566576557, 0))
+	require.NoError(t, err)
+	assert.Equal(t, PollFeatures{Deadline: 1566583757, Reminders: []int64{3600}}, features)
+}
+
+func TestReminderStep(t *testing.T) {
+	features := PollFeatures{Deadline: 1566590000, Reminders: []int64{10800, 3600}}
+
+	assert.Equal(t, -1, features.reminderStep(time.Unix(1566579199, 0)))
+	assert.Equal(t, 0, features.reminderStep(time.Unix(1566579200, 0)))
+	assert.Equal(t, 1, features.reminderStep(time.Unix(1566586400, 0)))
+}
+
+func TestFormatReminder(t *testing.T) {
+	poll := Poll{ID: "un", MsgID: &MsgID{ChannelID: "CID", Timestamp: "1566576557.354007"}, Question: "Lunch?", Features: PollFeatures{Deadline: 1566590000}}
+
+	mp := &MarcoPoller{messenger: permalinkMessenger{}}
+	assert.Equal(t, ":wave: You haven't voted on *Lunch?* yet. Voting closes <!date^1566590000^{date_short_pretty} at {time}|Fri, 23 Aug 2019 19:53:20 UTC>. <https://marcopoller.slack.com/archives/CID/p1566576557.354007|Vote now>", mp.formatReminder(poll))
+
+	mp = &MarcoPoller{messenger: permalinkMessenger{err: fmt.Errorf("message_not_found")}}
+	assert.Equal(t, ":wave: You haven't voted on *Lunch?* yet. Voting closes <!date^1566590000^{date_short_pretty} at {time}|Fri, 23 Aug 2019 19:53:20 UTC>. Vote in <#CID>.", mp.formatReminder(poll))
+}
+
+func TestDeletePollForgetsReminders(t *testing.T) {
+	mp := &MarcoPoller{storer: NewMemoryStorer(), reminderStorer: NewMemoryStorer()}
+	require.NoError(t, mp.storer.PutSiloString("un", pollInfoKey, "{}"))
+	require.NoError(t, mp.reminderStorer.PutSiloString("un", "polo", "0"))
+
+	require.NoError(t, mp.deletePoll("un"))
+
+	reminders, err := mp.reminderStorer.ScanSilo("un")
+	require.NoError(t, err)
+	assert.Empty(t, reminders)
+}
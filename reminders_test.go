@@ -0,0 +1,153 @@
+package marcopoller_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alexandre-normand/marcopoller"
+	mmocks "github.com/alexandre-normand/marcopoller/mocks"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// permalinkMessenger is a Messenger that can also find permalinks
+type permalinkMessenger struct {
+	*mmocks.Messenger
+}
+
+func (pm permalinkMessenger) GetPermalink(params *slack.PermalinkParameters) (permalink string, err error) {
+	return fmt.Sprintf("https://marcopoller.slack.com/archives/%s/p%s", params.Channel, params.Ts), nil
+}
+
+// reminderPoll has a deadline at 1566590000 with reminders 3 hours and 1 hour before it
+const reminderPoll = "{\"id\":\"1566576557-poll1\",\"msgID\":{\"channelID\":\"CID\",\"timestamp\":\"1566576557.354007\"},\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"deadline\":1566590000,\"reminders\":[10800,3600]},\"creator\":\"UID\"}"
+
+func TestSendReminders(t *testing.T) {
+	storer := marcopoller.NewMemoryStorer()
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", reminderPoll))
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "marco", "0"))
+	require.NoError(t, storer.PutSiloString("1566576557-poll2", "pollInfo", "{\"id\":\"1566576557-poll2\",\"question\":\"Without reminders?\",\"options\":[\"Yes\",\"No\"],\"features\":{\"deadline\":1566590000},\"creator\":\"UID\"}"))
+	reminderStorer := marcopoller.NewMemoryStorer()
+
+	memberFinder := &mmocks.MemberFinder{}
+	memberFinder.On("GetUsersInConversation", &slack.GetUsersInConversationParameters{ChannelID: "CID", Limit: 1000}).Return([]string{"marco", "polo", "robot"}, "", nil)
+	defer memberFinder.AssertExpectations(t)
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "polo").Return(&slack.User{ID: "polo"}, nil)
+	userFinder.On("GetUserInfo", "robot").Return(&slack.User{ID: "robot", IsBot: true}, nil)
+	defer userFinder.AssertExpectations(t)
+
+	messenger := &mmocks.Messenger{}
+	messenger.On("PostMessage", "polo", mock.Anything).Return("DID", "1566580000.000100", nil).Twice()
+	defer messenger.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionMemberFinder(memberFinder), marcopoller.OptionMessenger(permalinkMessenger{messenger}),
+		marcopoller.OptionStorer(storer), marcopoller.OptionReminderStorer(reminderStorer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	// No reminder is due more than 3 hours before the deadline
+	sent, err := mp.SendReminders(time.Unix(1566579000, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	// The first reminder is only sent once
+	sent, err = mp.SendReminders(time.Unix(1566579200, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	sent, err = mp.SendReminders(time.Unix(1566580000, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	// The second reminder is sent 1 hour before the deadline
+	sent, err = mp.SendReminders(time.Unix(1566586400, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	// No reminder is sent once the deadline has passed
+	sent, err = mp.SendReminders(time.Unix(1566590000, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	reminded, err := reminderStorer.GetSiloString("1566576557-poll1", "polo")
+	require.NoError(t, err)
+	assert.Equal(t, "1", reminded)
+}
+
+func TestSendRemindersWithoutReminderStorer(t *testing.T) {
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionStorer(marcopoller.NewMemoryStorer()), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	_, err = mp.SendReminders(time.Now())
+	assert.EqualError(t, err, "A reminder storer is needed to send reminders")
+}
+
+func TestSendRemindersWithoutMessenger(t *testing.T) {
+	storer := marcopoller.NewMemoryStorer()
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", reminderPoll))
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionStorer(storer), marcopoller.OptionReminderStorer(marcopoller.NewMemoryStorer()), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	sent, err := mp.SendReminders(time.Unix(1566580000, 0))
+	assert.EqualError(t, err, "A MemberFinder and a Messenger are needed to send reminders")
+	assert.Equal(t, 0, sent)
+}
+
+func TestSendRemindersRetriesFailedMessages(t *testing.T) {
+	storer := marcopoller.NewMemoryStorer()
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", reminderPoll))
+	reminderStorer := marcopoller.NewMemoryStorer()
+
+	memberFinder := &mmocks.MemberFinder{}
+	memberFinder.On("GetUsersInConversation", mock.Anything).Return([]string{"polo"}, "", nil)
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "polo").Return(&slack.User{ID: "polo"}, nil)
+
+	messenger := &mmocks.Messenger{}
+	messenger.On("PostMessage", "polo", mock.Anything).Return("", "", fmt.Errorf("ratelimited")).Once()
+	messenger.On("PostMessage", "polo", mock.Anything).Return("DID", "1566580000.000100", nil).Once()
+	defer messenger.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionMemberFinder(memberFinder), marcopoller.OptionMessenger(messenger),
+		marcopoller.OptionStorer(storer), marcopoller.OptionReminderStorer(reminderStorer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	sent, err := mp.SendReminders(time.Unix(1566580000, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	sent, err = mp.SendReminders(time.Unix(1566580060, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+}
+
+func TestSendRemindersSkipsInvalidPolls(t *testing.T) {
+	storer := marcopoller.NewMemoryStorer()
+	require.NoError(t, storer.PutSiloString("1566576557-brokenPoll", "pollInfo", "{\"id\":\"1566576557-brokenPoll\",\"options\":"))
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", reminderPoll))
+	reminderStorer := marcopoller.NewMemoryStorer()
+
+	memberFinder := &mmocks.MemberFinder{}
+	memberFinder.On("GetUsersInConversation", mock.Anything).Return([]string{"polo"}, "", nil)
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "polo").Return(&slack.User{ID: "polo"}, nil)
+
+	messenger := &mmocks.Messenger{}
+	messenger.On("PostMessage", "polo", mock.Anything).Return("DID", "1566580000.000100", nil).Once()
+	defer messenger.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionMemberFinder(memberFinder), marcopoller.OptionMessenger(messenger),
+		marcopoller.OptionStorer(storer), marcopoller.OptionReminderStorer(reminderStorer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	sent, err := mp.SendReminders(time.Unix(1566580000, 0))
+	assert.EqualError(t, err, "Error decoding poll [1566576557-brokenPoll]: unexpected end of JSON input")
+	assert.Equal(t, 1, sent)
+}
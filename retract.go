@@ -0,0 +1,60 @@
+package marcopoller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// handleRemoveVoteRequest handles a request from a user to remove all of their votes on a poll. The poll message is
+// updated and the user is told their votes were removed with a message only visible to them
+func (mp *MarcoPoller) handleRemoveVoteRequest(poll Poll, callback InteractionCallback, w http.ResponseWriter) {
+	if poll.Closed || poll.Features.isDue(actionTime(callback)) {
+		showErrorToUser(callback.ResponseURL, ":warning: Sorry, voting on this poll is closed")
+		return
+	}
+
+	userVotes, err := mp.storer.GetSiloString(poll.ID, callback.User.ID)
+	if err != nil && !isNotFound(err) {
+		log.Printf("Error getting existing votes for user [%s] on poll id [%s]: %v", callback.User.ID, poll.ID, err)
+		showErrorToUser(callback.ResponseURL, ":warning: Error loading existing votes. Please try again.")
+		return
+	}
+
+	if userVotes == "" {
+		showMessageToUser(callback.ResponseURL, fmt.Sprintf("You haven't voted on *%s* yet", poll.Question))
+		return
+	}
+
+	err = mp.storer.DeleteSiloString(poll.ID, callback.User.ID)
+	if err != nil {
+		log.Printf("Error removing votes of user [%s] on poll [%s]: %v", callback.User.ID, poll.ID, err)
+		showErrorToUser(callback.ResponseURL, ":warning: Error removing your vote. Please try again.")
+		return
+	}
+
+	votes, err := mp.listVotes(poll)
+	if err != nil {
+		log.Printf("Error listing votes for poll [%s]: %v", poll.ID, err)
+		showErrorToUser(callback.ResponseURL, ":warning: Error listing votes for poll. Please try again.")
+		return
+	}
+
+	err = mp.updatePollMessage(poll, callback.ResponseURL, renderPoll(poll, votes, false, mp.maxVoterAvatars))
+	if err != nil {
+		log.Printf("Error updating poll [%s] message : %v", poll.ID, err)
+		showErrorToUser(callback.ResponseURL, ":warning: Error updating slack message for poll. Please try again.")
+		return
+	}
+
+	showMessageToUser(callback.ResponseURL, fmt.Sprintf("Your vote on *%s* was removed", poll.Question))
+}
+
+// formatVoteConfirmation formats the confirmation sent to a user after a vote, listing the user's current votes
+func formatVoteConfirmation(poll Poll, userVotes string) (formatted string) {
+	if userVotes == "" {
+		return fmt.Sprintf("You no longer have votes on *%s*", poll.Question)
+	}
+
+	return formatMyVote(poll, userVotes)
+}
@@ -0,0 +1,14 @@
+package marcopoller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatVoteConfirmation(t *testing.T) {
+	poll := Poll{Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos"}}}
+
+	assert.Equal(t, "Your vote on *Lunch?*: Tacos", formatVoteConfirmation(poll, "1"))
+	assert.Equal(t, "You no longer have votes on *Lunch?*", formatVoteConfirmation(poll, ""))
+}
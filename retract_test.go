@@ -0,0 +1,99 @@
+package marcopoller_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/marcopoller"
+	mmocks "github.com/alexandre-normand/marcopoller/mocks"
+	"github.com/alexandre-normand/slackscot/store/mocks"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const retractPollInfo = "{\"id\":\"1566576557-poll1\",\"msgID\":{\"channelID\":\"myLittleChannel\",\"timestamp\":\"1566576557.354007\"},\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\"%s}"
+
+// removeVote sends a request from marco to remove his vote and returns the requests sent to slack
+func removeVote(t *testing.T, storer *mocks.Storer) (slackRequests []string) {
+	server := newSlackServer()
+	defer server.Close()
+
+	callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "marco"}, ResponseURL: server.URL, ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,removevote", Value: "removevote"}}}}
+	callback.Channel.ID = "myLittleChannel"
+
+	payload, _ := json.Marshal(callback)
+	body := fmt.Sprintf("payload=%s", payload)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.HandleInteractions(w, r)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	return server.Requests()
+}
+
+func TestRemoveVote(t *testing.T) {
+	pollInfo := fmt.Sprintf(retractPollInfo, "")
+
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(pollInfo, nil)
+	storer.On("GetSiloString", "1566576557-poll1", "marco").Return("1", nil)
+	storer.On("DeleteSiloString", "1566576557-poll1", "marco").Return(nil)
+	storer.On("ScanSilo", "1566576557-poll1").Return(map[string]string{"pollInfo": pollInfo}, nil)
+	defer storer.AssertExpectations(t)
+
+	slackRequests := removeVote(t, storer)
+
+	require.Len(t, slackRequests, 2)
+	assert.Regexp(t, regexp.MustCompile("\\{\"blocks\".*,\"replace_original\":true}"), slackRequests[0])
+	assert.Equal(t, "{\"response_type\":\"ephemeral\",\"text\":\"Your vote on *To do or not to do?* was removed\",\"replace_original\":false}", slackRequests[1])
+}
+
+func TestRemoveVoteWithoutVote(t *testing.T) {
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(fmt.Sprintf(retractPollInfo, ""), nil)
+	storer.On("GetSiloString", "1566576557-poll1", "marco").Return("", marcopoller.ErrNotFound)
+	defer storer.AssertExpectations(t)
+
+	slackRequests := removeVote(t, storer)
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\"You haven't voted on *To do or not to do?* yet\",\"replace_original\":false}"}, slackRequests)
+}
+
+func TestRemoveVoteOnClosedPoll(t *testing.T) {
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(fmt.Sprintf(retractPollInfo, ",\"closed\":true"), nil)
+	defer storer.AssertExpectations(t)
+
+	slackRequests := removeVote(t, storer)
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\":warning: Sorry, voting on this poll is closed\",\"replace_original\":false}"}, slackRequests)
+}
+
+func TestErrorRemovingVote(t *testing.T) {
+	storer := &mocks.Storer{}
+	storer.On("GetSiloString", "1566576557-poll1", "pollInfo").Return(fmt.Sprintf(retractPollInfo, ""), nil)
+	storer.On("GetSiloString", "1566576557-poll1", "marco").Return("0", nil)
+	storer.On("DeleteSiloString", "1566576557-poll1", "marco").Return(fmt.Errorf("failed to delete"))
+	defer storer.AssertExpectations(t)
+
+	slackRequests := removeVote(t, storer)
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\":warning: Error removing your vote. Please try again.\",\"replace_original\":false}"}, slackRequests)
+}
@@ -0,0 +1,379 @@
+package marcopoller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+)
+
+// Scheduled poll persistence. Scheduled polls are kept by the template storer in their own silo
+const (
+	schedulesSilo = "schedules"
+
+	// scheduleKeyDelimiter separates the channel ID from the template key in the key of a scheduled poll
+	scheduleKeyDelimiter = ":"
+)
+
+// ScheduledPoll represents a poll created from a template in a channel on a recurring schedule. The next run is persisted
+// so that a poll is created at most once per due run, even when runs were missed (i.e. while the server was down)
+type ScheduledPoll struct {
+	Template  string `json:"template"`
+	Schedule  string `json:"schedule"`
+	Timezone  string `json:"timezone,omitempty"`
+	ChannelID string `json:"channelID"`
+	Creator   string `json:"creator"`
+	NextRun   int64  `json:"nextRun"`
+}
+
+// NextRunTime returns the time of the next run of a scheduled poll
+func (sp ScheduledPoll) NextRunTime() (next time.Time) {
+	return time.Unix(sp.NextRun, 0)
+}
+
+// location returns the location the schedule of a scheduled poll is evaluated in. Unknown timezones fall back on UTC
+func (sp ScheduledPoll) location() (location *time.Location) {
+	location, err := time.LoadLocation(sp.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+// scheduleKey returns the key of a template's scheduled poll in a channel
+func scheduleKey(channelID string, templateName string) (key string) {
+	return channelID + scheduleKeyDelimiter + normalizeTemplateName(templateName)
+}
+
+// newScheduledPoll returns a scheduled poll with its first run after the given time
+func newScheduledPoll(templateName string, schedule string, timezone string, channelID string, creator string, now time.Time) (scheduledPoll ScheduledPoll, err error) {
+	cron, err := parseCronSchedule(schedule)
+	if err != nil {
+		return scheduledPoll, err
+	}
+
+	scheduledPoll = ScheduledPoll{Template: templateName, Schedule: strings.Join(strings.Fields(schedule), " "), Timezone: timezone, ChannelID: channelID, Creator: creator}
+
+	next := cron.next(now.In(scheduledPoll.location()))
+	if next.IsZero() {
+		return scheduledPoll, fmt.Errorf("Schedule [%s] never runs", schedule)
+	}
+
+	scheduledPoll.NextRun = next.Unix()
+
+	return scheduledPoll, nil
+}
+
+// RunScheduledPolls creates the polls of due scheduled polls from their template and posts them to their channel. A scheduled
+// poll is created once even if many of its runs are due (i.e. after a downtime) and its next run is saved before the poll is
+// posted so that it's never posted twice. Scheduled polls whose template no longer exists are removed and their creator is
+// told about it. A scheduled poll that can't be run doesn't stop the others from being run. It returns the number of polls
+// created. The now time should be the current time except for synthetic scenarios like tests
+func (mp *MarcoPoller) RunScheduledPolls(now time.Time) (count int, err error) {
+	if mp.templateStorer == nil {
+		return 0, fmt.Errorf("A template storer is needed to run scheduled polls")
+	}
+
+	// Teams get their messenger from their token when many teams are supported
+	if mp.messenger == nil && mp.tokenStore == nil {
+		return 0, fmt.Errorf("A messenger is needed to post scheduled polls")
+	}
+
+	entries, err := mp.templateStorer.GlobalScan()
+	if err != nil {
+		return 0, err
+	}
+
+	errs := make([]error, 0)
+
+	for teamID, silos := range mp.pollsByTeam(entries) {
+		schedules, ok := silos[schedulesSilo]
+		if !ok {
+			continue
+		}
+
+		tp := mp
+		if teamID != "" {
+			tp, err = mp.forTeam(teamID)
+			if err != nil {
+				log.Printf("Error loading team [%s]: %v", teamID, err)
+				errs = append(errs, errors.Wrapf(err, "Error loading team [%s]", teamID))
+				continue
+			}
+		}
+
+		created, err := tp.runScheduledPolls(schedules, now)
+		count += created
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return count, combineErrors(errs)
+}
+
+// runScheduledPolls creates the polls of the due scheduled polls of a team
+func (mp *MarcoPoller) runScheduledPolls(schedules map[string]string, now time.Time) (count int, err error) {
+	errs := make([]error, 0)
+	for key, encoded := range schedules {
+		var scheduledPoll ScheduledPoll
+		err := json.Unmarshal([]byte(encoded), &scheduledPoll)
+		if err != nil {
+			log.Printf("Error parsing scheduled poll [%s] for key [%s]: %v", encoded, key, err)
+			continue
+		}
+
+		if now.Before(scheduledPoll.NextRunTime()) {
+			continue
+		}
+
+		claimed, err := mp.advanceScheduledPoll(key, encoded, scheduledPoll, now)
+		if err != nil {
+			log.Printf("Error advancing scheduled poll [%s]: %v", key, err)
+			errs = append(errs, err)
+			continue
+		}
+
+		// Another instance already created the poll for this run
+		if !claimed {
+			continue
+		}
+
+		template, err := mp.getTemplate(scheduledPoll.Template)
+		if isNotFound(err) {
+			err = mp.removeScheduledPoll(key, scheduledPoll)
+			if err != nil {
+				log.Printf("Error removing scheduled poll [%s] of deleted template [%s]: %v", key, scheduledPoll.Template, err)
+				errs = append(errs, err)
+			}
+
+			continue
+		} else if err != nil {
+			log.Printf("Error getting template [%s] of scheduled poll [%s]: %v", scheduledPoll.Template, key, err)
+			errs = append(errs, errors.Wrapf(err, "Error getting template [%s] of scheduled poll [%s]", scheduledPoll.Template, key))
+			continue
+		}
+
+		err = mp.postScheduledPoll(template, scheduledPoll, now)
+		if err != nil {
+			log.Printf("Error posting scheduled poll [%s]: %v", key, err)
+			errs = append(errs, errors.Wrapf(err, "Error posting scheduled poll [%s]", key))
+			continue
+		}
+
+		count = count + 1
+	}
+
+	return count, combineErrors(errs)
+}
+
+// removeScheduledPoll removes a scheduled poll whose template no longer exists and tells its creator about it
+func (mp *MarcoPoller) removeScheduledPoll(key string, scheduledPoll ScheduledPoll) (err error) {
+	err = mp.templateStorer.DeleteSiloString(schedulesSilo, key)
+	if err != nil {
+		return errors.Wrapf(err, "Error deleting scheduled poll [%s]", key)
+	}
+
+	msg := fmt.Sprintf(":warning: The scheduled poll of *%s* in <#%s> was removed since the template no longer exists. Save the template again and use `/poll schedule` to schedule it again.", scheduledPoll.Template, scheduledPoll.ChannelID)
+	_, _, err = mp.messenger.PostMessage(scheduledPoll.Creator, slack.MsgOptionText(msg, false))
+	if err != nil {
+		return errors.Wrapf(err, "Error telling user [%s] about removed scheduled poll [%s]", scheduledPoll.Creator, key)
+	}
+
+	return nil
+}
+
+// advanceScheduledPoll saves the next run of a due scheduled poll. Claimed is false if the scheduled poll changed since
+// it was read (i.e. when another instance ran it first)
+func (mp *MarcoPoller) advanceScheduledPoll(key string, encoded string, scheduledPoll ScheduledPoll, now time.Time) (claimed bool, err error) {
+	cron, err := parseCronSchedule(scheduledPoll.Schedule)
+	if err != nil {
+		log.Printf("Error parsing schedule [%s] of scheduled poll [%s]: %v", scheduledPoll.Schedule, key, err)
+		return false, nil
+	}
+
+	next := cron.next(now.In(scheduledPoll.location()))
+	if next.IsZero() {
+		log.Printf("Schedule [%s] of scheduled poll [%s] never runs again", scheduledPoll.Schedule, key)
+		return false, nil
+	}
+
+	scheduledPoll.NextRun = next.Unix()
+
+	updated, err := json.Marshal(scheduledPoll)
+	if err != nil {
+		return false, errors.Wrapf(err, "Error encoding scheduled poll [%s]", key)
+	}
+
+	if cas, ok := mp.templateStorer.(CompareAndSwapper); ok {
+		claimed, err = cas.CompareAndSwapSiloString(schedulesSilo, key, encoded, string(updated))
+	} else {
+		claimed, err = true, mp.templateStorer.PutSiloString(schedulesSilo, key, string(updated))
+	}
+
+	if err != nil {
+		return false, errors.Wrapf(err, "Error persisting next run of scheduled poll [%s]", key)
+	}
+
+	return claimed, nil
+}
+
+// postScheduledPoll creates a poll from the template of a scheduled poll and posts it to the scheduled poll's channel. There's no
+// response url for scheduled polls so their message is always updated through the messenger
+func (mp *MarcoPoller) postScheduledPoll(template PollTemplate, scheduledPoll ScheduledPoll, now time.Time) (err error) {
+	features := template.Features
+	if template.Duration > 0 {
+		features.Deadline = now.Add(time.Duration(template.Duration) * time.Second).Unix()
+	}
+
+	poll := Poll{ID: generatePollID(now.Unix()), Question: template.Question, Options: template.Options, Creator: scheduledPoll.Creator, Features: features}
+
+	encodedPoll, err := encodePoll(poll)
+	if err != nil {
+		return errors.Wrap(err, "Error encoding poll")
+	}
+
+	err = mp.storer.PutSiloString(poll.ID, pollInfoKey, encodedPoll)
+	if err != nil {
+		return errors.Wrapf(err, "Error persisting poll [%s]", poll.ID)
+	}
+
+	blocks := renderPoll(poll, map[string][]Voter{}, false, mp.maxVoterAvatars)
+
+	_, timestamp, err := mp.messenger.PostMessage(scheduledPoll.ChannelID, slack.MsgOptionText(poll.Question, false), slack.MsgOptionBlocks(blocks...))
+	if err != nil {
+		// The poll can't be voted on without its message
+		if deleteErr := mp.deletePoll(poll.ID); deleteErr != nil {
+			log.Printf("Error deleting poll [%s] after failing to post it: %v", poll.ID, deleteErr)
+		}
+
+		return errors.Wrapf(err, "Error posting poll [%s] to channel [%s]", poll.ID, scheduledPoll.ChannelID)
+	}
+
+	poll.MsgID = &MsgID{ChannelID: scheduledPoll.ChannelID, Timestamp: timestamp}
+	mp.persistPollMsgID(poll)
+
+	ctx := context.Background()
+	mp.instruments.pollCount.Add(ctx, 1)
+
+	return nil
+}
+
+// schedulePoll schedules a template's poll in a channel on behalf of a user. The schedule is evaluated in the user's timezone
+func (mp *MarcoPoller) schedulePoll(args []string, userID string, channelID string, responseURL string) {
+	if len(args) <= cronFieldCount {
+		showErrorToUser(responseURL, ":warning: Wrong usage. `/poll schedule <template name> <minute> <hour> <day of month> <month> <day of week>` (i.e. `/poll schedule Sprint Retro 0 9 * * mon`)")
+		return
+	}
+
+	templateName := strings.Join(args[:len(args)-cronFieldCount], " ")
+	template, err := mp.getTemplate(templateName)
+	if isNotFound(err) {
+		showErrorToUser(responseURL, fmt.Sprintf(":warning: Template [%s] not found. Use `/poll template` to list the saved templates", templateName))
+		return
+	} else if err != nil {
+		log.Printf("Error getting template [%s]: %v", templateName, err)
+		showErrorToUser(responseURL, ":warning: Error getting template. Please try again.")
+		return
+	}
+
+	timezone := ""
+	if user, err := mp.userFinder.GetUserInfo(userID); err == nil {
+		timezone = user.TZ
+	} else {
+		log.Printf("Error getting user info for [%s], scheduling in UTC: %v", userID, err)
+	}
+
+	scheduledPoll, err := newScheduledPoll(template.Name, strings.Join(args[len(args)-cronFieldCount:], " "), timezone, channelID, userID, time.Now())
+	if err != nil {
+		showErrorToUser(responseURL, fmt.Sprintf(":warning: %s", err.Error()))
+		return
+	}
+
+	encoded, err := json.Marshal(scheduledPoll)
+	if err != nil {
+		log.Printf("Error encoding scheduled poll for template [%s]: %v", template.Name, err)
+		showErrorToUser(responseURL, ":warning: Error encoding scheduled poll. Please report this at https://github.com/alexandre-normand/marcopoller")
+		return
+	}
+
+	err = mp.templateStorer.PutSiloString(schedulesSilo, scheduleKey(channelID, template.Name), string(encoded))
+	if err != nil {
+		log.Printf("Error persisting scheduled poll for template [%s]: %v", template.Name, err)
+		showErrorToUser(responseURL, ":warning: Error saving scheduled poll. Please try again.")
+		return
+	}
+
+	showMessageToUser(responseURL, fmt.Sprintf("Scheduled *%s* in this channel (`%s`). The next poll is created %s", template.Name, scheduledPoll.Schedule, formatSlackDate(scheduledPoll.NextRunTime())))
+}
+
+// unschedulePoll removes the scheduled poll of a template in a channel
+func (mp *MarcoPoller) unschedulePoll(templateName string, channelID string, responseURL string) {
+	key := scheduleKey(channelID, templateName)
+
+	_, err := mp.templateStorer.GetSiloString(schedulesSilo, key)
+	if isNotFound(err) {
+		showErrorToUser(responseURL, fmt.Sprintf(":warning: Template [%s] isn't scheduled in this channel", templateName))
+		return
+	} else if err != nil {
+		log.Printf("Error getting scheduled poll [%s]: %v", key, err)
+		showErrorToUser(responseURL, ":warning: Error getting scheduled poll. Please try again.")
+		return
+	}
+
+	err = mp.templateStorer.DeleteSiloString(schedulesSilo, key)
+	if err != nil {
+		log.Printf("Error deleting scheduled poll [%s]: %v", key, err)
+		showErrorToUser(responseURL, ":warning: Error deleting scheduled poll. Please try again.")
+		return
+	}
+
+	showMessageToUser(responseURL, fmt.Sprintf("Unscheduled *%s* in this channel", templateName))
+}
+
+// showScheduledPolls shows a user all scheduled polls
+func (mp *MarcoPoller) showScheduledPolls(responseURL string) {
+	entries, err := mp.templateStorer.ScanSilo(schedulesSilo)
+	if err != nil {
+		log.Printf("Error listing scheduled polls: %v", err)
+		showErrorToUser(responseURL, ":warning: Error listing scheduled polls. Please try again.")
+		return
+	}
+
+	scheduledPolls := make([]ScheduledPoll, 0)
+	for key, encoded := range entries {
+		var scheduledPoll ScheduledPoll
+		err := json.Unmarshal([]byte(encoded), &scheduledPoll)
+		if err != nil {
+			log.Printf("Error parsing scheduled poll [%s] for key [%s]: %v", encoded, key, err)
+			continue
+		}
+
+		scheduledPolls = append(scheduledPolls, scheduledPoll)
+	}
+
+	showMessageToUser(responseURL, formatScheduledPollList(scheduledPolls))
+}
+
+// formatScheduledPollList formats the list of scheduled polls sorted by their next run
+func formatScheduledPollList(scheduledPolls []ScheduledPoll) (formatted string) {
+	if len(scheduledPolls) == 0 {
+		return "There are no scheduled polls. Schedule a template with `/poll schedule <template name> <minute> <hour> <day of month> <month> <day of week>`"
+	}
+
+	sort.Slice(scheduledPolls, func(i, j int) bool { return scheduledPolls[i].NextRun < scheduledPolls[j].NextRun })
+
+	lines := []string{"*Scheduled polls*"}
+	for _, sp := range scheduledPolls {
+		lines = append(lines, fmt.Sprintf("• `%s` in <#%s> `%s` (next poll %s)", sp.Template, sp.ChannelID, sp.Schedule, formatSlackDate(sp.NextRunTime())))
+	}
+
+	return strings.Join(lines, "\n")
+}
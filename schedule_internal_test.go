@@ -0,0 +1,34 @@
+package marcopoller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewScheduledPoll(t *testing.T) {
+	scheduledPoll, err := newScheduledPoll("Sprint Retro", " 0  9 * *   mon", "America/Montreal", "CID", "marco", time.Date(2020, time.October, 16, 10, 30, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	assert.Equal(t, ScheduledPoll{Template: "Sprint Retro", Schedule: "0 9 * * mon", Timezone: "America/Montreal", ChannelID: "CID", Creator: "marco", NextRun: time.Date(2020, time.October, 19, 13, 0, 0, 0, time.UTC).Unix()}, scheduledPoll)
+}
+
+func TestNewScheduledPollWithUnknownTimezone(t *testing.T) {
+	scheduledPoll, err := newScheduledPoll("Sprint Retro", "0 9 * * mon", "Mars/Olympus", "CID", "marco", time.Date(2020, time.October, 16, 10, 30, 0, 0, time.UTC))
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Date(2020, time.October, 19, 9, 0, 0, 0, time.UTC).Unix(), scheduledPoll.NextRun)
+}
+
+func TestNewScheduledPollNeverRunning(t *testing.T) {
+	_, err := newScheduledPoll("Sprint Retro", "0 0 31 apr *", "", "CID", "marco", time.Now())
+	assert.EqualError(t, err, "Schedule [0 0 31 apr *] never runs")
+}
+
+func TestFormatScheduledPollList(t *testing.T) {
+	assert.Equal(t, "There are no scheduled polls. Schedule a template with `/poll schedule <template name> <minute> <hour> <day of month> <month> <day of week>`", formatScheduledPollList([]ScheduledPoll{}))
+	assert.Equal(t, "*Scheduled polls*\n• `Standup lunch` in <#CID2> `0 11 * * *` (next poll <!date^1602932400^{date_short_pretty} at {time}|Sat, 17 Oct 2020 11:00:00 UTC>)\n• `Sprint Retro` in <#CID> `0 9 * * mon` (next poll <!date^1603098000^{date_short_pretty} at {time}|Mon, 19 Oct 2020 09:00:00 UTC>)",
+		formatScheduledPollList([]ScheduledPoll{{Template: "Sprint Retro", Schedule: "0 9 * * mon", ChannelID: "CID", NextRun: 1603098000}, {Template: "Standup lunch", Schedule: "0 11 * * *", ChannelID: "CID2", NextRun: 1602932400}}))
+}
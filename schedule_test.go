@@ -0,0 +1,232 @@
+package marcopoller_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexandre-normand/marcopoller"
+	mmocks "github.com/alexandre-normand/marcopoller/mocks"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// retroSchedule creates the retro template's poll in CID every monday at 9:00 UTC starting on October 19th 2020
+const retroSchedule = "{\"template\":\"Sprint Retro\",\"schedule\":\"0 9 * * mon\",\"channelID\":\"CID\",\"creator\":\"polo\",\"nextRun\":1603098000}"
+
+func TestRunScheduledPolls(t *testing.T) {
+	storer := marcopoller.NewMemoryStorer()
+	templateStorer := marcopoller.NewMemoryStorer()
+	require.NoError(t, templateStorer.PutSiloString("templates", "sprint retro", retroTemplate))
+	require.NoError(t, templateStorer.PutSiloString("schedules", "CID:sprint retro", retroSchedule))
+
+	messenger := &mmocks.Messenger{}
+	messenger.On("PostMessage", "CID", mock.Anything, mock.Anything).Return("CID", "1603098000.000100", nil).Twice()
+	defer messenger.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionMessenger(messenger),
+		marcopoller.OptionStorer(storer), marcopoller.OptionTemplateStorer(templateStorer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	// Nothing is due before the first run
+	created, err := mp.RunScheduledPolls(time.Date(2020, time.October, 19, 8, 59, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 0, created)
+
+	created, err = mp.RunScheduledPolls(time.Date(2020, time.October, 19, 9, 2, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 1, created)
+
+	// The same run isn't posted twice
+	created, err = mp.RunScheduledPolls(time.Date(2020, time.October, 19, 9, 7, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 0, created)
+
+	// Missed runs only create one poll
+	created, err = mp.RunScheduledPolls(time.Date(2020, time.November, 3, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 1, created)
+
+	scheduledPoll, err := templateStorer.GetSiloString("schedules", "CID:sprint retro")
+	require.NoError(t, err)
+	assert.Equal(t, "{\"template\":\"Sprint Retro\",\"schedule\":\"0 9 * * mon\",\"channelID\":\"CID\",\"creator\":\"polo\",\"nextRun\":1604912400}", scheduledPoll)
+
+	polls, err := storer.GlobalScan()
+	require.NoError(t, err)
+	require.Len(t, polls, 2)
+
+	for _, values := range polls {
+		var poll marcopoller.Poll
+		require.NoError(t, json.Unmarshal([]byte(values["pollInfo"]), &poll))
+
+		assert.Equal(t, "How was the sprint?", poll.Question)
+		assert.Equal(t, "polo", poll.Creator)
+		assert.Equal(t, &marcopoller.MsgID{ChannelID: "CID", Timestamp: "1603098000.000100"}, poll.MsgID)
+	}
+}
+
+func TestRunScheduledPollsWithoutTemplateStorer(t *testing.T) {
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionStorer(marcopoller.NewMemoryStorer()), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	_, err = mp.RunScheduledPolls(time.Now())
+	assert.EqualError(t, err, "A template storer is needed to run scheduled polls")
+}
+
+func TestRunScheduledPollFailingToPost(t *testing.T) {
+	storer := marcopoller.NewMemoryStorer()
+	templateStorer := marcopoller.NewMemoryStorer()
+	require.NoError(t, templateStorer.PutSiloString("templates", "sprint retro", retroTemplate))
+	require.NoError(t, templateStorer.PutSiloString("schedules", "CID:sprint retro", retroSchedule))
+	require.NoError(t, templateStorer.PutSiloString("schedules", "CID2:sprint retro", strings.Replace(retroSchedule, "\"CID\"", "\"CID2\"", 1)))
+
+	messenger := &mmocks.Messenger{}
+	messenger.On("PostMessage", "CID", mock.Anything, mock.Anything).Return("", "", fmt.Errorf("not_in_channel"))
+	messenger.On("PostMessage", "CID2", mock.Anything, mock.Anything).Return("CID2", "1603098000.000100", nil)
+	defer messenger.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionMessenger(messenger),
+		marcopoller.OptionStorer(storer), marcopoller.OptionTemplateStorer(templateStorer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	// The poll that can't be posted doesn't stop the other scheduled poll from being posted
+	created, err := mp.RunScheduledPolls(time.Date(2020, time.October, 19, 9, 2, 0, 0, time.UTC))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Error posting scheduled poll [CID:sprint retro]")
+	assert.Contains(t, err.Error(), "not_in_channel")
+	assert.Equal(t, 1, created)
+
+	// The poll that couldn't be posted is deleted
+	polls, err := storer.GlobalScan()
+	require.NoError(t, err)
+	assert.Len(t, polls, 1)
+}
+
+func TestRunScheduledPollsWithoutMessenger(t *testing.T) {
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionStorer(marcopoller.NewMemoryStorer()), marcopoller.OptionTemplateStorer(marcopoller.NewMemoryStorer()), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	_, err = mp.RunScheduledPolls(time.Now())
+	assert.EqualError(t, err, "A messenger is needed to post scheduled polls")
+}
+
+func TestRunScheduledPollOfDeletedTemplate(t *testing.T) {
+	templateStorer := marcopoller.NewMemoryStorer()
+	require.NoError(t, templateStorer.PutSiloString("schedules", "CID:sprint retro", retroSchedule))
+
+	messenger := &mmocks.Messenger{}
+	messenger.On("PostMessage", "polo", mock.Anything).Return("DMID", "1603098000.000100", nil).Once()
+	defer messenger.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionMessenger(messenger),
+		marcopoller.OptionStorer(marcopoller.NewMemoryStorer()), marcopoller.OptionTemplateStorer(templateStorer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	created, err := mp.RunScheduledPolls(time.Date(2020, time.October, 19, 9, 2, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 0, created)
+
+	// The scheduled poll is removed so that its creator is only told once
+	_, err = templateStorer.GetSiloString("schedules", "CID:sprint retro")
+	assert.Equal(t, marcopoller.ErrNotFound, err)
+
+	created, err = mp.RunScheduledPolls(time.Date(2020, time.October, 26, 9, 2, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, 0, created)
+}
+
+// runScheduleSubcommand sends a /poll command from marco in CID with the text and returns the requests sent to slack
+func runScheduleSubcommand(t *testing.T, templateStorer *marcopoller.MemoryStorer, userFinder *UserFinder, text string) (slackRequests []string) {
+	server := newSlackServer()
+	defer server.Close()
+
+	verifier := &Verifier{}
+	verifier.On("Verify", mock.Anything, mock.Anything).Return(nil)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(marcopoller.NewMemoryStorer()), marcopoller.OptionTemplateStorer(templateStorer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	body := fmt.Sprintf("token=sometoken&team_id=TEAMID3&channel_id=CID&user_id=marco&command=%%2Fpoll&text=%s&response_url=%s&trigger_id=someTriggerID", url.QueryEscape(text), server.URL)
+
+	w := httptest.NewRecorder()
+	mp.StartPoll(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	return server.Requests()
+}
+
+func TestScheduleSubcommand(t *testing.T) {
+	templateStorer := marcopoller.NewMemoryStorer()
+	require.NoError(t, templateStorer.PutSiloString("templates", "sprint retro", retroTemplate))
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "marco").Return(&slack.User{ID: "marco", TZ: "America/Montreal"}, nil)
+	defer userFinder.AssertExpectations(t)
+
+	slackRequests := runScheduleSubcommand(t, templateStorer, userFinder, "schedule sprint retro 0 9 * * mon")
+
+	require.Len(t, slackRequests, 1)
+	assert.Contains(t, slackRequests[0], "\"text\":\"Scheduled *Sprint Retro* in this channel (`0 9 * * mon`). The next poll is created \\u003c!date^")
+
+	encoded, err := templateStorer.GetSiloString("schedules", "CID:sprint retro")
+	require.NoError(t, err)
+
+	var scheduledPoll marcopoller.ScheduledPoll
+	require.NoError(t, json.Unmarshal([]byte(encoded), &scheduledPoll))
+
+	location, err := time.LoadLocation("America/Montreal")
+	require.NoError(t, err)
+
+	next := scheduledPoll.NextRunTime().In(location)
+	assert.Equal(t, "Sprint Retro", scheduledPoll.Template)
+	assert.Equal(t, "America/Montreal", scheduledPoll.Timezone)
+	assert.Equal(t, "marco", scheduledPoll.Creator)
+	assert.Equal(t, time.Monday, next.Weekday())
+	assert.Equal(t, 9, next.Hour())
+	assert.True(t, next.After(time.Now()))
+}
+
+func TestScheduleSubcommandErrors(t *testing.T) {
+	templateStorer := marcopoller.NewMemoryStorer()
+	require.NoError(t, templateStorer.PutSiloString("templates", "sprint retro", retroTemplate))
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "marco").Return(&slack.User{ID: "marco"}, nil)
+
+	testCases := []struct {
+		name            string
+		text            string
+		expectedMessage string
+	}{
+		{"Missing schedule", "schedule sprint retro", "{\"response_type\":\"ephemeral\",\"text\":\":warning: Wrong usage. `/poll schedule \\u003ctemplate name\\u003e \\u003cminute\\u003e \\u003chour\\u003e \\u003cday of month\\u003e \\u003cmonth\\u003e \\u003cday of week\\u003e` (i.e. `/poll schedule Sprint Retro 0 9 * * mon`)\",\"replace_original\":false}"},
+		{"Unknown template", "schedule standup 0 9 * * mon", "{\"response_type\":\"ephemeral\",\"text\":\":warning: Template [standup] not found. Use `/poll template` to list the saved templates\",\"replace_original\":false}"},
+		{"Invalid schedule", "schedule sprint retro 0 25 * * mon", "{\"response_type\":\"ephemeral\",\"text\":\":warning: Invalid hour [25], expected a value between 0 and 23\",\"replace_original\":false}"},
+		{"Unscheduled template", "unschedule sprint retro", "{\"response_type\":\"ephemeral\",\"text\":\":warning: Template [sprint retro] isn't scheduled in this channel\",\"replace_original\":false}"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, []string{tc.expectedMessage}, runScheduleSubcommand(t, templateStorer, userFinder, tc.text))
+		})
+	}
+}
+
+func TestUnscheduleSubcommand(t *testing.T) {
+	templateStorer := marcopoller.NewMemoryStorer()
+	require.NoError(t, templateStorer.PutSiloString("schedules", "CID:sprint retro", retroSchedule))
+
+	slackRequests := runScheduleSubcommand(t, templateStorer, &UserFinder{}, "unschedule Sprint Retro")
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\"Unscheduled *Sprint Retro* in this channel\",\"replace_original\":false}"}, slackRequests)
+
+	_, err := templateStorer.GetSiloString("schedules", "CID:sprint retro")
+	assert.Equal(t, marcopoller.ErrNotFound, err)
+}
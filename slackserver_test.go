@@ -0,0 +1,40 @@
+package marcopoller_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// slackServer is a test server standing in for slack response urls and recording the requests sent to it
+type slackServer struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests []string
+}
+
+// newSlackServer starts a slackServer that callers must close when done
+func newSlackServer() (server *slackServer) {
+	server = &slackServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := ioutil.ReadAll(r.Body)
+
+		server.mutex.Lock()
+		server.requests = append(server.requests, string(reqBody))
+		server.mutex.Unlock()
+
+		fmt.Fprintln(w, "OK")
+	}))
+
+	return server
+}
+
+// Requests returns the body of the requests received so far, in order
+func (s *slackServer) Requests() (requests []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append(requests, s.requests...)
+}
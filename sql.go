@@ -0,0 +1,630 @@
+package marcopoller
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alexandre-normand/slackscot/store"
+	"github.com/alexandre-normand/slackscot/store/datastoredb"
+	"github.com/pkg/errors"
+	"google.golang.org/api/option"
+)
+
+// sqlSchema creates the poll and vote tables. Each vote is a row so that results can be queried directly (i.e.
+// counting votes by option) and the position of a vote in a voter's choices is kept as its rank
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS polls (
+		id TEXT PRIMARY KEY,
+		question TEXT NOT NULL,
+		creator TEXT NOT NULL,
+		closed BOOLEAN NOT NULL DEFAULT FALSE,
+		info TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS votes (
+		poll_id TEXT NOT NULL,
+		user_id TEXT NOT NULL,
+		option_index INTEGER NOT NULL,
+		rank INTEGER NOT NULL,
+		PRIMARY KEY (poll_id, user_id, option_index)
+	)`,
+}
+
+// SQLStorer represents a store.GlobalSiloStringStorer backed by a relational database with poll and vote
+// tables. A poll's silo maps to the poll's row and its votes while the poll info key maps to the poll's
+// encoded content. It supports SQLite 3.24 or later (i.e. github.com/mattn/go-sqlite3) since it uses ? placeholders
+// and SQLite's upsert (INSERT ... ON CONFLICT ... DO UPDATE). Other databases like MySQL, SQL Server or PostgreSQL
+// (whose drivers expect $1 placeholders) aren't supported. Open the database with a busy timeout (i.e.
+// _busy_timeout=5000 with github.com/mattn/go-sqlite3) so that concurrent votes wait for each other instead of
+// failing on a locked database
+type SQLStorer struct {
+	db *sql.DB
+}
+
+// NewSQLStorer returns a new SQLStorer using the database. The poll and vote tables are created if they don't exist
+func NewSQLStorer(db *sql.DB) (ss *SQLStorer, err error) {
+	for _, statement := range sqlSchema {
+		_, err = db.Exec(statement)
+		if err != nil {
+			return nil, errors.Wrap(err, "Error creating schema")
+		}
+	}
+
+	return &SQLStorer{db: db}, nil
+}
+
+// OptionSQLStorer sets a SQLStorer using the database as the implementation of GlobalSiloStringStorer
+func OptionSQLStorer(db *sql.DB) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.storer, err = NewSQLStorer(db)
+		if err != nil {
+			return errors.Wrap(err, "Error initializing sql persistence")
+		}
+
+		return nil
+	}
+}
+
+// GetSiloString returns the poll info or a user's votes for a poll. ErrNotFound is returned if
+// the poll doesn't exist or if the user hasn't voted
+func (ss *SQLStorer) GetSiloString(silo string, key string) (value string, err error) {
+	if key == pollInfoKey {
+		err = ss.db.QueryRow("SELECT info FROM polls WHERE id = ?", silo).Scan(&value)
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+
+		return value, err
+	}
+
+	votes, err := queryVotes(ss.db, "SELECT user_id, option_index FROM votes WHERE poll_id = ? AND user_id = ? ORDER BY rank", silo, key)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := votes[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return value, nil
+}
+
+// PutSiloString stores the poll info or replaces a user's votes for a poll
+func (ss *SQLStorer) PutSiloString(silo string, key string, value string) (err error) {
+	if key == pollInfoKey {
+		poll, err := decodePoll(value)
+		if err != nil {
+			return errors.Wrapf(err, "Error decoding poll [%s]", silo)
+		}
+
+		_, err = ss.db.Exec("INSERT INTO polls (id, question, creator, closed, info) VALUES (?, ?, ?, ?, ?) ON CONFLICT (id) DO UPDATE SET question = excluded.question, creator = excluded.creator, closed = excluded.closed, info = excluded.info", silo, poll.Question, poll.Creator, poll.Closed, value)
+		return err
+	}
+
+	options, err := parseVoteIndexes(value)
+	if err != nil {
+		return errors.Wrapf(err, "Error parsing votes [%s] of user [%s] on poll [%s]", value, key, silo)
+	}
+
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = replaceVotes(tx, silo, key, options)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CompareAndSwapSiloString replaces the poll info or a user's votes for a poll if they're still old. The current value
+// is only replaced by a conditional statement (checking the number of rows it affected) so a value changed concurrently,
+// even on another connection, is never overwritten
+func (ss *SQLStorer) CompareAndSwapSiloString(silo string, key string, old string, new string) (swapped bool, err error) {
+	if key == pollInfoKey {
+		return ss.compareAndSwapPollInfo(silo, old, new)
+	}
+
+	oldOptions, err := parseVoteIndexes(old)
+	if err != nil {
+		return false, errors.Wrapf(err, "Error parsing votes [%s] of user [%s] on poll [%s]", old, key, silo)
+	}
+
+	options, err := parseVoteIndexes(new)
+	if err != nil {
+		return false, errors.Wrapf(err, "Error parsing votes [%s] of user [%s] on poll [%s]", new, key, silo)
+	}
+
+	tx, err := ss.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	// The first statement of the transaction is the conditional write so the votes can't change until it's committed
+	rank := 0
+	switch {
+	case len(oldOptions) > 0:
+		swapped, err = deleteVotesIf(tx, silo, key, oldOptions)
+	case len(options) > 0:
+		swapped, err = insertFirstVoteIfNone(tx, silo, key, options[0])
+		rank = 1
+	default:
+		var count int
+		err = tx.QueryRow("SELECT COUNT(*) FROM votes WHERE poll_id = ? AND user_id = ?", silo, key).Scan(&count)
+		swapped = count == 0
+	}
+
+	if err != nil || !swapped {
+		tx.Rollback()
+		return false, err
+	}
+
+	for ; rank < len(options); rank++ {
+		_, err = tx.Exec("INSERT INTO votes (poll_id, user_id, option_index, rank) VALUES (?, ?, ?, ?)", silo, key, options[rank], rank)
+		if err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+// compareAndSwapPollInfo replaces the poll info of a poll if it's still old. An empty old value only inserts a poll
+// that doesn't exist
+func (ss *SQLStorer) compareAndSwapPollInfo(silo string, old string, new string) (swapped bool, err error) {
+	poll, err := decodePoll(new)
+	if err != nil {
+		return false, errors.Wrapf(err, "Error decoding poll [%s]", silo)
+	}
+
+	var result sql.Result
+	if old == "" {
+		result, err = ss.db.Exec("INSERT INTO polls (id, question, creator, closed, info) VALUES (?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING", silo, poll.Question, poll.Creator, poll.Closed, new)
+	} else {
+		result, err = ss.db.Exec("UPDATE polls SET question = ?, creator = ?, closed = ?, info = ? WHERE id = ? AND info = ?", poll.Question, poll.Creator, poll.Closed, new, silo, old)
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// deleteVotesIf deletes a user's votes for a poll in a transaction if they're exactly the options (in order of rank).
+// It returns true if the votes were deleted
+func deleteVotesIf(tx *sql.Tx, silo string, key string, options []int) (deleted bool, err error) {
+	matches := make([]string, 0, len(options))
+	args := []interface{}{silo, key, silo, key, len(options), silo, key}
+	for rank, option := range options {
+		matches = append(matches, "(option_index = ? AND rank = ?)")
+		args = append(args, option, rank)
+	}
+	args = append(args, len(options))
+
+	result, err := tx.Exec(fmt.Sprintf("DELETE FROM votes WHERE poll_id = ? AND user_id = ? AND (SELECT COUNT(*) FROM votes WHERE poll_id = ? AND user_id = ?) = ? AND (SELECT COUNT(*) FROM votes WHERE poll_id = ? AND user_id = ? AND (%s)) = ?", strings.Join(matches, " OR ")), args...)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == int64(len(options)), err
+}
+
+// insertFirstVoteIfNone inserts the first-ranked vote of a user for a poll in a transaction if the user has no votes.
+// It returns true if the vote was inserted
+func insertFirstVoteIfNone(tx *sql.Tx, silo string, key string, option int) (inserted bool, err error) {
+	result, err := tx.Exec("INSERT INTO votes (poll_id, user_id, option_index, rank) SELECT ?, ?, ?, 0 WHERE NOT EXISTS (SELECT 1 FROM votes WHERE poll_id = ? AND user_id = ?)", silo, key, option, silo, key)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// DeleteSiloString deletes the poll info or a user's votes for a poll
+func (ss *SQLStorer) DeleteSiloString(silo string, key string) (err error) {
+	if key == pollInfoKey {
+		_, err = ss.db.Exec("DELETE FROM polls WHERE id = ?", silo)
+		return err
+	}
+
+	_, err = ss.db.Exec("DELETE FROM votes WHERE poll_id = ? AND user_id = ?", silo, key)
+	return err
+}
+
+// ScanSilo returns the poll info and the votes of all users for a poll
+func (ss *SQLStorer) ScanSilo(silo string) (entries map[string]string, err error) {
+	entries, err = queryVotes(ss.db, "SELECT user_id, option_index FROM votes WHERE poll_id = ? ORDER BY user_id, rank", silo)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := ss.GetSiloString(silo, pollInfoKey)
+	if err == nil {
+		entries[pollInfoKey] = info
+	} else if err != ErrNotFound {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// GlobalScan returns the poll info and votes of all polls
+func (ss *SQLStorer) GlobalScan() (entries map[string]map[string]string, err error) {
+	entries = make(map[string]map[string]string)
+
+	rows, err := ss.db.Query("SELECT id, info FROM polls")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pollID, info string
+		err = rows.Scan(&pollID, &info)
+		if err != nil {
+			return nil, err
+		}
+
+		entries[pollID] = map[string]string{pollInfoKey: info}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	voteRows, err := ss.db.Query("SELECT poll_id, user_id, option_index FROM votes ORDER BY poll_id, user_id, rank")
+	if err != nil {
+		return nil, err
+	}
+	defer voteRows.Close()
+
+	for voteRows.Next() {
+		var pollID, userID string
+		var option int
+		err = voteRows.Scan(&pollID, &userID, &option)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := entries[pollID]; !ok {
+			entries[pollID] = make(map[string]string)
+		}
+
+		entries[pollID][userID] = appendVote(entries[pollID][userID], option)
+	}
+
+	return entries, voteRows.Err()
+}
+
+// Close closes the database
+func (ss *SQLStorer) Close() (err error) {
+	return ss.db.Close()
+}
+
+// replaceVotes replaces a user's votes for a poll in a transaction
+func replaceVotes(tx *sql.Tx, silo string, key string, options []int) (err error) {
+	_, err = tx.Exec("DELETE FROM votes WHERE poll_id = ? AND user_id = ?", silo, key)
+	if err != nil {
+		return err
+	}
+
+	for rank, option := range options {
+		_, err = tx.Exec("INSERT INTO votes (poll_id, user_id, option_index, rank) VALUES (?, ?, ?, ?)", silo, key, option, rank)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// querier is implemented by both sql.DB and sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryVotes runs a query returning user IDs and option indexes ordered by user and rank and returns the
+// votes keyed by user ID
+func queryVotes(q querier, query string, args ...interface{}) (votes map[string]string, err error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	votes = make(map[string]string)
+	for rows.Next() {
+		var userID string
+		var option int
+		err = rows.Scan(&userID, &option)
+		if err != nil {
+			return nil, err
+		}
+
+		votes[userID] = appendVote(votes[userID], option)
+	}
+
+	return votes, rows.Err()
+}
+
+// appendVote appends an option index to a user's delimited votes
+func appendVote(userVotes string, option int) (newUserVotes string) {
+	if userVotes == "" {
+		return strconv.Itoa(option)
+	}
+
+	return userVotes + voteDelimiter + strconv.Itoa(option)
+}
+
+// parseVoteIndexes parses a user's delimited votes into option indexes
+func parseVoteIndexes(userVotes string) (options []int, err error) {
+	options = make([]int, 0)
+	if userVotes == "" {
+		return options, nil
+	}
+
+	for _, vote := range strings.Split(userVotes, voteDelimiter) {
+		option, err := strconv.Atoi(vote)
+		if err != nil {
+			return nil, err
+		}
+
+		options = append(options, option)
+	}
+
+	return options, nil
+}
+
+// MigrateStorage copies all polls and votes from one storer to another (i.e. from the datastore to a SQLStorer).
+// Polls are listed with GlobalScan and each poll's values are read with ScanSilo. The poll info is copied
+// before the votes. It returns the number of polls copied
+func MigrateStorage(from store.GlobalSiloStringStorer, to store.SiloStringStorer) (count int, err error) {
+	entries, err := from.GlobalScan()
+	if err != nil {
+		return 0, errors.Wrap(err, "Error listing polls")
+	}
+
+	silos := make([]string, 0, len(entries))
+	for silo := range entries {
+		silos = append(silos, silo)
+	}
+	sort.Strings(silos)
+
+	for _, silo := range silos {
+		values, err := from.ScanSilo(silo)
+		if err != nil {
+			return count, errors.Wrapf(err, "Error reading poll [%s]", silo)
+		}
+
+		info, ok := values[pollInfoKey]
+		if !ok {
+			continue
+		}
+
+		err = to.PutSiloString(silo, pollInfoKey, info)
+		if err != nil {
+			return count, errors.Wrapf(err, "Error copying poll [%s]", silo)
+		}
+
+		for key, value := range values {
+			if key == pollInfoKey {
+				continue
+			}
+
+			err = to.PutSiloString(silo, key, value)
+			if err != nil {
+				return count, errors.Wrapf(err, "Error copying votes of user [%s] on poll [%s]", key, silo)
+			}
+		}
+
+		count++
+	}
+
+	return count, nil
+}
+
+// MigrateSilos copies every value of every silo from one storer to another as is (i.e. archived polls, templates and
+// schedules, reminders or team tokens). It returns the number of values copied
+func MigrateSilos(from store.GlobalSiloStringStorer, to store.SiloStringStorer) (count int, err error) {
+	entries, err := from.GlobalScan()
+	if err != nil {
+		return 0, errors.Wrap(err, "Error listing silos")
+	}
+
+	silos := make([]string, 0, len(entries))
+	for silo := range entries {
+		silos = append(silos, silo)
+	}
+	sort.Strings(silos)
+
+	for _, silo := range silos {
+		for key, value := range entries[silo] {
+			err = to.PutSiloString(silo, key, value)
+			if err != nil {
+				return count, errors.Wrapf(err, "Error copying [%s] of silo [%s]", key, silo)
+			}
+
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// MigrationStorers holds the storers MigrateFromDatastore copies data to. Data whose storer is nil isn't migrated
+type MigrationStorers struct {
+	// Polls receives the polls and votes (OptionDatastore)
+	Polls store.SiloStringStorer
+
+	// Archive receives the archived polls (OptionDatastoreArchiveStorer)
+	Archive store.SiloStringStorer
+
+	// Templates receives the poll templates and schedules (OptionDatastoreTemplateStorer)
+	Templates store.SiloStringStorer
+
+	// Reminders receives the reminders sent to users (OptionDatastoreReminderStorer)
+	Reminders store.SiloStringStorer
+
+	// Installations receives the bot tokens of the teams Marco Poller is installed on (OptionDatastoreTokenStore)
+	Installations store.SiloStringStorer
+}
+
+// MigrationCounts holds the number of polls copied by MigrateFromDatastore along with the number of values copied for
+// the rest of the data
+type MigrationCounts struct {
+	Polls         int
+	Archive       int
+	Templates     int
+	Reminders     int
+	Installations int
+}
+
+// MigrateFromDatastore copies the data stored in the datastore of a gcloud project to other storers: polls and votes
+// along with the archived polls, templates, schedules, reminders and team tokens kept by the other datastore storers.
+// It returns the counts of data copied, including the data copied before an error
+func MigrateFromDatastore(datastoreProjectID string, to MigrationStorers, gcloudClientOpts ...option.ClientOption) (counts MigrationCounts, err error) {
+	migrations := []struct {
+		kindName string
+		to       store.SiloStringStorer
+		count    *int
+		migrate  func(from store.GlobalSiloStringStorer, to store.SiloStringStorer) (count int, err error)
+	}{
+		{persistenceKindName, to.Polls, &counts.Polls, MigrateStorage},
+		{archiveKindName, to.Archive, &counts.Archive, MigrateSilos},
+		{templatesKindName, to.Templates, &counts.Templates, MigrateSilos},
+		{remindersKindName, to.Reminders, &counts.Reminders, MigrateSilos},
+		{installationsKindName, to.Installations, &counts.Installations, MigrateSilos},
+	}
+
+	for _, m := range migrations {
+		if m.to == nil {
+			continue
+		}
+
+		*m.count, err = migrateFromDatastoreKind(m.kindName, datastoreProjectID, m.to, m.migrate, gcloudClientOpts...)
+		if err != nil {
+			return counts, errors.Wrapf(err, "Error migrating [%s]", m.kindName)
+		}
+	}
+
+	return counts, nil
+}
+
+// migrateFromDatastoreKind copies the data of a datastore kind to another storer with a migration function
+func migrateFromDatastoreKind(kindName string, datastoreProjectID string, to store.SiloStringStorer, migrate func(from store.GlobalSiloStringStorer, to store.SiloStringStorer) (count int, err error), gcloudClientOpts ...option.ClientOption) (count int, err error) {
+	from, err := datastoredb.New(kindName, datastoreProjectID, gcloudClientOpts...)
+	if err != nil {
+		return 0, errors.Wrapf(err, "Error initializing datastore persistence on project [%s]", datastoreProjectID)
+	}
+	defer from.Close()
+
+	return migrate(from, to)
+}
+
+// SQLSiloStorer represents a store.GlobalSiloStringStorer backed by a relational database table of silo, key and
+// value rows. Unlike SQLStorer, it stores any value and is meant for data other than polls (i.e. reminders). Like
+// SQLStorer, it supports SQLite 3.24 or later only
+type SQLSiloStorer struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLSiloStorer returns a new SQLSiloStorer using a table of the database. The table is created if it doesn't exist
+func NewSQLSiloStorer(db *sql.DB, table string) (ss *SQLSiloStorer, err error) {
+	_, err = db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		silo TEXT NOT NULL,
+		key TEXT NOT NULL,
+		value TEXT NOT NULL,
+		PRIMARY KEY (silo, key)
+	)`, table))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error creating table [%s]", table)
+	}
+
+	return &SQLSiloStorer{db: db, table: table}, nil
+}
+
+// GetSiloString returns the value of a key in a silo. ErrNotFound is returned if the key doesn't exist
+func (ss *SQLSiloStorer) GetSiloString(silo string, key string) (value string, err error) {
+	err = ss.db.QueryRow(fmt.Sprintf("SELECT value FROM %s WHERE silo = ? AND key = ?", ss.table), silo, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+
+	return value, err
+}
+
+// PutSiloString stores the value of a key in a silo
+func (ss *SQLSiloStorer) PutSiloString(silo string, key string, value string) (err error) {
+	_, err = ss.db.Exec(fmt.Sprintf("INSERT INTO %s (silo, key, value) VALUES (?, ?, ?) ON CONFLICT (silo, key) DO UPDATE SET value = excluded.value", ss.table), silo, key, value)
+	return err
+}
+
+// DeleteSiloString deletes a key from a silo
+func (ss *SQLSiloStorer) DeleteSiloString(silo string, key string) (err error) {
+	_, err = ss.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE silo = ? AND key = ?", ss.table), silo, key)
+	return err
+}
+
+// ScanSilo returns all entries of a silo
+func (ss *SQLSiloStorer) ScanSilo(silo string) (entries map[string]string, err error) {
+	all, err := ss.scan(fmt.Sprintf("SELECT silo, key, value FROM %s WHERE silo = ?", ss.table), silo)
+	if err != nil {
+		return nil, err
+	}
+
+	entries = all[silo]
+	if entries == nil {
+		entries = make(map[string]string)
+	}
+
+	return entries, nil
+}
+
+// GlobalScan returns the entries of all silos
+func (ss *SQLSiloStorer) GlobalScan() (entries map[string]map[string]string, err error) {
+	return ss.scan(fmt.Sprintf("SELECT silo, key, value FROM %s", ss.table))
+}
+
+// scan runs a query returning silos, keys and values and returns the entries keyed by silo
+func (ss *SQLSiloStorer) scan(query string, args ...interface{}) (entries map[string]map[string]string, err error) {
+	rows, err := ss.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries = make(map[string]map[string]string)
+	for rows.Next() {
+		var silo, key, value string
+		err = rows.Scan(&silo, &key, &value)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := entries[silo]; !ok {
+			entries[silo] = make(map[string]string)
+		}
+
+		entries[silo][key] = value
+	}
+
+	return entries, rows.Err()
+}
+
+// Close does nothing since the database is usually shared with a SQLStorer (which closes it) or other SQLSiloStorers.
+// The database is left for its owner to close
+func (ss *SQLSiloStorer) Close() (err error) {
+	return nil
+}
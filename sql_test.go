@@ -0,0 +1,244 @@
+package marcopoller_test
+
+import (
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/alexandre-normand/marcopoller"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSQLiteStorer returns a new SQLStorer backed by a SQLite database in a temporary directory along with
+// a function to clean it up
+func newSQLiteStorer(t *testing.T) (storer *marcopoller.SQLStorer, db *sql.DB, cleanup func()) {
+	dir, err := ioutil.TempDir("", "marcopoller")
+	require.NoError(t, err)
+
+	db, err = sql.Open("sqlite3", filepath.Join(dir, "marcopoller.db")+"?_busy_timeout=5000")
+	require.NoError(t, err)
+
+	storer, err = marcopoller.NewSQLStorer(db)
+	require.NoError(t, err)
+
+	return storer, db, func() { os.RemoveAll(dir) }
+}
+
+func TestSQLStorer(t *testing.T) {
+	storer, _, cleanup := newSQLiteStorer(t)
+	defer cleanup()
+
+	testStorer(t, storer, marcopoller.ErrNotFound)
+}
+
+func TestSQLStorerCompareAndSwap(t *testing.T) {
+	storer, _, cleanup := newSQLiteStorer(t)
+	defer cleanup()
+	defer storer.Close()
+
+	testCases := []struct {
+		name            string
+		old             string
+		new             string
+		expectedSwapped bool
+		expectedVotes   string
+	}{
+		{"First vote", "", "1,0", true, "1,0"},
+		{"Missing vote", "", "2", false, "1,0"},
+		{"Other votes", "0,1", "2", false, "1,0"},
+		{"Fewer votes", "1", "2", false, "1,0"},
+		{"Current votes", "1,0", "2,1,0", true, "2,1,0"},
+		{"Removed votes", "2,1,0", "", true, ""},
+		{"Still no votes", "", "", true, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			swapped, err := storer.CompareAndSwapSiloString("1566576557-poll1", "marco", tc.old, tc.new)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedSwapped, swapped)
+
+			votes, err := storer.ScanSilo("1566576557-poll1")
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedVotes, votes["marco"])
+		})
+	}
+}
+
+func TestSQLStorerCompareAndSwapPollInfo(t *testing.T) {
+	storer, _, cleanup := newSQLiteStorer(t)
+	defer cleanup()
+	defer storer.Close()
+
+	poll := "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\"],\"creator\":\"UID\"}"
+	editedPoll := "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\",\"Rome\"],\"creator\":\"UID\"}"
+
+	swapped, err := storer.CompareAndSwapSiloString("1566576557-poll1", "pollInfo", "", poll)
+	require.NoError(t, err)
+	assert.True(t, swapped)
+
+	swapped, err = storer.CompareAndSwapSiloString("1566576557-poll1", "pollInfo", "", editedPoll)
+	require.NoError(t, err)
+	assert.False(t, swapped)
+
+	swapped, err = storer.CompareAndSwapSiloString("1566576557-poll1", "pollInfo", poll, editedPoll)
+	require.NoError(t, err)
+	assert.True(t, swapped)
+
+	swapped, err = storer.CompareAndSwapSiloString("1566576557-poll1", "pollInfo", poll, poll)
+	require.NoError(t, err)
+	assert.False(t, swapped)
+
+	info, err := storer.GetSiloString("1566576557-poll1", "pollInfo")
+	require.NoError(t, err)
+	assert.Equal(t, editedPoll, info)
+}
+
+func TestSQLStorerConcurrentCompareAndSwap(t *testing.T) {
+	storer, _, cleanup := newSQLiteStorer(t)
+	defer cleanup()
+	defer storer.Close()
+
+	// Every swap expects no votes so only one of them can win, whichever connection it runs on
+	var wg sync.WaitGroup
+	swaps := make(chan bool, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(option int) {
+			defer wg.Done()
+
+			swapped, err := storer.CompareAndSwapSiloString("1566576557-poll1", "marco", "", fmt.Sprintf("%d,%d", option, option+1))
+			assert.NoError(t, err)
+			swaps <- swapped
+		}(i)
+	}
+
+	wg.Wait()
+	close(swaps)
+
+	swapCount := 0
+	for swapped := range swaps {
+		if swapped {
+			swapCount++
+		}
+	}
+
+	assert.Equal(t, 1, swapCount)
+}
+
+func TestSQLSiloStorer(t *testing.T) {
+	_, db, cleanup := newSQLiteStorer(t)
+	defer cleanup()
+	defer db.Close()
+
+	storer, err := marcopoller.NewSQLSiloStorer(db, "reminders")
+	require.NoError(t, err)
+
+	testStorer(t, storer, marcopoller.ErrNotFound)
+}
+
+func TestSQLSiloStorerCloseLeavesSharedDatabaseOpen(t *testing.T) {
+	pollStorer, db, cleanup := newSQLiteStorer(t)
+	defer cleanup()
+	defer pollStorer.Close()
+
+	reminderStorer, err := marcopoller.NewSQLSiloStorer(db, "reminders")
+	require.NoError(t, err)
+	require.NoError(t, reminderStorer.Close())
+
+	require.NoError(t, pollStorer.PutSiloString("1566576557-poll1", "pollInfo", "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\"],\"creator\":\"UID\"}"))
+	require.NoError(t, db.Ping())
+}
+
+func TestSQLStorerKeepsRankedVotesOrder(t *testing.T) {
+	storer, db, cleanup := newSQLiteStorer(t)
+	defer cleanup()
+	defer storer.Close()
+
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\",\"Rome\",\"Oslo\"],\"features\":{\"multianswers\":false,\"rankedChoice\":true},\"creator\":\"UID\"}"))
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "marco", "2,0,1"))
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "polo", "1"))
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "polo", "2,1"))
+
+	votes, err := storer.GetSiloString("1566576557-poll1", "marco")
+	require.NoError(t, err)
+	assert.Equal(t, "2,0,1", votes)
+
+	votes, err = storer.GetSiloString("1566576557-poll1", "polo")
+	require.NoError(t, err)
+	assert.Equal(t, "2,1", votes)
+
+	var question string
+	var firstChoices int
+	require.NoError(t, db.QueryRow("SELECT question FROM polls WHERE id = ?", "1566576557-poll1").Scan(&question))
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM votes WHERE poll_id = ? AND option_index = 2 AND rank = 0", "1566576557-poll1").Scan(&firstChoices))
+
+	assert.Equal(t, "Where to?", question)
+	assert.Equal(t, 2, firstChoices)
+}
+
+func TestSQLStorerRejectsInvalidValues(t *testing.T) {
+	storer, _, cleanup := newSQLiteStorer(t)
+	defer cleanup()
+	defer storer.Close()
+
+	assert.Error(t, storer.PutSiloString("1566576557-poll1", "pollInfo", "not a poll"))
+	assert.Error(t, storer.PutSiloString("1566576557-poll1", "marco", "0,Paris"))
+}
+
+func TestMigrateStorage(t *testing.T) {
+	from := marcopoller.NewMemoryStorer()
+	require.NoError(t, from.PutSiloString("1566576557-poll1", "pollInfo", "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\",\"Rome\"],\"features\":{\"multianswers\":true},\"creator\":\"UID\"}"))
+	require.NoError(t, from.PutSiloString("1566576557-poll1", "marco", "0,1"))
+	require.NoError(t, from.PutSiloString("1566576557-poll1", "polo", ""))
+	require.NoError(t, from.PutSiloString("TEAMID3.1566576558-poll2", "pollInfo", "{\"id\":\"1566576558-poll2\",\"question\":\"When?\",\"options\":[\"Now\",\"Later\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\",\"closed\":true}"))
+	require.NoError(t, from.PutSiloString("TEAMID3.1566576558-poll2", "marco", "1"))
+	// Votes without a poll are left behind
+	require.NoError(t, from.PutSiloString("1566576559-orphan", "marco", "1"))
+
+	to, _, cleanup := newSQLiteStorer(t)
+	defer cleanup()
+	defer to.Close()
+
+	count, err := marcopoller.MigrateStorage(from, to)
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	entries, err := to.GlobalScan()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		"1566576557-poll1":         {"pollInfo": "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\",\"Rome\"],\"features\":{\"multianswers\":true},\"creator\":\"UID\"}", "marco": "0,1"},
+		"TEAMID3.1566576558-poll2": {"pollInfo": "{\"id\":\"1566576558-poll2\",\"question\":\"When?\",\"options\":[\"Now\",\"Later\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\",\"closed\":true}", "marco": "1"},
+	}, entries)
+}
+
+func TestMigrateSilos(t *testing.T) {
+	from := marcopoller.NewMemoryStorer()
+	require.NoError(t, from.PutSiloString("CHANNEL1", "1566576557-poll1", "{\"id\":\"1566576557-poll1\"}"))
+	require.NoError(t, from.PutSiloString("CHANNEL1", "1566576558-poll2", "{\"id\":\"1566576558-poll2\"}"))
+	require.NoError(t, from.PutSiloString("UID", "retro", "{\"name\":\"retro\"}"))
+
+	_, db, cleanup := newSQLiteStorer(t)
+	defer cleanup()
+	defer db.Close()
+
+	to, err := marcopoller.NewSQLSiloStorer(db, "archive")
+	require.NoError(t, err)
+
+	count, err := marcopoller.MigrateSilos(from, to)
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+
+	entries, err := to.GlobalScan()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{
+		"CHANNEL1": {"1566576557-poll1": "{\"id\":\"1566576557-poll1\"}", "1566576558-poll2": "{\"id\":\"1566576558-poll2\"}"},
+		"UID":      {"retro": "{\"name\":\"retro\"}"},
+	}, entries)
+}
@@ -0,0 +1,291 @@
+package marcopoller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/datastore"
+	"github.com/alexandre-normand/slackscot/store"
+	"github.com/alexandre-normand/slackscot/store/datastoredb"
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/api/option"
+)
+
+// ErrNotFound is returned by a MemoryStorer when getting a key that doesn't exist
+var ErrNotFound = fmt.Errorf("Not found")
+
+// CompareAndSwapper is implemented by storers that can atomically replace a value only if it's still the expected
+// value. A missing key is expected as an empty value. Storers implementing it protect votes from concurrent updates
+type CompareAndSwapper interface {
+	CompareAndSwapSiloString(silo string, key string, old string, new string) (swapped bool, err error)
+}
+
+// MemoryStorer represents an in-memory store.GlobalSiloStringStorer. Its data is lost when the process exits which
+// makes it a good fit for tests and ephemeral deployments
+type MemoryStorer struct {
+	sync.RWMutex
+	data map[string]map[string]string
+}
+
+// NewMemoryStorer returns a new empty MemoryStorer
+func NewMemoryStorer() (ms *MemoryStorer) {
+	return &MemoryStorer{data: make(map[string]map[string]string)}
+}
+
+// GetSiloString returns the value of a key in a silo or ErrNotFound if the key doesn't exist
+func (ms *MemoryStorer) GetSiloString(silo string, key string) (value string, err error) {
+	ms.RLock()
+	defer ms.RUnlock()
+
+	value, ok := ms.data[silo][key]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return value, nil
+}
+
+// PutSiloString stores a value for a key in a silo
+func (ms *MemoryStorer) PutSiloString(silo string, key string, value string) (err error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	if _, ok := ms.data[silo]; !ok {
+		ms.data[silo] = make(map[string]string)
+	}
+
+	ms.data[silo][key] = value
+	return nil
+}
+
+// CompareAndSwapSiloString stores a value for a key in a silo if the current value is old
+func (ms *MemoryStorer) CompareAndSwapSiloString(silo string, key string, old string, new string) (swapped bool, err error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	if ms.data[silo][key] != old {
+		return false, nil
+	}
+
+	if _, ok := ms.data[silo]; !ok {
+		ms.data[silo] = make(map[string]string)
+	}
+
+	ms.data[silo][key] = new
+	return true, nil
+}
+
+// DeleteSiloString deletes a key from a silo. Deleting a key that doesn't exist isn't an error
+func (ms *MemoryStorer) DeleteSiloString(silo string, key string) (err error) {
+	ms.Lock()
+	defer ms.Unlock()
+
+	if s, ok := ms.data[silo]; ok {
+		delete(s, key)
+		if len(s) == 0 {
+			delete(ms.data, silo)
+		}
+	}
+
+	return nil
+}
+
+// ScanSilo returns a copy of all entries of a silo
+func (ms *MemoryStorer) ScanSilo(silo string) (entries map[string]string, err error) {
+	ms.RLock()
+	defer ms.RUnlock()
+
+	entries = make(map[string]string)
+	for k, v := range ms.data[silo] {
+		entries[k] = v
+	}
+
+	return entries, nil
+}
+
+// GlobalScan returns a copy of all entries of all silos
+func (ms *MemoryStorer) GlobalScan() (entries map[string]map[string]string, err error) {
+	ms.RLock()
+	defer ms.RUnlock()
+
+	entries = make(map[string]map[string]string)
+	for silo, values := range ms.data {
+		entries[silo] = make(map[string]string)
+		for k, v := range values {
+			entries[silo][k] = v
+		}
+	}
+
+	return entries, nil
+}
+
+// Close does nothing since there's nothing to release
+func (ms *MemoryStorer) Close() (err error) {
+	return nil
+}
+
+// DatastoreStorer represents a datastoredb storer that can also compare-and-swap values. Values are read and written
+// by the datastoredb storer while compare-and-swaps run in a datastore transaction on the same entities
+type DatastoreStorer struct {
+	*datastoredb.DatastoreDB
+	client *datastore.Client
+	kind   string
+}
+
+// NewDatastoreStorer returns a new DatastoreStorer of a kind of entities in the datastore of a gcloud project
+func NewDatastoreStorer(appName string, meter metric.Meter, kindName string, gcloudProjectID string, gcloudClientOpts ...option.ClientOption) (ds *DatastoreStorer, err error) {
+	dsdb, err := datastoredb.NewWithTelemetry(appName, meter, kindName, gcloudProjectID, gcloudClientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := datastore.NewClient(context.Background(), gcloudProjectID, gcloudClientOpts...)
+	if err != nil {
+		dsdb.Close()
+		return nil, err
+	}
+
+	return &DatastoreStorer{DatastoreDB: dsdb, client: client, kind: kindName}, nil
+}
+
+// CompareAndSwapSiloString stores a value for a key in a silo if the current value is old. The value is read and
+// written in a transaction so a value changed concurrently is never overwritten. A transaction that keeps
+// conflicting with concurrent ones isn't swapped
+func (ds *DatastoreStorer) CompareAndSwapSiloString(silo string, key string, old string, new string) (swapped bool, err error) {
+	k := datastore.NameKey(ds.kind, key, nil)
+	k.Namespace = silo
+
+	_, err = ds.client.RunInTransaction(context.Background(), func(tx *datastore.Transaction) (err error) {
+		swapped, err = compareAndSwapEntry(tx, k, old, new)
+		return err
+	})
+
+	if err == datastore.ErrConcurrentTransaction {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return swapped, nil
+}
+
+// Close closes the datastoredb storer and the client running transactions
+func (ds *DatastoreStorer) Close() (err error) {
+	err = ds.DatastoreDB.Close()
+	if closeErr := ds.client.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// entryTransaction is implemented by datastore.Transaction
+type entryTransaction interface {
+	Get(key *datastore.Key, dst interface{}) (err error)
+	Put(key *datastore.Key, src interface{}) (pendingKey *datastore.PendingKey, err error)
+}
+
+// compareAndSwapEntry stores a value for a datastoredb entity in a transaction if the entity's current value is old.
+// A missing entity has an empty value
+func compareAndSwapEntry(tx entryTransaction, k *datastore.Key, old string, new string) (swapped bool, err error) {
+	var entry datastoredb.EntryValue
+	err = tx.Get(k, &entry)
+	if err != nil && err != datastore.ErrNoSuchEntity {
+		return false, err
+	}
+
+	if entry.Value != old {
+		return false, nil
+	}
+
+	_, err = tx.Put(k, &datastoredb.EntryValue{Value: new})
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// OptionInMemoryStorer sets a MemoryStorer as the implementation of GlobalSiloStringStorer
+func OptionInMemoryStorer() Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.storer = NewMemoryStorer()
+		return nil
+	}
+}
+
+// OptionLevelDB sets a file-backed leveldb as the implementation of GlobalSiloStringStorer. The database is
+// created in a marco-poller directory under storagePath if it doesn't exist
+func OptionLevelDB(storagePath string) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.storer, err = store.NewLevelDB(appName, storagePath)
+		if err != nil {
+			return errors.Wrapf(err, "Error initializing leveldb persistence at [%s]", storagePath)
+		}
+
+		return nil
+	}
+}
+
+// OptionLevelDBReminderStorer sets a leveldb storer as the storer keeping track of the reminders sent to users. The storage
+// path must be different from the poll storage path
+func OptionLevelDBReminderStorer(storagePath string) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.reminderStorer, err = store.NewLevelDB(appName, storagePath)
+		if err != nil {
+			return errors.Wrapf(err, "Error initializing leveldb reminder persistence at [%s]", storagePath)
+		}
+
+		return nil
+	}
+}
+
+// OptionLevelDBTemplateStorer sets a leveldb storer as the storer of poll templates. The storage path must be different
+// from the poll storage path
+func OptionLevelDBTemplateStorer(storagePath string) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.templateStorer, err = store.NewLevelDB(appName, storagePath)
+		if err != nil {
+			return errors.Wrapf(err, "Error initializing leveldb template persistence at [%s]", storagePath)
+		}
+
+		return nil
+	}
+}
+
+// OptionLevelDBArchiveStorer sets a leveldb storer as the storer of archived polls. The storage path must be different
+// from the poll storage path
+func OptionLevelDBArchiveStorer(storagePath string) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.archiveStorer, err = store.NewLevelDB(appName, storagePath)
+		if err != nil {
+			return errors.Wrapf(err, "Error initializing leveldb archive persistence at [%s]", storagePath)
+		}
+
+		return nil
+	}
+}
+
+// OptionLevelDBTokenStore sets a leveldb-backed StorerTokenStore as the implementation of TokenStore. The storage path must
+// be different from the poll storage path
+func OptionLevelDBTokenStore(storagePath string) Option {
+	return func(mp *MarcoPoller) (err error) {
+		storer, err := store.NewLevelDB(appName, storagePath)
+		if err != nil {
+			return errors.Wrapf(err, "Error initializing leveldb token persistence at [%s]", storagePath)
+		}
+
+		mp.tokenStore = NewStorerTokenStore(storer)
+		return nil
+	}
+}
+
+// isNotFound returns true if err is the error returned by any of the supported storers when getting a key that doesn't exist
+func isNotFound(err error) bool {
+	return err == ErrNotFound || err == datastore.ErrNoSuchEntity || err == leveldb.ErrNotFound
+}
@@ -0,0 +1,73 @@
+package marcopoller
+
+import (
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/datastore"
+	"github.com/alexandre-normand/slackscot/store/datastoredb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTransaction is an in-memory entryTransaction of datastoredb entities keyed by name
+type fakeTransaction struct {
+	entries map[string]string
+	getErr  error
+}
+
+func (ft *fakeTransaction) Get(key *datastore.Key, dst interface{}) (err error) {
+	if ft.getErr != nil {
+		return ft.getErr
+	}
+
+	value, ok := ft.entries[key.Name]
+	if !ok {
+		return datastore.ErrNoSuchEntity
+	}
+
+	dst.(*datastoredb.EntryValue).Value = value
+	return nil
+}
+
+func (ft *fakeTransaction) Put(key *datastore.Key, src interface{}) (pendingKey *datastore.PendingKey, err error) {
+	ft.entries[key.Name] = src.(*datastoredb.EntryValue).Value
+	return nil, nil
+}
+
+func TestCompareAndSwapEntry(t *testing.T) {
+	testCases := []struct {
+		name            string
+		entries         map[string]string
+		old             string
+		expectedSwapped bool
+		expectedVotes   string
+	}{
+		{"Unchanged votes", map[string]string{"marco": "1"}, "1", true, "1,2"},
+		{"Concurrently changed votes", map[string]string{"marco": "0"}, "1", false, "0"},
+		{"First vote", map[string]string{}, "", true, "1,2"},
+		{"Concurrent first vote", map[string]string{"marco": "0"}, "", false, "0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tx := &fakeTransaction{entries: tc.entries}
+
+			swapped, err := compareAndSwapEntry(tx, datastore.NameKey(persistenceKindName, "marco", nil), tc.old, "1,2")
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedSwapped, swapped)
+			assert.Equal(t, tc.expectedVotes, tx.entries["marco"])
+		})
+	}
+}
+
+func TestCompareAndSwapEntryWithError(t *testing.T) {
+	tx := &fakeTransaction{entries: map[string]string{}, getErr: fmt.Errorf("unavailable")}
+
+	swapped, err := compareAndSwapEntry(tx, datastore.NameKey(persistenceKindName, "marco", nil), "", "1")
+
+	assert.EqualError(t, err, "unavailable")
+	assert.False(t, swapped)
+	assert.Len(t, tx.entries, 0)
+}
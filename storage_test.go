@@ -0,0 +1,242 @@
+package marcopoller_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alexandre-normand/marcopoller"
+	mmocks "github.com/alexandre-normand/marcopoller/mocks"
+	"github.com/alexandre-normand/slackscot/store"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStorer(t *testing.T) {
+	testStorer(t, marcopoller.NewMemoryStorer(), marcopoller.ErrNotFound)
+}
+
+func TestLevelDBStorer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "marcopoller")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	storer, err := store.NewLevelDB("marco-poller", dir)
+	require.NoError(t, err)
+
+	testStorer(t, storer, nil)
+}
+
+// testStorer runs the storage operations used by MarcoPoller against a storer. The expected not found
+// error is only checked when it's not nil
+func testStorer(t *testing.T, storer store.GlobalSiloStringStorer, expectedNotFoundErr error) {
+	defer storer.Close()
+
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", "{}"))
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "marco", "0,1"))
+	require.NoError(t, storer.PutSiloString("1566576557-poll2", "pollInfo", "{}"))
+
+	value, err := storer.GetSiloString("1566576557-poll1", "marco")
+	require.NoError(t, err)
+	assert.Equal(t, "0,1", value)
+
+	_, err = storer.GetSiloString("1566576557-poll1", "polo")
+	require.Error(t, err)
+	if expectedNotFoundErr != nil {
+		assert.Equal(t, expectedNotFoundErr, err)
+	}
+
+	entries, err := storer.ScanSilo("1566576557-poll1")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"pollInfo": "{}", "marco": "0,1"}, entries)
+
+	require.NoError(t, storer.DeleteSiloString("1566576557-poll1", "marco"))
+
+	all, err := storer.GlobalScan()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]map[string]string{"1566576557-poll1": {"pollInfo": "{}"}, "1566576557-poll2": {"pollInfo": "{}"}}, all)
+}
+
+func TestVotesWithInMemoryStorer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	verifier := &Verifier{}
+	verifier.On("Verify", mock.Anything, mock.Anything).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "marco").Return(&slack.User{ID: "marco", Profile: slack.UserProfile{Image24: "http://image.me", RealName: "Marco Poller"}}, nil)
+
+	storer := marcopoller.NewMemoryStorer()
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\",\"Rome\",\"Oslo\"],\"features\":{\"multianswers\":true},\"creator\":\"UID\"}"))
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	for _, vote := range []string{"2", "0"} {
+		callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "marco"}, ResponseURL: server.URL, ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,vote", Value: vote}}}}
+		payload, _ := json.Marshal(callback)
+
+		w := httptest.NewRecorder()
+		mp.HandleInteractions(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(fmt.Sprintf("payload=%s", payload))))
+
+		assert.Equal(t, 200, w.Result().StatusCode)
+	}
+
+	votes, err := storer.GetSiloString("1566576557-poll1", "marco")
+	require.NoError(t, err)
+	assert.Equal(t, "0,2", votes)
+}
+
+// conflictingStorer simulates a concurrent vote by changing a user's votes right before the first compare-and-swap
+type conflictingStorer struct {
+	*marcopoller.MemoryStorer
+	concurrentVotes string
+	conflicted      bool
+}
+
+func (cs *conflictingStorer) CompareAndSwapSiloString(silo string, key string, old string, new string) (swapped bool, err error) {
+	if !cs.conflicted {
+		cs.conflicted = true
+		cs.MemoryStorer.PutSiloString(silo, key, cs.concurrentVotes)
+	}
+
+	return cs.MemoryStorer.CompareAndSwapSiloString(silo, key, old, new)
+}
+
+func TestMemoryStorerCompareAndSwap(t *testing.T) {
+	storer := marcopoller.NewMemoryStorer()
+
+	swapped, err := storer.CompareAndSwapSiloString("1566576557-poll1", "marco", "", "1")
+	require.NoError(t, err)
+	assert.True(t, swapped)
+
+	swapped, err = storer.CompareAndSwapSiloString("1566576557-poll1", "marco", "", "2")
+	require.NoError(t, err)
+	assert.False(t, swapped)
+
+	votes, err := storer.GetSiloString("1566576557-poll1", "marco")
+	require.NoError(t, err)
+	assert.Equal(t, "1", votes)
+}
+
+func TestVoteRetriedOnConflict(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	userFinder := &UserFinder{}
+	userFinder.On("GetUserInfo", "marco").Return(&slack.User{ID: "marco", Profile: slack.UserProfile{Image24: "http://image.me", RealName: "Marco Poller"}}, nil)
+
+	storer := &conflictingStorer{MemoryStorer: marcopoller.NewMemoryStorer(), concurrentVotes: "0"}
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\",\"Rome\",\"Oslo\"],\"features\":{\"multianswers\":true},\"creator\":\"UID\"}"))
+
+	verifier := &Verifier{}
+	verifier.On("Verify", mock.Anything, mock.Anything).Return(nil)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "marco"}, ResponseURL: server.URL, ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,vote", Value: "2"}}}}
+	payload, _ := json.Marshal(callback)
+
+	w := httptest.NewRecorder()
+	mp.HandleInteractions(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(fmt.Sprintf("payload=%s", payload))))
+
+	votes, err := storer.GetSiloString("1566576557-poll1", "marco")
+	require.NoError(t, err)
+
+	// The concurrent vote for Paris is kept along with the vote for Oslo
+	assert.Equal(t, "0,2", votes)
+}
+
+func TestConcurrentVotesConverge(t *testing.T) {
+	sqlStorer, _, cleanup := newSQLiteStorer(t)
+	defer cleanup()
+	defer sqlStorer.Close()
+
+	storers := map[string]store.GlobalSiloStringStorer{"memory": marcopoller.NewMemoryStorer(), "sql": sqlStorer}
+
+	for name, storer := range storers {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintln(w, "OK")
+			}))
+			defer server.Close()
+
+			userFinder := &UserFinder{}
+			userFinder.On("GetUserInfo", "marco").Return(&slack.User{ID: "marco", Profile: slack.UserProfile{Image24: "http://image.me", RealName: "Marco Poller"}}, nil)
+
+			verifier := &Verifier{}
+			verifier.On("Verify", mock.Anything, mock.Anything).Return(nil)
+
+			require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", "{\"id\":\"1566576557-poll1\",\"question\":\"Where to?\",\"options\":[\"Paris\",\"Rome\",\"Oslo\",\"Lima\",\"Kyiv\"],\"features\":{\"multianswers\":true},\"creator\":\"UID\"}"))
+
+			mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+			require.NoError(t, err)
+
+			// Every option is toggled once except Rome which is toggled twice (on and back off)
+			votes := []string{"0", "1", "2", "3", "4", "1"}
+
+			var wg sync.WaitGroup
+			for _, vote := range votes {
+				wg.Add(1)
+				go func(vote string) {
+					defer wg.Done()
+
+					callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "marco"}, ResponseURL: server.URL, ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "1566576557-poll1,vote", Value: vote}}}}
+					payload, _ := json.Marshal(callback)
+
+					w := httptest.NewRecorder()
+					mp.HandleInteractions(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(fmt.Sprintf("payload=%s", payload))))
+				}(vote)
+			}
+			wg.Wait()
+
+			userVotes, err := storer.GetSiloString("1566576557-poll1", "marco")
+			require.NoError(t, err)
+			assert.Equal(t, "0,2,3,4", userVotes)
+		})
+	}
+}
+
+// closeRecordingStorer records the name of the storer when it's closed and fails to close when it has a close error
+type closeRecordingStorer struct {
+	*marcopoller.MemoryStorer
+	name     string
+	closed   *[]string
+	closeErr error
+}
+
+func (cs closeRecordingStorer) Close() (err error) {
+	*cs.closed = append(*cs.closed, cs.name)
+	return cs.closeErr
+}
+
+func TestCloseClosesAllStorers(t *testing.T) {
+	closed := make([]string, 0)
+	newStorer := func(name string, closeErr error) closeRecordingStorer {
+		return closeRecordingStorer{MemoryStorer: marcopoller.NewMemoryStorer(), name: name, closed: &closed, closeErr: closeErr}
+	}
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}),
+		marcopoller.OptionStorer(newStorer("polls", nil)), marcopoller.OptionReminderStorer(newStorer("reminders", fmt.Errorf("reminders unavailable"))), marcopoller.OptionTemplateStorer(newStorer("templates", nil)),
+		marcopoller.OptionArchiveStorer(newStorer("archive", nil)), marcopoller.OptionTokenStore(marcopoller.NewStorerTokenStore(newStorer("installations", nil))))
+	require.NoError(t, err)
+
+	err = mp.Close()
+	assert.EqualError(t, err, "reminders unavailable")
+	assert.Equal(t, []string{"reminders", "templates", "archive", "installations", "polls"}, closed)
+}
@@ -0,0 +1,421 @@
+package marcopoller
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/alexandre-normand/slackscot/store"
+	"github.com/alexandre-normand/slackscot/store/datastoredb"
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+	otel "go.opentelemetry.io/otel/metric/global"
+	"google.golang.org/api/option"
+)
+
+// Team installation constants
+const (
+	installationsKindName = "marcoPollerInstallations"
+	tokensSilo            = "tokens"
+
+	// teamSiloDelimiter separates the team ID from the poll ID in the silo of a team's poll. It has to be a valid
+	// character in a datastore namespace
+	teamSiloDelimiter = "."
+
+	teamIDParam = "team_id"
+
+	installCodeParam  = "code"
+	installErrorParam = "error"
+	installStateParam = "state"
+	exportTeamParam   = "team"
+
+	// installStateCookie holds the state of an installation started by StartInstall until slack redirects to HandleInstall
+	installStateCookie = "marcopoller_install_state"
+
+	// installStateMaxAge is how long (in seconds) an installation can take between StartInstall and HandleInstall
+	installStateMaxAge = 600
+
+	slackAuthorizeURL = "https://slack.com/oauth/v2/authorize"
+)
+
+// TokenStore is implemented by any value that has the GetToken and PutToken methods. A TokenStore holds
+// the bot token of every slack team (workspace) Marco Poller is installed on
+type TokenStore interface {
+	GetToken(teamID string) (token string, err error)
+	PutToken(teamID string, token string) (err error)
+}
+
+// Installer is implemented by any value that has the AuthorizeURL and Install methods
+type Installer interface {
+	// AuthorizeURL returns the url of slack's authorization page that starts an OAuth installation with the given state
+	AuthorizeURL(state string) (authorizeURL string)
+
+	// Install completes an OAuth installation by exchanging the code for the team's bot token. See https://api.slack.com/authentication/oauth-v2
+	Install(code string) (teamID string, token string, err error)
+}
+
+// TeamClient is implemented by any value that is a UserFinder, a MemberFinder, a Dialoguer and a Messenger like a slack-go/slack.Client
+type TeamClient interface {
+	UserFinder
+	MemberFinder
+	Dialoguer
+	Messenger
+}
+
+// TeamClientFactory returns the client to use for a team given the team's bot token
+type TeamClientFactory func(token string) (client TeamClient)
+
+// StorerTokenStore represents a TokenStore backed by a store.SiloStringStorer. The storer should be dedicated to
+// tokens since polls are found by scanning all silos of the poll storer
+type StorerTokenStore struct {
+	storer store.SiloStringStorer
+}
+
+// NewStorerTokenStore returns a new TokenStore backed by the given storer
+func NewStorerTokenStore(storer store.SiloStringStorer) (tokenStore *StorerTokenStore) {
+	return &StorerTokenStore{storer: storer}
+}
+
+// GetToken returns the bot token of a team
+func (sts *StorerTokenStore) GetToken(teamID string) (token string, err error) {
+	return sts.storer.GetSiloString(tokensSilo, teamID)
+}
+
+// PutToken stores the bot token of a team
+func (sts *StorerTokenStore) PutToken(teamID string, token string) (err error) {
+	return sts.storer.PutSiloString(tokensSilo, teamID, token)
+}
+
+// Close closes the storer of the tokens
+func (sts *StorerTokenStore) Close() (err error) {
+	return sts.storer.Close()
+}
+
+// SlackInstaller represents an Installer backed by github.com/slack-go/slack
+type SlackInstaller struct {
+	clientID     string
+	clientSecret string
+	redirectURI  string
+	scopes       []string
+}
+
+// AuthorizeURL returns the url of slack's authorization page requesting the installer's bot scopes
+func (si *SlackInstaller) AuthorizeURL(state string) (authorizeURL string) {
+	params := url.Values{}
+	params.Set("client_id", si.clientID)
+	params.Set("scope", strings.Join(si.scopes, ","))
+	params.Set("redirect_uri", si.redirectURI)
+	params.Set(installStateParam, state)
+
+	return fmt.Sprintf("%s?%s", slackAuthorizeURL, params.Encode())
+}
+
+// Install exchanges an OAuth code for the team's bot token using slack's oauth.v2.access
+func (si *SlackInstaller) Install(code string) (teamID string, token string, err error) {
+	resp, err := slack.GetOAuthV2Response(http.DefaultClient, si.clientID, si.clientSecret, code, si.redirectURI)
+	if err != nil {
+		return "", "", err
+	}
+
+	return resp.Team.ID, resp.AccessToken, nil
+}
+
+// OptionTokenStore enables installations on many slack teams (workspaces). The slack clients (UserFinder, Dialoguer and Messenger)
+// are then created for each request with the bot token of the request's team and polls are stored separately for each team
+func OptionTokenStore(tokenStore TokenStore) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.tokenStore = tokenStore
+		return nil
+	}
+}
+
+// OptionDatastoreTokenStore sets a datastoredb-backed StorerTokenStore as the implementation of TokenStore
+func OptionDatastoreTokenStore(datastoreProjectID string, gcloudClientOpts ...option.ClientOption) Option {
+	return func(mp *MarcoPoller) (err error) {
+		meter := otel.GetMeterProvider().Meter("github.com/alexandre-normand/marcopoller")
+
+		storer, err := datastoredb.NewWithTelemetry(appName, meter, installationsKindName, datastoreProjectID, gcloudClientOpts...)
+		if err != nil {
+			return errors.Wrapf(err, "Error initializing datastore token store on project [%s]", datastoreProjectID)
+		}
+
+		mp.tokenStore = NewStorerTokenStore(storer)
+		return nil
+	}
+}
+
+// OptionTeamClientFactory sets how team clients are created from a team's bot token. Clients are slack-go/slack clients by default
+func OptionTeamClientFactory(factory TeamClientFactory) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.newTeamClient = factory
+		return nil
+	}
+}
+
+// OptionSlackInstaller sets a slack-go-backed SlackInstaller requesting the given bot scopes as the implementation of Installer
+func OptionSlackInstaller(clientID string, clientSecret string, redirectURI string, scopes ...string) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.installer = &SlackInstaller{clientID: clientID, clientSecret: clientSecret, redirectURI: redirectURI, scopes: scopes}
+		return nil
+	}
+}
+
+// OptionInstaller sets an installer as the implementation on MarcoPoller
+func OptionInstaller(installer Installer) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.installer = installer
+		return nil
+	}
+}
+
+// StartInstall starts slack's OAuth installation flow (https://api.slack.com/authentication/oauth-v2) by redirecting to
+// slack's authorization page. A random state is kept in a cookie and sent to slack so that HandleInstall only completes
+// installations started from the same browser
+func (mp *MarcoPoller) StartInstall(w http.ResponseWriter, r *http.Request) {
+	if mp.installer == nil || mp.tokenStore == nil {
+		http.Error(w, "Installation isn't enabled", 404)
+		return
+	}
+
+	state, err := generateInstallState()
+	if err != nil {
+		log.Printf("Error generating installation state: %v", err)
+		http.Error(w, "Error starting installation", 500)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: installStateCookie, Value: state, Path: "/", MaxAge: installStateMaxAge, Secure: true, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	http.Redirect(w, r, mp.installer.AuthorizeURL(state), http.StatusFound)
+}
+
+// generateInstallState returns a random installation state
+func generateInstallState() (state string, err error) {
+	b := make([]byte, 32)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// verifyInstallState returns an error unless the request's state is the state kept in the cookie set by StartInstall
+func verifyInstallState(r *http.Request) (err error) {
+	state := r.URL.Query().Get(installStateParam)
+	if state == "" {
+		return fmt.Errorf("Missing [%s] parameter", installStateParam)
+	}
+
+	cookie, err := r.Cookie(installStateCookie)
+	if err != nil {
+		return fmt.Errorf("Missing installation state cookie, installations must be started from the install link")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		return fmt.Errorf("Invalid installation state")
+	}
+
+	return nil
+}
+
+// HandleInstall handles the redirect at the end of slack's OAuth installation flow (https://api.slack.com/authentication/oauth-v2).
+// The installation must have been started by StartInstall in the same browser. The code is then exchanged for the team's bot
+// token which is saved in the TokenStore
+func (mp *MarcoPoller) HandleInstall(w http.ResponseWriter, r *http.Request) {
+	if mp.installer == nil || mp.tokenStore == nil {
+		http.Error(w, "Installation isn't enabled", 404)
+		return
+	}
+
+	err := verifyInstallState(r)
+	if err != nil {
+		log.Printf("Rejecting installation: %v", err)
+		http.Error(w, err.Error(), 403)
+		return
+	}
+
+	// The state is only good for one installation
+	http.SetCookie(w, &http.Cookie{Name: installStateCookie, Path: "/", MaxAge: -1, Secure: true, HttpOnly: true, SameSite: http.SameSiteLaxMode})
+
+	if installErr := r.URL.Query().Get(installErrorParam); installErr != "" {
+		log.Printf("Installation denied: %s", installErr)
+		http.Error(w, fmt.Sprintf("Installation denied: %s", installErr), 403)
+		return
+	}
+
+	code := r.URL.Query().Get(installCodeParam)
+	if code == "" {
+		http.Error(w, fmt.Sprintf("Missing [%s] parameter", installCodeParam), 400)
+		return
+	}
+
+	teamID, token, err := mp.installer.Install(code)
+	if err != nil {
+		log.Printf("Error completing installation: %v", err)
+		http.Error(w, err.Error(), 403)
+		return
+	}
+
+	err = mp.tokenStore.PutToken(teamID, token)
+	if err != nil {
+		log.Printf("Error storing token for team [%s]: %v", teamID, err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+
+	fmt.Fprintf(w, "%s is installed. Start a poll with /poll.", friendlyName)
+}
+
+// forTeam returns the MarcoPoller to use for requests from a team. When many teams are supported (with a TokenStore), it
+// uses a client created with the team's token and the team's poll storage. Otherwise, the MarcoPoller itself is returned
+func (mp *MarcoPoller) forTeam(teamID string) (teamPoller *MarcoPoller, err error) {
+	if mp.tokenStore == nil {
+		return mp, nil
+	}
+
+	if teamID == "" {
+		return nil, fmt.Errorf("Missing team ID")
+	}
+
+	token, err := mp.tokenStore.GetToken(teamID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error getting token for team [%s]", teamID)
+	}
+
+	client := mp.newTeamClient(token)
+
+	teamPoller = mp.forTeamStorage(teamID)
+	teamPoller.userFinder = client
+	teamPoller.memberFinder = client
+	teamPoller.dialoguer = client
+	teamPoller.messenger = client
+
+	return teamPoller, nil
+}
+
+// forTeamStorage returns a copy of the MarcoPoller using the team's poll storage. Polls without a team (stored
+// before many teams were supported) use the MarcoPoller's storage
+func (mp *MarcoPoller) forTeamStorage(teamID string) (teamPoller *MarcoPoller) {
+	tp := *mp
+	if teamID != "" {
+		tp.storer = &teamStorer{storer: mp.storer, teamID: teamID}
+
+		if mp.reminderStorer != nil {
+			tp.reminderStorer = &teamStorer{storer: mp.reminderStorer, teamID: teamID}
+		}
+
+		if mp.templateStorer != nil {
+			tp.templateStorer = &teamStorer{storer: mp.templateStorer, teamID: teamID}
+		}
+
+		if mp.archiveStorer != nil {
+			tp.archiveStorer = &teamStorer{storer: mp.archiveStorer, teamID: teamID}
+		}
+	}
+
+	return &tp
+}
+
+// pollsByTeam groups the entries of a global scan of the poll storage by team. When many teams aren't
+// supported, all polls are grouped under an empty team ID
+func (mp *MarcoPoller) pollsByTeam(entries map[string]map[string]string) (polls map[string]map[string]map[string]string) {
+	polls = make(map[string]map[string]map[string]string)
+	for silo, values := range entries {
+		teamID, pollID := "", silo
+		if mp.tokenStore != nil {
+			teamID, pollID = splitTeamSilo(silo)
+		}
+
+		if _, ok := polls[teamID]; !ok {
+			polls[teamID] = make(map[string]map[string]string)
+		}
+
+		polls[teamID][pollID] = values
+	}
+
+	return polls
+}
+
+// newSlackTeamClient returns a slack-go/slack client for a team
+func (mp *MarcoPoller) newSlackTeamClient(token string) (client TeamClient) {
+	return slack.New(token, slack.OptionDebug(mp.debug))
+}
+
+// splitTeamSilo splits a team poll's silo into its team ID and poll ID. Polls stored before many teams were
+// supported don't have a team ID
+func splitTeamSilo(silo string) (teamID string, pollID string) {
+	parts := strings.SplitN(silo, teamSiloDelimiter, 2)
+	if len(parts) < 2 {
+		return "", silo
+	}
+
+	return parts[0], parts[1]
+}
+
+// teamStorer scopes a storer to a team's silos so that polls of different teams never collide
+type teamStorer struct {
+	storer store.GlobalSiloStringStorer
+	teamID string
+}
+
+// silo returns the team's silo name for a poll
+func (ts *teamStorer) silo(pollID string) (silo string) {
+	return ts.teamID + teamSiloDelimiter + pollID
+}
+
+// GetSiloString returns the value of a key in a team's poll silo
+func (ts *teamStorer) GetSiloString(silo string, key string) (value string, err error) {
+	return ts.storer.GetSiloString(ts.silo(silo), key)
+}
+
+// PutSiloString stores a value for a key in a team's poll silo
+func (ts *teamStorer) PutSiloString(silo string, key string, value string) (err error) {
+	return ts.storer.PutSiloString(ts.silo(silo), key, value)
+}
+
+// CompareAndSwapSiloString stores a value for a key in a team's poll silo if the current value is old. Storers that
+// can't compare-and-swap always store the value
+func (ts *teamStorer) CompareAndSwapSiloString(silo string, key string, old string, new string) (swapped bool, err error) {
+	if cas, ok := ts.storer.(CompareAndSwapper); ok {
+		return cas.CompareAndSwapSiloString(ts.silo(silo), key, old, new)
+	}
+
+	return true, ts.storer.PutSiloString(ts.silo(silo), key, new)
+}
+
+// DeleteSiloString deletes a key from a team's poll silo
+func (ts *teamStorer) DeleteSiloString(silo string, key string) (err error) {
+	return ts.storer.DeleteSiloString(ts.silo(silo), key)
+}
+
+// ScanSilo returns all entries of a team's poll silo
+func (ts *teamStorer) ScanSilo(silo string) (entries map[string]string, err error) {
+	return ts.storer.ScanSilo(ts.silo(silo))
+}
+
+// GlobalScan returns the entries of all of a team's poll silos
+func (ts *teamStorer) GlobalScan() (entries map[string]map[string]string, err error) {
+	all, err := ts.storer.GlobalScan()
+	if err != nil {
+		return nil, err
+	}
+
+	entries = make(map[string]map[string]string)
+	for silo, values := range all {
+		if teamID, pollID := splitTeamSilo(silo); teamID == ts.teamID {
+			entries[pollID] = values
+		}
+	}
+
+	return entries, nil
+}
+
+// Close closes the underlying storer
+func (ts *teamStorer) Close() (err error) {
+	return ts.storer.Close()
+}
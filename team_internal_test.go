@@ -0,0 +1,45 @@
+package marcopoller
+
+import (
+	"testing"
+
+	"github.com/alexandre-normand/slackscot/store/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitTeamSilo(t *testing.T) {
+	tests := map[string]struct {
+		silo           string
+		expectedTeamID string
+		expectedPollID string
+	}{
+		"team poll":   {silo: "TEAMID3.1566576557-poll", expectedTeamID: "TEAMID3", expectedPollID: "1566576557-poll"},
+		"legacy poll": {silo: "1566576557-poll", expectedTeamID: "", expectedPollID: "1566576557-poll"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			teamID, pollID := splitTeamSilo(tc.silo)
+			assert.Equal(t, tc.expectedTeamID, teamID)
+			assert.Equal(t, tc.expectedPollID, pollID)
+		})
+	}
+}
+
+func TestTeamStorerScopesSilos(t *testing.T) {
+	storer := &mocks.Storer{}
+	storer.On("PutSiloString", "TEAMID3.somePoll", "UID", "1").Return(nil)
+	storer.On("GlobalScan").Return(map[string]map[string]string{"TEAMID3.somePoll": {"UID": "1"}, "TEAMID4.otherPoll": {"UID": "0"}, "legacyPoll": {"UID": "0"}}, nil)
+	defer storer.AssertExpectations(t)
+
+	ts := &teamStorer{storer: storer, teamID: "TEAMID3"}
+
+	err := ts.PutSiloString("somePoll", "UID", "1")
+	require.NoError(t, err)
+
+	entries, err := ts.GlobalScan()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]map[string]string{"somePoll": {"UID": "1"}}, entries)
+}
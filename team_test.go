@@ -0,0 +1,219 @@
+package marcopoller_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexandre-normand/marcopoller"
+	mmocks "github.com/alexandre-normand/marcopoller/mocks"
+	"github.com/alexandre-normand/slackscot/store/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// teamClient combines the mocks of a team's clients
+type teamClient struct {
+	*UserFinder
+	*mmocks.MemberFinder
+	*mmocks.Dialoguer
+	*mmocks.Messenger
+}
+
+func TestHandleInstall(t *testing.T) {
+	installer := &mmocks.Installer{}
+	installer.On("Install", "someCode").Return("TEAMID3", "xoxb-someToken", nil)
+	defer installer.AssertExpectations(t)
+
+	tokenStore := &mmocks.TokenStore{}
+	tokenStore.On("PutToken", "TEAMID3", "xoxb-someToken").Return(nil)
+	defer tokenStore.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionStorer(&mocks.Storer{}), marcopoller.OptionTokenStore(tokenStore), marcopoller.OptionInstaller(installer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/install?code=someCode&state=someState", nil)
+	r.AddCookie(&http.Cookie{Name: "marcopoller_install_state", Value: "someState"})
+
+	w := httptest.NewRecorder()
+	mp.HandleInstall(w, r)
+
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, "Marco Poller is installed. Start a poll with /poll.", string(body))
+
+	// The state can't be used again
+	require.Len(t, resp.Cookies(), 1)
+	assert.Equal(t, "marcopoller_install_state", resp.Cookies()[0].Name)
+	assert.Equal(t, -1, resp.Cookies()[0].MaxAge)
+}
+
+func TestStartInstall(t *testing.T) {
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionStorer(&mocks.Storer{}), marcopoller.OptionTokenStore(&mmocks.TokenStore{}), marcopoller.OptionSlackInstaller("someClientID", "someSecret", "https://marcopoller.me/install", "commands", "chat:write"), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	mp.StartInstall(w, httptest.NewRequest(http.MethodGet, "/startInstall", nil))
+
+	resp := w.Result()
+	assert.Equal(t, 302, resp.StatusCode)
+
+	require.Len(t, resp.Cookies(), 1)
+	cookie := resp.Cookies()[0]
+	assert.Equal(t, "marcopoller_install_state", cookie.Name)
+	assert.True(t, cookie.HttpOnly)
+	assert.True(t, cookie.Secure)
+	assert.Len(t, cookie.Value, 43)
+
+	assert.Equal(t, fmt.Sprintf("https://slack.com/oauth/v2/authorize?client_id=someClientID&redirect_uri=https%%3A%%2F%%2Fmarcopoller.me%%2Finstall&scope=commands%%2Cchat%%3Awrite&state=%s", cookie.Value), resp.Header.Get("Location"))
+
+	// Each installation gets its own state
+	other := httptest.NewRecorder()
+	mp.StartInstall(other, httptest.NewRequest(http.MethodGet, "/startInstall", nil))
+	require.Len(t, other.Result().Cookies(), 1)
+	assert.NotEqual(t, cookie.Value, other.Result().Cookies()[0].Value)
+}
+
+func TestHandleInstallErrors(t *testing.T) {
+	tests := map[string]struct {
+		url            string
+		stateCookie    string
+		installErr     error
+		expectedStatus int
+	}{
+		"denied":         {url: "/install?error=access_denied&state=someState", stateCookie: "someState", expectedStatus: 403},
+		"missing code":   {url: "/install?state=someState", stateCookie: "someState", expectedStatus: 400},
+		"invalid code":   {url: "/install?code=someCode&state=someState", stateCookie: "someState", installErr: fmt.Errorf("invalid_code"), expectedStatus: 403},
+		"not configured": {url: "/install?code=someCode&state=someState", stateCookie: "someState", expectedStatus: 404},
+		"missing state":  {url: "/install?code=someCode", stateCookie: "someState", expectedStatus: 403},
+		"missing cookie": {url: "/install?code=someCode&state=someState", expectedStatus: 403},
+		"wrong state":    {url: "/install?code=someCode&state=attackerState", stateCookie: "someState", expectedStatus: 403},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			installer := &mmocks.Installer{}
+			if tc.installErr != nil {
+				installer.On("Install", "someCode").Return("", "", tc.installErr)
+			}
+			defer installer.AssertExpectations(t)
+
+			options := []marcopoller.Option{marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionStorer(&mocks.Storer{}), marcopoller.OptionTokenStore(&mmocks.TokenStore{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{})}
+			if name != "not configured" {
+				options = append(options, marcopoller.OptionInstaller(installer))
+			}
+
+			mp, err := marcopoller.NewWithOptions(options...)
+			require.NoError(t, err)
+
+			r := httptest.NewRequest(http.MethodGet, tc.url, nil)
+			if tc.stateCookie != "" {
+				r.AddCookie(&http.Cookie{Name: "marcopoller_install_state", Value: tc.stateCookie})
+			}
+
+			w := httptest.NewRecorder()
+			mp.HandleInstall(w, r)
+
+			assert.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+		})
+	}
+}
+
+func TestNewPollStoredInTeamSilo(t *testing.T) {
+	body := "token=sometoken&team_id=TEAMID3&team_domain=test-workspace&channel_id=CID&channel_name=testchannel&user_id=UID&user_name=marco&command=%2Fpoll&text=%22To%20do%20or%20not%20to%20do%3F%22%20%22Do%22%20%22Not%20Do%22&response_url=https%3A%2F%2Fhooks.slack.com%2Fcommands%2Fbla%2Fbleh%2Fblo&trigger_id=someTriggerID"
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	storer := &mocks.Storer{}
+	storer.On("PutSiloString", mock.MatchedBy(func(silo string) bool {
+		return strings.HasPrefix(silo, "TEAMID3.")
+	}), "pollInfo", mock.Anything).Return(nil).Twice()
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	tokenStore := &mmocks.TokenStore{}
+	tokenStore.On("GetToken", "TEAMID3").Return("xoxb-team3", nil)
+	defer tokenStore.AssertExpectations(t)
+
+	messenger := &mmocks.Messenger{}
+	messenger.On("PostMessage", "CID", mock.Anything, mock.Anything).Return("CID", "1566576557.354007", nil)
+	defer messenger.AssertExpectations(t)
+
+	clientToken := ""
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionStorer(storer), marcopoller.OptionTokenStore(tokenStore), marcopoller.OptionTeamClientFactory(func(token string) marcopoller.TeamClient {
+		clientToken = token
+		return teamClient{&UserFinder{}, &mmocks.MemberFinder{}, &mmocks.Dialoguer{}, messenger}
+	}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.StartPoll(w, r)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+	assert.Equal(t, "xoxb-team3", clientToken)
+}
+
+func TestNewPollFromTeamNotInstalled(t *testing.T) {
+	slackRequest := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqBody, _ := ioutil.ReadAll(r.Body)
+		slackRequest = string(reqBody)
+		fmt.Fprintln(w, "OK")
+	}))
+	defer server.Close()
+
+	body := fmt.Sprintf("token=sometoken&team_id=TEAMID4&team_domain=test-workspace&channel_id=CID&channel_name=testchannel&user_id=UID&user_name=marco&command=%%2Fpoll&text=%%22To%%20do%%20or%%20not%%20to%%20do%%3F%%22%%20%%22Do%%22%%20%%22Not%%20Do%%22&response_url=%s&trigger_id=someTriggerID", server.URL)
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+
+	storer := &mocks.Storer{}
+	defer storer.AssertExpectations(t)
+
+	verifier := &Verifier{}
+	verifier.On("Verify", r.Header, []byte(body)).Return(nil)
+	defer verifier.AssertExpectations(t)
+
+	tokenStore := &mmocks.TokenStore{}
+	tokenStore.On("GetToken", "TEAMID4").Return("", fmt.Errorf("no such entity"))
+	defer tokenStore.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionStorer(storer), marcopoller.OptionTokenStore(tokenStore), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+
+	mp.StartPoll(w, r)
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+	assert.Equal(t, "{\"response_type\":\"ephemeral\",\"text\":\":warning: Marco Poller isn't installed on this workspace. Please ask an admin to install it.\",\"replace_original\":false}", slackRequest)
+}
+
+func TestDeleteExpiredPollsOfManyTeams(t *testing.T) {
+	storer := &mocks.Storer{}
+	storer.On("GlobalScan").Return(map[string]map[string]string{"TEAMID3.1566576557-expiredPoll1": {"pollInfo": "{\"id\":\"1566576557-expiredPoll1\",\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\"}"},
+		"1566574991-expiredPoll2":      {"pollInfo": "{\"id\":\"1566574991-expiredPoll2\",\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\"}"},
+		"TEAMID4.1566580148-freshPoll": {"pollInfo": "{\"id\":\"1566580148-freshPoll\",\"question\":\"To do or not to do?\",\"options\":[\"Do\",\"Not Do\"],\"features\":{\"multianswers\":false},\"creator\":\"UID\"}"}}, nil)
+	storer.On("ScanSilo", "TEAMID3.1566576557-expiredPoll1").Return(map[string]string{"pollInfo": "{}", "UID": "0"}, nil)
+	storer.On("DeleteSiloString", "TEAMID3.1566576557-expiredPoll1", "pollInfo").Return(nil)
+	storer.On("DeleteSiloString", "TEAMID3.1566576557-expiredPoll1", "UID").Return(nil)
+	storer.On("ScanSilo", "1566574991-expiredPoll2").Return(map[string]string{"pollInfo": "{}"}, nil)
+	storer.On("DeleteSiloString", "1566574991-expiredPoll2", "pollInfo").Return(nil)
+	defer storer.AssertExpectations(t)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionStorer(storer), marcopoller.OptionTokenStore(&mmocks.TokenStore{}), marcopoller.OptionPollVerifier(marcopoller.ExpirationPollVerifier{ValidityPeriod: time.Duration(1) * time.Hour}))
+	require.NoError(t, err)
+
+	deleted, err := mp.DeleteExpiredPolls(time.Unix(1566580158, 0))
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, deleted)
+}
@@ -0,0 +1,319 @@
+package marcopoller
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alexandre-normand/slackscot/store"
+	"github.com/alexandre-normand/slackscot/store/datastoredb"
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+	otel "go.opentelemetry.io/otel/metric/global"
+	"google.golang.org/api/option"
+)
+
+// Template prompt identifiers and persistence
+const (
+	pollTemplatePickerBlockID    = "poll_template_picker"
+	pollTemplatePickerActionID   = "poll_template_picker"
+	pollTemplateNameInputBlockID = "poll_template_name"
+	pollTemplateNameActionID     = "poll_template_name"
+
+	// templateBlockIDDelimiter separates the block ID of a prefilled input from the key of its template
+	templateBlockIDDelimiter = "."
+
+	// maxTemplateNameLength keeps template names short enough to be select options and part of block IDs
+	maxTemplateNameLength = 50
+
+	// maxTemplatePickerOptions is the maximum number of options of a slack static select
+	maxTemplatePickerOptions = 100
+
+	templatesKindName = "marcoPollerTemplates"
+	templatesSilo     = "templates"
+)
+
+// PollTemplate represents a named poll saved to create the same poll again. The deadline is saved as a duration (in
+// seconds) since an absolute deadline would already be passed when the template is used again
+type PollTemplate struct {
+	Name     string       `json:"name"`
+	Question string       `json:"question"`
+	Options  []PollOption `json:"options"`
+	Features PollFeatures `json:"features"`
+	Duration int64        `json:"duration,omitempty"`
+	Creator  string       `json:"creator"`
+}
+
+// OptionTemplateStorer sets the storer of poll templates. It should be dedicated to templates since polls are found by
+// scanning all silos of the poll storer
+func OptionTemplateStorer(storer store.GlobalSiloStringStorer) Option {
+	return func(mp *MarcoPoller) (err error) {
+		mp.templateStorer = storer
+		return nil
+	}
+}
+
+// OptionDatastoreTemplateStorer sets a datastoredb storer as the storer of poll templates
+func OptionDatastoreTemplateStorer(datastoreProjectID string, gcloudClientOpts ...option.ClientOption) Option {
+	return func(mp *MarcoPoller) (err error) {
+		meter := otel.GetMeterProvider().Meter("github.com/alexandre-normand/marcopoller")
+
+		mp.templateStorer, err = datastoredb.NewWithTelemetry(appName, meter, templatesKindName, datastoreProjectID, gcloudClientOpts...)
+		if err != nil {
+			return errors.Wrapf(err, "Error initializing datastore template storer on project [%s]", datastoreProjectID)
+		}
+
+		return nil
+	}
+}
+
+// newPollTemplate returns the template of a poll created from the prompt. Only deadlines entered as a duration are kept
+func newPollTemplate(name string, question string, options []PollOption, features PollFeatures, rawDeadline string, creator string) (template PollTemplate) {
+	features.Deadline = 0
+	template = PollTemplate{Name: name, Question: question, Options: options, Features: features, Creator: creator}
+
+	if d, err := time.ParseDuration(rawDeadline); err == nil && d > 0 {
+		template.Duration = int64(d / time.Second)
+	}
+
+	return template
+}
+
+// normalizeTemplateName returns the key of a template so that names differing only by case or spacing match the same template
+func normalizeTemplateName(name string) (key string) {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// templateBlockID returns the block ID of a prompt input for a template. Inputs of a prompt without a template keep their block ID
+func templateBlockID(blockID string, templateKey string) (id string) {
+	if templateKey == "" {
+		return blockID
+	}
+
+	return blockID + templateBlockIDDelimiter + templateKey
+}
+
+// listTemplates returns all saved templates sorted by name. No templates are returned when templates aren't enabled
+func (mp *MarcoPoller) listTemplates() (templates []PollTemplate, err error) {
+	if mp.templateStorer == nil {
+		return nil, nil
+	}
+
+	entries, err := mp.templateStorer.ScanSilo(templatesSilo)
+	if err != nil {
+		return nil, err
+	}
+
+	templates = make([]PollTemplate, 0)
+	for key, encoded := range entries {
+		var template PollTemplate
+		err := json.Unmarshal([]byte(encoded), &template)
+		if err != nil {
+			log.Printf("Error parsing template [%s] for key [%s]: %v", encoded, key, err)
+			continue
+		}
+
+		templates = append(templates, template)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return normalizeTemplateName(templates[i].Name) < normalizeTemplateName(templates[j].Name)
+	})
+
+	return templates, nil
+}
+
+// getTemplate returns the template saved with a name
+func (mp *MarcoPoller) getTemplate(name string) (template PollTemplate, err error) {
+	encoded, err := mp.templateStorer.GetSiloString(templatesSilo, normalizeTemplateName(name))
+	if err != nil {
+		return template, err
+	}
+
+	err = json.Unmarshal([]byte(encoded), &template)
+
+	return template, err
+}
+
+// saveTemplate saves a template and tells the user about it. A template with the same name is replaced only if it was
+// saved by the same user since templates are shared by everyone on the workspace
+func (mp *MarcoPoller) saveTemplate(template PollTemplate, responseURL string) {
+	existing, err := mp.getTemplate(template.Name)
+	if err != nil && !isNotFound(err) {
+		log.Printf("Error getting template [%s]: %v", template.Name, err)
+		showErrorToUser(responseURL, fmt.Sprintf(":warning: Error saving template [%s]. Please try again.", template.Name))
+		return
+	}
+
+	if err == nil && existing.Creator != template.Creator {
+		showErrorToUser(responseURL, fmt.Sprintf(":warning: A template named [%s] was already saved by <@%s>. Pick another name to save this poll as a template", existing.Name, existing.Creator))
+		return
+	}
+
+	encoded, err := json.Marshal(template)
+	if err != nil {
+		log.Printf("Error encoding template [%s]: %v", template.Name, err)
+		showErrorToUser(responseURL, ":warning: Error encoding template. Please report this at https://github.com/alexandre-normand/marcopoller")
+		return
+	}
+
+	err = mp.templateStorer.PutSiloString(templatesSilo, normalizeTemplateName(template.Name), string(encoded))
+	if err != nil {
+		log.Printf("Error persisting template [%s]: %v", template.Name, err)
+		showErrorToUser(responseURL, fmt.Sprintf(":warning: Error saving template [%s]. Please try again.", template.Name))
+		return
+	}
+
+	showMessageToUser(responseURL, fmt.Sprintf("Saved template *%s*. Create a poll from it with `/poll template %s`", template.Name, template.Name))
+}
+
+// createPollFromTemplate creates a poll from a saved template on behalf of a user. The deadline of the poll is set
+// relative to now when the template has one
+func (mp *MarcoPoller) createPollFromTemplate(name string, userID string, channelID string, responseURL string, w http.ResponseWriter) {
+	template, err := mp.getTemplate(name)
+	if isNotFound(err) {
+		showErrorToUser(responseURL, fmt.Sprintf(":warning: Template [%s] not found. Use `/poll template` to list the saved templates", name))
+		return
+	} else if err != nil {
+		log.Printf("Error getting template [%s]: %v", name, err)
+		showErrorToUser(responseURL, ":warning: Error getting template. Please try again.")
+		return
+	}
+
+	features := template.Features
+	if template.Duration > 0 {
+		features.Deadline = time.Now().Add(time.Duration(template.Duration) * time.Second).Unix()
+	}
+
+	mp.createNewPoll(template.Question, template.Options, userID, features, channelID, responseURL, w)
+}
+
+// showTemplates shows a user the saved templates
+func (mp *MarcoPoller) showTemplates(responseURL string) {
+	templates, err := mp.listTemplates()
+	if err != nil {
+		log.Printf("Error listing templates: %v", err)
+		showErrorToUser(responseURL, ":warning: Error listing templates. Please try again.")
+		return
+	}
+
+	showMessageToUser(responseURL, formatTemplateList(templates))
+}
+
+// handleTemplateSelection handles the selection of a template in the new poll prompt by updating the prompt with the
+// fields of the template
+func (mp *MarcoPoller) handleTemplateSelection(callback InteractionCallback) {
+	if len(callback.ActionCallback.BlockActions) < 1 {
+		log.Printf("Invalid template selection without block actions: %v", callback)
+		return
+	}
+
+	templates, err := mp.listTemplates()
+	if err != nil {
+		log.Printf("Error listing templates: %v", err)
+		return
+	}
+
+	selectedKey := callback.ActionCallback.BlockActions[0].SelectedOption.Value
+	for i, template := range templates {
+		if normalizeTemplateName(template.Name) == selectedKey {
+			_, err = mp.dialoguer.UpdateView(createInteractivePollPrompt(templates, &templates[i], true), "", callback.View.Hash, callback.View.ID)
+			if err != nil {
+				log.Printf("Error updating interactive prompt [%s] with template [%s]: %v", callback.View.ID, template.Name, err)
+			}
+
+			return
+		}
+	}
+
+	log.Printf("Template [%s] selected in interactive prompt [%s] not found", selectedKey, callback.View.ID)
+}
+
+// createTemplatePicker renders the select of the saved templates at the top of the new poll prompt
+func createTemplatePicker(templates []PollTemplate, selected *PollTemplate) (block *slack.ActionBlock) {
+	options := make([]*slack.OptionBlockObject, 0)
+	for i, template := range templates {
+		if i == maxTemplatePickerOptions {
+			break
+		}
+
+		options = append(options, slack.NewOptionBlockObject(normalizeTemplateName(template.Name), slack.NewTextBlockObject("plain_text", template.Name, false, false), nil))
+	}
+
+	picker := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, slack.NewTextBlockObject("plain_text", "Start from a template", false, false), pollTemplatePickerActionID, options...)
+	if selected != nil {
+		for _, o := range options {
+			if o.Value == normalizeTemplateName(selected.Name) {
+				picker.InitialOption = o
+			}
+		}
+	}
+
+	return slack.NewActionBlock(pollTemplatePickerBlockID, picker)
+}
+
+// createTemplateNameInput renders the optional input to save a new poll as a template
+func createTemplateNameInput() (block *slack.InputBlock) {
+	nameInput := slack.NewPlainTextInputBlockElement(slack.NewTextBlockObject("plain_text", "Sprint retro", false, false), pollTemplateNameActionID)
+	nameInput.MaxLength = maxTemplateNameLength
+
+	block = slack.NewInputBlock(pollTemplateNameInputBlockID, slack.NewTextBlockObject("plain_text", "Save as a template", false, false), nameInput)
+	block.Hint = slack.NewTextBlockObject("plain_text", "Enter a name to reuse this poll later with /poll template <name>. A template you saved with the same name is replaced", false, false)
+	block.Optional = true
+
+	return block
+}
+
+// selectedFeatureOptions returns the feature checkboxes of the new poll prompt matching the enabled poll features
+func selectedFeatureOptions(features PollFeatures, options []*slack.OptionBlockObject) (selected []*slack.OptionBlockObject) {
+	enabled := map[string]bool{
+		multiAnswerOptionID:    features.MultiAnswers,
+		anonymousOptionID:      features.Anonymous,
+		rankedChoiceOptionID:   features.RankedChoice,
+		keepResultsOptionID:    features.KeepResults,
+		openOptionsOptionID:    features.OpenOptions,
+		hiddenResultsOptionID:  features.HiddenResults,
+		channelMembersOptionID: features.Eligibility != nil && features.Eligibility.ChannelMembers,
+		barChartOptionID:       features.RenderStyle == BarsRenderStyle,
+	}
+
+	for _, o := range options {
+		if enabled[o.Value] {
+			selected = append(selected, o)
+		}
+	}
+
+	return selected
+}
+
+// formatTemplateDuration formats a template's duration (in seconds) as a deadline duration (i.e. 2h, 1h30m)
+func formatTemplateDuration(seconds int64) (formatted string) {
+	formatted = (time.Duration(seconds) * time.Second).String()
+	if strings.HasSuffix(formatted, "m0s") {
+		formatted = strings.TrimSuffix(formatted, "0s")
+	}
+
+	if strings.HasSuffix(formatted, "h0m") {
+		formatted = strings.TrimSuffix(formatted, "0m")
+	}
+
+	return formatted
+}
+
+// formatTemplateList formats the list of saved templates
+func formatTemplateList(templates []PollTemplate) (formatted string) {
+	if len(templates) == 0 {
+		return "There are no saved templates. Save one with the *Save as a template* field of the `/poll` prompt"
+	}
+
+	lines := []string{"*Templates*"}
+	for _, template := range templates {
+		lines = append(lines, fmt.Sprintf("• `%s` %s (%d options)", template.Name, template.Question, len(template.Options)))
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,83 @@
+package marcopoller
+
+import (
+	"testing"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPollTemplate(t *testing.T) {
+	testCases := []struct {
+		name             string
+		rawDeadline      string
+		expectedDuration int64
+	}{
+		{"Without deadline", "", 0},
+		{"With duration", "1h30m", 5400},
+		{"With absolute deadline", "2020-10-20T15:00:00-07:00", 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			template := newPollTemplate("Retro", "How was the sprint?", parseOptions([]string{"Great", "Rough"}), PollFeatures{Anonymous: true, Deadline: 1603231200}, tc.rawDeadline, "marco")
+
+			assert.Equal(t, PollTemplate{Name: "Retro", Question: "How was the sprint?", Options: []PollOption{{Text: "Great"}, {Text: "Rough"}}, Features: PollFeatures{Anonymous: true}, Duration: tc.expectedDuration, Creator: "marco"}, template)
+		})
+	}
+}
+
+func TestNormalizeTemplateName(t *testing.T) {
+	assert.Equal(t, "sprint retro", normalizeTemplateName("  Sprint   RETRO "))
+	assert.Equal(t, "poll_question.sprint retro", templateBlockID(pollQuestionInputBlockID, "sprint retro"))
+	assert.Equal(t, "poll_question", templateBlockID(pollQuestionInputBlockID, ""))
+}
+
+func TestFormatTemplateDuration(t *testing.T) {
+	assert.Equal(t, "2h", formatTemplateDuration(7200))
+	assert.Equal(t, "1h30m", formatTemplateDuration(5400))
+	assert.Equal(t, "45m", formatTemplateDuration(2700))
+	assert.Equal(t, "1m30s", formatTemplateDuration(90))
+}
+
+func TestFormatTemplateList(t *testing.T) {
+	assert.Equal(t, "There are no saved templates. Save one with the *Save as a template* field of the `/poll` prompt", formatTemplateList([]PollTemplate{}))
+	assert.Equal(t, "*Templates*\n• `Retro` How was the sprint? (2 options)", formatTemplateList([]PollTemplate{{Name: "Retro", Question: "How was the sprint?", Options: []PollOption{{Text: "Great"}, {Text: "Rough"}}}}))
+}
+
+func TestInteractivePollPromptWithSelectedTemplate(t *testing.T) {
+	templates := []PollTemplate{
+		{Name: "On-call handoff", Question: "Ready?", Options: []PollOption{{Text: "Yes"}, {Text: "No"}}},
+		{Name: "Sprint Retro", Question: "How was the sprint?", Options: []PollOption{{Text: "Great"}, {Text: "Rough"}}, Features: PollFeatures{MultiAnswers: true, MaxVotesPerUser: 2, RenderStyle: BarsRenderStyle}, Duration: 5400},
+	}
+
+	viewRequest := createInteractivePollPrompt(templates, &templates[1], true)
+	blocks := viewRequest.Blocks.BlockSet
+
+	assert.Equal(t, "sprint retro", viewRequest.PrivateMetadata)
+	require.Len(t, blocks, 8)
+
+	picker := blocks[0].(*slack.ActionBlock).Elements.ElementSet[0].(*slack.SelectBlockElement)
+	require.Len(t, picker.Options, 2)
+	assert.Equal(t, "sprint retro", picker.InitialOption.Value)
+
+	assert.Equal(t, pollConversationInputBlockID, blocks[1].(*slack.InputBlock).BlockID)
+
+	question := blocks[2].(*slack.InputBlock)
+	assert.Equal(t, "poll_question.sprint retro", question.BlockID)
+	assert.Equal(t, "How was the sprint?", question.Element.(*slack.PlainTextInputBlockElement).InitialValue)
+
+	options := blocks[3].(*slack.InputBlock)
+	assert.Equal(t, "poll_answer_options.sprint retro", options.BlockID)
+	assert.Equal(t, "Great\nRough", options.Element.(*slack.PlainTextInputBlockElement).InitialValue)
+
+	features := blocks[4].(*slack.InputBlock).Element.(*slack.CheckboxGroupsBlockElement)
+	require.Len(t, features.InitialOptions, 2)
+	assert.Equal(t, multiAnswerOptionID, features.InitialOptions[0].Value)
+	assert.Equal(t, barChartOptionID, features.InitialOptions[1].Value)
+
+	assert.Equal(t, "1h30m", blocks[5].(*slack.InputBlock).Element.(*slack.PlainTextInputBlockElement).InitialValue)
+	assert.Equal(t, "2", blocks[6].(*slack.InputBlock).Element.(*slack.PlainTextInputBlockElement).InitialValue)
+	assert.Equal(t, pollTemplateNameInputBlockID, blocks[7].(*slack.InputBlock).BlockID)
+}
@@ -0,0 +1,224 @@
+package marcopoller_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexandre-normand/marcopoller"
+	mmocks "github.com/alexandre-normand/marcopoller/mocks"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const retroTemplate = "{\"name\":\"Sprint Retro\",\"question\":\"How was the sprint?\",\"options\":[\"Great\",\"Meh\",\"Rough\"],\"features\":{\"multianswers\":false,\"anonymous\":true},\"duration\":7200,\"creator\":\"polo\"}"
+
+// newTemplatePoller returns a MarcoPoller with the given poll and template storers whose slack requests are sent to the server
+func newTemplatePoller(t *testing.T, storer *marcopoller.MemoryStorer, templateStorer *marcopoller.MemoryStorer, dialoguer *mmocks.Dialoguer) (mp *marcopoller.MarcoPoller) {
+	verifier := &Verifier{}
+	verifier.On("Verify", mock.Anything, mock.Anything).Return(nil)
+
+	mp, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionStorer(storer), marcopoller.OptionTemplateStorer(templateStorer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}))
+	require.NoError(t, err)
+
+	return mp
+}
+
+// runTemplateSubcommand sends a /poll command from marco with the text and returns the requests sent to slack
+func runTemplateSubcommand(t *testing.T, storer *marcopoller.MemoryStorer, templateStorer *marcopoller.MemoryStorer, text string) (slackRequests []string) {
+	server := newSlackServer()
+	defer server.Close()
+
+	mp := newTemplatePoller(t, storer, templateStorer, &mmocks.Dialoguer{})
+
+	body := fmt.Sprintf("token=sometoken&team_id=TEAMID3&channel_id=CID&user_id=marco&command=%%2Fpoll&text=%s&response_url=%s&trigger_id=someTriggerID", url.QueryEscape(text), server.URL)
+
+	w := httptest.NewRecorder()
+	mp.StartPoll(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	return server.Requests()
+}
+
+func TestInteractivePollSubmissionSavesTemplate(t *testing.T) {
+	server := newSlackServer()
+	defer server.Close()
+
+	callback := marcopoller.InteractionCallback{Type: "view_submission",
+		User:         slack.User{ID: "marco"},
+		ResponseURLs: []marcopoller.ResponseURL{marcopoller.ResponseURL{ResponseURL: server.URL}},
+		View: slack.View{CallbackID: "interactive-poll-create",
+			State: &slack.ViewState{Values: map[string]map[string]slack.BlockAction{
+				"poll_question":       map[string]slack.BlockAction{"poll_question": slack.BlockAction{Value: "How was the sprint?"}},
+				"poll_answer_options": map[string]slack.BlockAction{"poll_answer_options": slack.BlockAction{Value: "Great\nMeh\nRough\n"}},
+				"poll_features":       map[string]slack.BlockAction{"poll_features": slack.BlockAction{SelectedOptions: []slack.OptionBlockObject{slack.OptionBlockObject{Value: "anonymous"}}}},
+				"poll_deadline":       map[string]slack.BlockAction{"poll_deadline": slack.BlockAction{Value: "2h"}},
+				"poll_template_name":  map[string]slack.BlockAction{"poll_template_name": slack.BlockAction{Value: " Sprint Retro "}},
+			}}}}
+
+	payload, _ := json.Marshal(callback)
+
+	storer := marcopoller.NewMemoryStorer()
+	templateStorer := marcopoller.NewMemoryStorer()
+	mp := newTemplatePoller(t, storer, templateStorer, &mmocks.Dialoguer{})
+
+	w := httptest.NewRecorder()
+	mp.HandleInteractions(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(fmt.Sprintf("payload=%s", payload))))
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	template, err := templateStorer.GetSiloString("templates", "sprint retro")
+	require.NoError(t, err)
+	assert.Equal(t, "{\"name\":\"Sprint Retro\",\"question\":\"How was the sprint?\",\"options\":[\"Great\",\"Meh\",\"Rough\"],\"features\":{\"multianswers\":false,\"anonymous\":true},\"duration\":7200,\"creator\":\"marco\"}", template)
+
+	slackRequests := server.Requests()
+	require.Len(t, slackRequests, 2)
+	assert.Equal(t, "{\"response_type\":\"ephemeral\",\"text\":\"Saved template *Sprint Retro*. Create a poll from it with `/poll template Sprint Retro`\",\"replace_original\":false}", slackRequests[0])
+	assert.Contains(t, slackRequests[1], "\"response_type\":\"in_channel\"")
+
+	polls, err := storer.GlobalScan()
+	require.NoError(t, err)
+	assert.Len(t, polls, 1)
+}
+
+func TestInteractivePollSubmissionKeepsTemplateOfOtherUser(t *testing.T) {
+	server := newSlackServer()
+	defer server.Close()
+
+	callback := marcopoller.InteractionCallback{Type: "view_submission",
+		User:         slack.User{ID: "marco"},
+		ResponseURLs: []marcopoller.ResponseURL{marcopoller.ResponseURL{ResponseURL: server.URL}},
+		View: slack.View{CallbackID: "interactive-poll-create",
+			State: &slack.ViewState{Values: map[string]map[string]slack.BlockAction{
+				"poll_question":       map[string]slack.BlockAction{"poll_question": slack.BlockAction{Value: "Lunch?"}},
+				"poll_answer_options": map[string]slack.BlockAction{"poll_answer_options": slack.BlockAction{Value: "Pizza\nTacos\n"}},
+				"poll_template_name":  map[string]slack.BlockAction{"poll_template_name": slack.BlockAction{Value: "sprint retro"}},
+			}}}}
+
+	payload, _ := json.Marshal(callback)
+
+	storer := marcopoller.NewMemoryStorer()
+	templateStorer := marcopoller.NewMemoryStorer()
+	require.NoError(t, templateStorer.PutSiloString("templates", "sprint retro", retroTemplate))
+	mp := newTemplatePoller(t, storer, templateStorer, &mmocks.Dialoguer{})
+
+	w := httptest.NewRecorder()
+	mp.HandleInteractions(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(fmt.Sprintf("payload=%s", payload))))
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	template, err := templateStorer.GetSiloString("templates", "sprint retro")
+	require.NoError(t, err)
+	assert.Equal(t, retroTemplate, template)
+
+	// The poll is still created without replacing polo's template
+	slackRequests := server.Requests()
+	require.Len(t, slackRequests, 2)
+	assert.Equal(t, "{\"response_type\":\"ephemeral\",\"text\":\":warning: A template named [Sprint Retro] was already saved by \\u003c@polo\\u003e. Pick another name to save this poll as a template\",\"replace_original\":false}", slackRequests[0])
+	assert.Contains(t, slackRequests[1], "\"response_type\":\"in_channel\"")
+}
+
+func TestTemplateSubcommand(t *testing.T) {
+	storer := marcopoller.NewMemoryStorer()
+	templateStorer := marcopoller.NewMemoryStorer()
+	require.NoError(t, templateStorer.PutSiloString("templates", "sprint retro", retroTemplate))
+
+	start := time.Now()
+	slackRequests := runTemplateSubcommand(t, storer, templateStorer, "template sprint  RETRO")
+
+	require.Len(t, slackRequests, 1)
+	assert.Contains(t, slackRequests[0], "\"response_type\":\"in_channel\"")
+	assert.Contains(t, slackRequests[0], "How was the sprint?")
+
+	polls, err := storer.GlobalScan()
+	require.NoError(t, err)
+	require.Len(t, polls, 1)
+
+	for _, values := range polls {
+		var poll marcopoller.Poll
+		require.NoError(t, json.Unmarshal([]byte(values["pollInfo"]), &poll))
+
+		assert.Equal(t, "How was the sprint?", poll.Question)
+		assert.Equal(t, []marcopoller.PollOption{{Text: "Great"}, {Text: "Meh"}, {Text: "Rough"}}, poll.Options)
+		assert.Equal(t, "marco", poll.Creator)
+		assert.True(t, poll.Features.Anonymous)
+		assert.InDelta(t, start.Add(2*time.Hour).Unix(), poll.Features.Deadline, 5)
+	}
+}
+
+func TestTemplateSubcommandWithUnknownTemplate(t *testing.T) {
+	slackRequests := runTemplateSubcommand(t, marcopoller.NewMemoryStorer(), marcopoller.NewMemoryStorer(), "template standup")
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\":warning: Template [standup] not found. Use `/poll template` to list the saved templates\",\"replace_original\":false}"}, slackRequests)
+}
+
+func TestTemplateSubcommandListsTemplates(t *testing.T) {
+	templateStorer := marcopoller.NewMemoryStorer()
+	require.NoError(t, templateStorer.PutSiloString("templates", "sprint retro", retroTemplate))
+	require.NoError(t, templateStorer.PutSiloString("templates", "on-call handoff", "{\"name\":\"On-call handoff\",\"question\":\"Ready to take over?\",\"options\":[\"Yes\",\"No\"],\"features\":{\"multianswers\":false},\"creator\":\"marco\"}"))
+
+	slackRequests := runTemplateSubcommand(t, marcopoller.NewMemoryStorer(), templateStorer, "template")
+
+	assert.Equal(t, []string{"{\"response_type\":\"ephemeral\",\"text\":\"*Templates*\\n• `On-call handoff` Ready to take over? (2 options)\\n• `Sprint Retro` How was the sprint? (3 options)\",\"replace_original\":false}"}, slackRequests)
+}
+
+func TestInteractivePromptWithTemplates(t *testing.T) {
+	templateStorer := marcopoller.NewMemoryStorer()
+	require.NoError(t, templateStorer.PutSiloString("templates", "sprint retro", retroTemplate))
+
+	dialoguer := &mmocks.Dialoguer{}
+	dialoguer.On("OpenView", "someTriggerID", mock.MatchedBy(func(view slack.ModalViewRequest) bool {
+		picker, ok := view.Blocks.BlockSet[0].(*slack.ActionBlock)
+		if !ok || len(picker.Elements.ElementSet) != 1 {
+			return false
+		}
+
+		templateSelect := picker.Elements.ElementSet[0].(*slack.SelectBlockElement)
+		nameInput := view.Blocks.BlockSet[len(view.Blocks.BlockSet)-1].(*slack.InputBlock)
+
+		return len(templateSelect.Options) == 1 && templateSelect.Options[0].Value == "sprint retro" && nameInput.BlockID == "poll_template_name"
+	})).Return(nil, nil)
+	defer dialoguer.AssertExpectations(t)
+
+	mp := newTemplatePoller(t, marcopoller.NewMemoryStorer(), templateStorer, dialoguer)
+
+	body := "token=sometoken&team_id=TEAMID3&channel_id=CID&user_id=marco&command=%2Fpoll&text=&response_url=https://hooks.slack.com/app/bla&trigger_id=someTriggerID"
+
+	w := httptest.NewRecorder()
+	mp.StartPoll(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+}
+
+func TestTemplateSelectionPrefillsPrompt(t *testing.T) {
+	templateStorer := marcopoller.NewMemoryStorer()
+	require.NoError(t, templateStorer.PutSiloString("templates", "sprint retro", retroTemplate))
+
+	dialoguer := &mmocks.Dialoguer{}
+	dialoguer.On("UpdateView", mock.MatchedBy(func(view slack.ModalViewRequest) bool {
+		question := view.Blocks.BlockSet[2].(*slack.InputBlock)
+
+		return view.PrivateMetadata == "sprint retro" && question.BlockID == "poll_question.sprint retro" && question.Element.(*slack.PlainTextInputBlockElement).InitialValue == "How was the sprint?"
+	}), "", "someHash", "someViewID").Return(nil, nil)
+	defer dialoguer.AssertExpectations(t)
+
+	mp := newTemplatePoller(t, marcopoller.NewMemoryStorer(), templateStorer, dialoguer)
+
+	callback := slack.InteractionCallback{Type: "block_actions", User: slack.User{ID: "marco"},
+		View:           slack.View{ID: "someViewID", Hash: "someHash", CallbackID: "interactive-poll-create"},
+		ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: "poll_template_picker", SelectedOption: slack.OptionBlockObject{Value: "sprint retro"}}}}}
+	payload, _ := json.Marshal(callback)
+
+	w := httptest.NewRecorder()
+	mp.HandleInteractions(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(fmt.Sprintf("payload=%s", payload))))
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+}
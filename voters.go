@@ -0,0 +1,168 @@
+package marcopoller
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// Voter list constants
+const (
+	// defaultMaxVoterAvatars is the number of voter avatars shown for each option unless set with OptionMaxVoterAvatars
+	defaultMaxVoterAvatars = 9
+
+	// maxContextElements is the maximum number of elements of a slack context block. One element is kept for the
+	// number of voters without an avatar
+	maxContextElements = 10
+
+	// seeVotersButtonValue prefixes the value of the button opening the list of voters of an option. It's followed by
+	// the option ID (i.e. seevoters:2)
+	seeVotersButtonValue    = "seevoters"
+	seeVotersValueDelimiter = ":"
+
+	// maxVoterListSectionLength keeps each section of the voter list under the length limit of a slack section's text
+	maxVoterListSectionLength = 3000
+)
+
+// OptionMaxVoterAvatars sets the number of voter avatars shown for each option of a poll. The others are counted
+// (i.e. + 3) and listed by the See voters button. Slack limits context blocks to 10 elements so at most 9 avatars
+// can be shown
+func OptionMaxVoterAvatars(maxAvatars int) Option {
+	return func(mp *MarcoPoller) (err error) {
+		if maxAvatars < 0 || maxAvatars >= maxContextElements {
+			return fmt.Errorf("Invalid maximum number of voter avatars [%d], expected a number between 0 and %d", maxAvatars, maxContextElements-1)
+		}
+
+		mp.maxVoterAvatars = maxAvatars
+		return nil
+	}
+}
+
+// renderVoters renders the avatars of the voters of an option, up to maxAvatars. When there are more voters, they're
+// counted and a button opens the list of all voters. The button isn't shown once a poll is closed unless its results
+// are kept since the votes are deleted
+func renderVoters(poll Poll, optionID string, voters []Voter, maxAvatars int, votingClosed bool) (blocks []slack.Block) {
+	blocks = make([]slack.Block, 0)
+
+	voteBlocks := make([]slack.MixedElement, 0)
+	for i := 0; i < len(voters) && i < maxAvatars; i++ {
+		voter := voters[i]
+		voteBlocks = append(voteBlocks, slack.NewImageBlockElement(voter.avatarURL, voter.name))
+	}
+
+	overflow := len(voters) > maxAvatars
+	if overflow {
+		voteBlocks = append(voteBlocks, slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("`+ %d`", len(voters)-maxAvatars), false, false))
+	}
+
+	if len(voteBlocks) > 0 {
+		blocks = append(blocks, slack.NewContextBlock("", voteBlocks...))
+	}
+
+	if overflow && (!votingClosed || poll.Features.KeepResults) {
+		seeVotersButton := slack.NewButtonBlockElement(formatButtonID(poll.ID, seeVotersButtonValue), formatSeeVotersValue(optionID), slack.NewTextBlockObject("plain_text", fmt.Sprintf("See all %d voters", len(voters)), false, false))
+		blocks = append(blocks, slack.NewActionBlock("", seeVotersButton))
+	}
+
+	return blocks
+}
+
+// formatSeeVotersValue formats the value of the button opening the list of voters of an option
+func formatSeeVotersValue(optionID string) (value string) {
+	return seeVotersButtonValue + seeVotersValueDelimiter + optionID
+}
+
+// isSeeVotersValue returns true if a button value is the value of a button opening the list of voters of an option
+func isSeeVotersValue(value string) bool {
+	return strings.HasPrefix(value, seeVotersButtonValue+seeVotersValueDelimiter)
+}
+
+// handleSeeVotersRequest handles a request to see all voters of an option by opening a modal listing them by name
+func (mp *MarcoPoller) handleSeeVotersRequest(poll Poll, callback InteractionCallback, w http.ResponseWriter) {
+	optionID := strings.TrimPrefix(voteValue(callback), seeVotersButtonValue+seeVotersValueDelimiter)
+	i, err := strconv.Atoi(optionID)
+	if err != nil || i < 0 || i >= len(poll.Options) {
+		log.Printf("Invalid option [%s] to list voters of poll [%s]", optionID, poll.ID)
+		showErrorToUser(callback.ResponseURL, ":warning: This option doesn't exist anymore")
+		return
+	}
+
+	// Voters are never revealed on anonymous polls and only revealed once voting closes on polls with hidden results
+	if poll.Features.Anonymous {
+		showErrorToUser(callback.ResponseURL, ":warning: Voters of anonymous polls aren't shown")
+		return
+	}
+
+	if poll.Features.HiddenResults && !poll.Closed && !poll.Features.isDue(actionTime(callback)) {
+		showErrorToUser(callback.ResponseURL, ":warning: Results are hidden until voting closes")
+		return
+	}
+
+	votes, err := mp.listVotes(poll)
+	if err != nil {
+		log.Printf("Error listing votes for poll [%s]: %v", poll.ID, err)
+		showErrorToUser(callback.ResponseURL, ":warning: Error listing votes for poll. Please try again.")
+		return
+	}
+
+	_, err = mp.dialoguer.OpenView(callback.TriggerID, createVoterListView(poll.Options[i], votes[optionID]))
+	if err != nil {
+		log.Printf("Error opening up voter list for trigger id [%s]: %s", callback.TriggerID, err.Error())
+		showErrorToUser(callback.ResponseURL, ":warning: Error opening up the list of voters. Try again, maybe?")
+		return
+	}
+}
+
+// createVoterListView renders the modal listing the voters of an option by name
+func createVoterListView(option PollOption, voters []Voter) (viewRequest slack.ModalViewRequest) {
+	blocks := []slack.Block{slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*%s* %s", formatOptionLabel(option), formatVoteCount(len(voters))), false, false), nil, nil)}
+	for _, section := range formatVoterList(voters) {
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", section, false, false), nil, nil))
+	}
+
+	viewRequest.Type = slack.VTModal
+	viewRequest.Title = slack.NewTextBlockObject("plain_text", "Voters", false, false)
+	viewRequest.Close = slack.NewTextBlockObject("plain_text", "Close", false, false)
+	viewRequest.Blocks = slack.Blocks{BlockSet: blocks}
+
+	return viewRequest
+}
+
+// formatVoterList formats voters by name (sorted), split in sections short enough to be the text of a slack section. Voters
+// without a name are mentioned instead
+func formatVoterList(voters []Voter) (sections []string) {
+	names := make([]string, 0, len(voters))
+	for _, voter := range voters {
+		if voter.name != "" {
+			names = append(names, voter.name)
+		} else {
+			names = append(names, fmt.Sprintf("<@%s>", voter.userID))
+		}
+	}
+	sort.Strings(names)
+
+	sections = make([]string, 0)
+	lines := make([]string, 0)
+	length := 0
+	for _, name := range names {
+		line := fmt.Sprintf("• %s", name)
+		if len(lines) > 0 && length+len(line)+1 > maxVoterListSectionLength {
+			sections = append(sections, strings.Join(lines, "\n"))
+			lines, length = make([]string, 0), 0
+		}
+
+		lines = append(lines, line)
+		length += len(line) + 1
+	}
+
+	if len(lines) > 0 {
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+
+	return sections
+}
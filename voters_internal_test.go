@@ -0,0 +1,70 @@
+package marcopoller
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newVoters returns count voters named User1, User2, etc.
+func newVoters(count int) (voters []Voter) {
+	voters = make([]Voter, 0, count)
+	for i := 1; i <= count; i++ {
+		voters = append(voters, Voter{userID: fmt.Sprintf("U%d", i), avatarURL: fmt.Sprintf("https://avatar%d.me", i), name: fmt.Sprintf("User%d", i)})
+	}
+
+	return voters
+}
+
+func TestRenderVoters(t *testing.T) {
+	testCases := []struct {
+		name         string
+		voters       int
+		maxAvatars   int
+		votingClosed bool
+		keepResults  bool
+		expected     string
+	}{
+		{"Under the limit", 2, 3, false, false, "[{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar1.me\",\"alt_text\":\"User1\"},{\"type\":\"image\",\"image_url\":\"https://avatar2.me\",\"alt_text\":\"User2\"}]}]"},
+		{"At the limit", 3, 3, false, false, "[{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar1.me\",\"alt_text\":\"User1\"},{\"type\":\"image\",\"image_url\":\"https://avatar2.me\",\"alt_text\":\"User2\"},{\"type\":\"image\",\"image_url\":\"https://avatar3.me\",\"alt_text\":\"User3\"}]}]"},
+		{"Over the limit", 4, 2, false, false, "[{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar1.me\",\"alt_text\":\"User1\"},{\"type\":\"image\",\"image_url\":\"https://avatar2.me\",\"alt_text\":\"User2\"},{\"type\":\"mrkdwn\",\"text\":\"`+ 2`\"}]},{\"type\":\"actions\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"See all 4 voters\"},\"action_id\":\"un,seevoters\",\"value\":\"seevoters:1\"}]}]"},
+		{"Without avatars", 2, 0, false, false, "[{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"`+ 2`\"}]},{\"type\":\"actions\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"See all 2 voters\"},\"action_id\":\"un,seevoters\",\"value\":\"seevoters:1\"}]}]"},
+		{"Closed", 2, 1, true, false, "[{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar1.me\",\"alt_text\":\"User1\"},{\"type\":\"mrkdwn\",\"text\":\"`+ 1`\"}]}]"},
+		{"Closed with kept results", 2, 1, true, true, "[{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://avatar1.me\",\"alt_text\":\"User1\"},{\"type\":\"mrkdwn\",\"text\":\"`+ 1`\"}]},{\"type\":\"actions\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"See all 2 voters\"},\"action_id\":\"un,seevoters\",\"value\":\"seevoters:1\"}]}]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			poll := Poll{ID: "un", Question: "Lunch?", Options: []PollOption{{Text: "Pizza"}, {Text: "Tacos"}}, Creator: "marco", Features: PollFeatures{KeepResults: tc.keepResults}}
+			render, err := json.Marshal(renderVoters(poll, "1", newVoters(tc.voters), tc.maxAvatars, tc.votingClosed))
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, string(render))
+		})
+	}
+}
+
+func TestSeeVotersValue(t *testing.T) {
+	assert.Equal(t, "seevoters:3", formatSeeVotersValue("3"))
+	assert.True(t, isSeeVotersValue("seevoters:3"))
+	assert.False(t, isSeeVotersValue("3"))
+	assert.False(t, isSeeVotersValue(seeVotersButtonValue))
+}
+
+func TestFormatVoterList(t *testing.T) {
+	assert.Equal(t, []string{"• <@U2>\n• Marco\n• Polo"}, formatVoterList([]Voter{{userID: "U1", name: "Polo"}, {userID: "U2"}, {userID: "U3", name: "Marco"}}))
+	assert.Equal(t, []string{}, formatVoterList([]Voter{}))
+}
+
+func TestFormatVoterListSplitsLongLists(t *testing.T) {
+	sections := formatVoterList(newVoters(500))
+
+	require.Len(t, sections, 2)
+	assert.True(t, len(sections[0]) <= maxVoterListSectionLength)
+	assert.True(t, strings.HasPrefix(sections[0], "• User1\n• User10\n"))
+	assert.Equal(t, 500, strings.Count(strings.Join(sections, "\n"), "• User"))
+}
@@ -0,0 +1,121 @@
+package marcopoller_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexandre-normand/marcopoller"
+	mmocks "github.com/alexandre-normand/marcopoller/mocks"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newVotersStorer returns a storer with a poll and a vote from marco, polo and luigi for its second option
+func newVotersStorer(t *testing.T, features string) (storer *marcopoller.MemoryStorer) {
+	storer = marcopoller.NewMemoryStorer()
+	require.NoError(t, storer.PutSiloString("1566576557-poll1", "pollInfo", fmt.Sprintf("{\"id\":\"1566576557-poll1\",\"question\":\"Lunch?\",\"options\":[\"Pizza\",\"Tacos\"],\"features\":%s,\"creator\":\"marco\"}", features)))
+	for _, userID := range []string{"marco", "polo", "luigi"} {
+		require.NoError(t, storer.PutSiloString("1566576557-poll1", userID, "1"))
+	}
+
+	return storer
+}
+
+// clickPollButton sends a click of a poll button from marco and returns the requests sent to the response url
+func clickPollButton(t *testing.T, mp *marcopoller.MarcoPoller, actionID string, value string) (slackRequests []string) {
+	server := newSlackServer()
+	defer server.Close()
+
+	callback := slack.InteractionCallback{Type: "block_actions", TriggerID: "someTriggerID", User: slack.User{ID: "marco"}, ResponseURL: server.URL, ActionCallback: slack.ActionCallbacks{BlockActions: []*slack.BlockAction{&slack.BlockAction{ActionID: actionID, Value: value}}}}
+	payload, _ := json.Marshal(callback)
+
+	w := httptest.NewRecorder()
+	mp.HandleInteractions(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(fmt.Sprintf("payload=%s", payload))))
+
+	assert.Equal(t, 200, w.Result().StatusCode)
+
+	return server.Requests()
+}
+
+// newVotersPoller returns a MarcoPoller finding users named after their ID
+func newVotersPoller(t *testing.T, storer *marcopoller.MemoryStorer, dialoguer *mmocks.Dialoguer, opts ...marcopoller.Option) (mp *marcopoller.MarcoPoller) {
+	verifier := &Verifier{}
+	verifier.On("Verify", mock.Anything, mock.Anything).Return(nil)
+
+	userFinder := &UserFinder{}
+	for _, userID := range []string{"marco", "polo", "luigi"} {
+		userFinder.On("GetUserInfo", userID).Return(&slack.User{ID: userID, RealName: strings.Title(userID), Profile: slack.UserProfile{Image24: fmt.Sprintf("https://%s.me", userID)}}, nil).Maybe()
+	}
+
+	opts = append([]marcopoller.Option{marcopoller.OptionVerifier(verifier), marcopoller.OptionUserFinder(userFinder), marcopoller.OptionStorer(storer), marcopoller.OptionDialoguer(dialoguer), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{})}, opts...)
+	mp, err := marcopoller.NewWithOptions(opts...)
+	require.NoError(t, err)
+
+	return mp
+}
+
+func TestVoteRendersConfiguredNumberOfAvatars(t *testing.T) {
+	mp := newVotersPoller(t, newVotersStorer(t, "{\"multianswers\":false}"), &mmocks.Dialoguer{}, marcopoller.OptionMaxVoterAvatars(1))
+
+	slackRequests := clickPollButton(t, mp, "1566576557-poll1,vote", "1")
+
+	require.Len(t, slackRequests, 2)
+	assert.Contains(t, slackRequests[0], "{\"type\":\"context\",\"elements\":[{\"type\":\"image\",\"image_url\":\"https://")
+	assert.Contains(t, slackRequests[0], "\"},{\"type\":\"mrkdwn\",\"text\":\"`+ 2`\"}]},{\"type\":\"actions\",\"elements\":[{\"type\":\"button\",\"text\":{\"type\":\"plain_text\",\"text\":\"See all 3 voters\"},\"action_id\":\"1566576557-poll1,seevoters\",\"value\":\"seevoters:1\"}]}")
+}
+
+func TestInvalidMaxVoterAvatars(t *testing.T) {
+	for _, maxAvatars := range []int{-1, 10} {
+		_, err := marcopoller.NewWithOptions(marcopoller.OptionVerifier(&Verifier{}), marcopoller.OptionUserFinder(&UserFinder{}), marcopoller.OptionStorer(marcopoller.NewMemoryStorer()), marcopoller.OptionDialoguer(&mmocks.Dialoguer{}), marcopoller.OptionPollVerifier(marcopoller.AlwaysValidPollVerifier{}), marcopoller.OptionMaxVoterAvatars(maxAvatars))
+
+		assert.EqualError(t, err, fmt.Sprintf("Invalid maximum number of voter avatars [%d], expected a number between 0 and 9", maxAvatars))
+	}
+}
+
+func TestSeeVoters(t *testing.T) {
+	dialoguer := &mmocks.Dialoguer{}
+	dialoguer.On("OpenView", "someTriggerID", mock.MatchedBy(func(view slack.ModalViewRequest) bool {
+		render, _ := json.Marshal(view.Blocks)
+
+		return view.Title.Text == "Voters" && string(render) == "[{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*Tacos* `3 votes`\"}},{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"• Luigi\\n• Marco\\n• Polo\"}}]"
+	})).Return(nil, nil)
+	defer dialoguer.AssertExpectations(t)
+
+	mp := newVotersPoller(t, newVotersStorer(t, "{\"multianswers\":false}"), dialoguer)
+
+	slackRequests := clickPollButton(t, mp, "1566576557-poll1,seevoters", "seevoters:1")
+
+	assert.Len(t, slackRequests, 0)
+}
+
+func TestSeeVotersErrors(t *testing.T) {
+	testCases := []struct {
+		name        string
+		features    string
+		value       string
+		expectedMsg string
+	}{
+		{"Anonymous poll", "{\"multianswers\":false,\"anonymous\":true}", "seevoters:1", ":warning: Voters of anonymous polls aren't shown"},
+		{"Hidden results", "{\"multianswers\":false,\"hiddenResults\":true}", "seevoters:1", ":warning: Results are hidden until voting closes"},
+		{"Removed option", "{\"multianswers\":false}", "seevoters:2", ":warning: This option doesn't exist anymore"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dialoguer := &mmocks.Dialoguer{}
+			defer dialoguer.AssertExpectations(t)
+
+			mp := newVotersPoller(t, newVotersStorer(t, tc.features), dialoguer)
+
+			slackRequests := clickPollButton(t, mp, "1566576557-poll1,seevoters", tc.value)
+
+			assert.Equal(t, []string{fmt.Sprintf("{\"response_type\":\"ephemeral\",\"text\":\"%s\",\"replace_original\":false}", tc.expectedMsg)}, slackRequests)
+		})
+	}
+}
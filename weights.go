@@ -0,0 +1,208 @@
+package marcopoller
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// weightDelimiter separates the user or user group from its weight in a weight flag (i.e. --weight=<@U123>:2)
+const weightDelimiter = ":"
+
+// mentionIDPattern matches a user ID, a user group ID or their escaped slack mentions (<@U123|marco> or <!subteam^S123|@leads>)
+var mentionIDPattern = regexp.MustCompile(`^(?:<@([UW][A-Z0-9]+)(?:\|[^>]*)?>|<!subteam\^(S[A-Z0-9]+)(?:\|[^>]*)?>|([UWS][A-Z0-9]+))$`)
+
+// UserGroupFinder is implemented by any value that has the GetUserGroupMembers method. Weights given to user
+// groups are only applied with a MemberFinder or when the UserFinder is also a UserGroupFinder (like a slack-go/slack.Client)
+type UserGroupFinder interface {
+	// GetUserGroupMembers will retrieve the current list of users in a group. See https://pkg.go.dev/github.com/slack-go/slack?tab=doc#Client.GetUserGroupMembers
+	GetUserGroupMembers(userGroup string) (members []string, err error)
+}
+
+// parseWeight parses the value of a weight flag formatted as <user or user group>:<weight>
+func parseWeight(value string) (id string, weight float64, err error) {
+	i := strings.LastIndex(value, weightDelimiter)
+	if i == -1 {
+		return "", 0, fmt.Errorf("Expected a user or user group and a weight (i.e. <@U123>:2)")
+	}
+
+	id, err = parseMentionID(value[:i])
+	if err != nil {
+		return "", 0, err
+	}
+
+	weight, err = strconv.ParseFloat(value[i+1:], 64)
+	if err != nil || weight <= 0 {
+		return "", 0, fmt.Errorf("[%s] isn't a positive number", value[i+1:])
+	}
+
+	return id, weight, nil
+}
+
+// parseMentionID returns the ID of a user or user group given either as an ID or as an escaped slack mention
+func parseMentionID(raw string) (id string, err error) {
+	match := mentionIDPattern.FindStringSubmatch(raw)
+	if match == nil {
+		return "", fmt.Errorf("[%s] isn't a user or user group", raw)
+	}
+
+	return match[1] + match[2] + match[3], nil
+}
+
+// isUserGroupID returns true if the ID is the ID of a user group
+func isUserGroupID(id string) bool {
+	return strings.HasPrefix(id, "S")
+}
+
+// voterWeights returns the weight of every user with a weight on a poll. Members of a user group get the group's weight
+// unless they have a weight of their own. Users in many groups get the highest of the groups' weights
+func (mp *MarcoPoller) voterWeights(poll Poll) (weights map[string]float64) {
+	weights = make(map[string]float64)
+
+	for id, weight := range poll.Features.Weights {
+		if !isUserGroupID(id) {
+			continue
+		}
+
+		userGroupFinder, ok := mp.userGroupFinder()
+		if !ok {
+			log.Printf("Ignoring weight of user group [%s] on poll [%s]: no UserGroupFinder to find user group members", id, poll.ID)
+			continue
+		}
+
+		members, err := userGroupFinder.GetUserGroupMembers(id)
+		if err != nil {
+			log.Printf("Ignoring weight of user group [%s] on poll [%s]: %v", id, poll.ID, err)
+			continue
+		}
+
+		for _, member := range members {
+			if weight > weights[member] {
+				weights[member] = weight
+			}
+		}
+	}
+
+	for id, weight := range poll.Features.Weights {
+		if !isUserGroupID(id) {
+			weights[id] = weight
+		}
+	}
+
+	return weights
+}
+
+// userGroupFinder returns the MemberFinder or, without one, the UserFinder if it's also a UserGroupFinder
+func (mp *MarcoPoller) userGroupFinder() (userGroupFinder UserGroupFinder, ok bool) {
+	if mp.memberFinder != nil {
+		return mp.memberFinder, true
+	}
+
+	userGroupFinder, ok = mp.userFinder.(UserGroupFinder)
+	return userGroupFinder, ok
+}
+
+// weightOf returns the weight of a user's votes. Users without a weight have a weight of 1
+func weightOf(weights map[string]float64, userID string) (weight float64) {
+	if weight, ok := weights[userID]; ok {
+		return weight
+	}
+
+	return 1
+}
+
+// weightedScore returns the sum of the weights of the voters
+func weightedScore(voters []Voter) (score float64) {
+	for _, voter := range voters {
+		score += voter.weight
+	}
+
+	return score
+}
+
+// weightedScores returns the weighted score of each option of a poll, in the poll's order, given all of its stored
+// values (poll info and votes) and the weights of its voters
+func weightedScores(poll Poll, values map[string]string, weights map[string]float64) (scores []float64) {
+	scores = make([]float64, len(poll.Options))
+	for userID, userVotes := range values {
+		if userID == pollInfoKey || userVotes == "" {
+			continue
+		}
+
+		for _, value := range strings.Split(userVotes, voteDelimiter) {
+			i, err := strconv.Atoi(value)
+			if err != nil || i < 0 || i >= len(poll.Options) {
+				continue
+			}
+
+			scores[i] += weightOf(weights, userID)
+		}
+	}
+
+	return scores
+}
+
+// formatWeight formats a weight for display without trailing zeros
+func formatWeight(weight float64) (formatted string) {
+	return strconv.FormatFloat(weight, 'f', -1, 64)
+}
+
+// formatWeightedVoteCount formats a number of votes along with their weighted score for display
+func formatWeightedVoteCount(count int, score float64) (formatted string) {
+	return fmt.Sprintf("%s · weighted `%s`", formatVoteCount(count), formatWeight(score))
+}
+
+// formatWeights formats the weights of a poll for display
+func formatWeights(weights map[string]float64) (formatted string) {
+	ids := make([]string, 0, len(weights))
+	for id := range weights {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		mention := fmt.Sprintf("<@%s>", id)
+		if isUserGroupID(id) {
+			mention = fmt.Sprintf("<!subteam^%s>", id)
+		}
+
+		parts = append(parts, fmt.Sprintf("%s `×%s`", mention, formatWeight(weights[id])))
+	}
+
+	return fmt.Sprintf("Weighted votes: %s", strings.Join(parts, ", "))
+}
+
+// renderWeightedResults renders the options of a poll ranked by weighted score. Options with the same score are
+// ranked by number of votes and then keep the poll's order
+func renderWeightedResults(poll Poll, votes map[string][]Voter) (blocks []slack.Block) {
+	optionIDs := make([]int, 0, len(poll.Options))
+	for i := range poll.Options {
+		optionIDs = append(optionIDs, i)
+	}
+
+	sort.SliceStable(optionIDs, func(i, j int) bool {
+		votersI, votersJ := votes[strconv.Itoa(optionIDs[i])], votes[strconv.Itoa(optionIDs[j])]
+		if scoreI, scoreJ := weightedScore(votersI), weightedScore(votersJ); scoreI != scoreJ {
+			return scoreI > scoreJ
+		}
+
+		return len(votersI) > len(votersJ)
+	})
+
+	lines := make([]string, 0, len(optionIDs))
+	for rank, i := range optionIDs {
+		voters := votes[strconv.Itoa(i)]
+		lines = append(lines, fmt.Sprintf("%d. %s %s", rank+1, poll.Options[i], formatWeightedVoteCount(len(voters), weightedScore(voters))))
+	}
+
+	blocks = append(blocks, slack.NewDividerBlock())
+	blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*Results by weighted score*\n%s", strings.Join(lines, "\n")), false, false), nil, nil))
+
+	return blocks
+}
@@ -0,0 +1,113 @@
+package marcopoller
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// groupUserFinder is a UserFinder that is also a UserGroupFinder
+type groupUserFinder struct {
+	members map[string][]string
+}
+
+func (guf groupUserFinder) GetUserInfo(user string) (*slack.User, error) {
+	return &slack.User{ID: user}, nil
+}
+
+func (guf groupUserFinder) GetUserGroupMembers(userGroup string) (members []string, err error) {
+	members, ok := guf.members[userGroup]
+	if !ok {
+		return nil, fmt.Errorf("usergroup_not_found")
+	}
+
+	return members, nil
+}
+
+func TestParseWeight(t *testing.T) {
+	tests := map[string]struct {
+		value          string
+		expectedID     string
+		expectedWeight float64
+		expectedErr    string
+	}{
+		"user mention":       {value: "<@U123|marco>:2", expectedID: "U123", expectedWeight: 2},
+		"user mention alone": {value: "<@W123>:3", expectedID: "W123", expectedWeight: 3},
+		"group mention":      {value: "<!subteam^S456|@leads>:1.5", expectedID: "S456", expectedWeight: 1.5},
+		"raw id":             {value: "S456:2", expectedID: "S456", expectedWeight: 2},
+		"missing weight":     {value: "<@U123>", expectedErr: "Expected a user or user group and a weight (i.e. <@U123>:2)"},
+		"unescaped name":     {value: "@marco:2", expectedErr: "[@marco] isn't a user or user group"},
+		"negative weight":    {value: "U123:-1", expectedErr: "[-1] isn't a positive number"},
+		"invalid weight":     {value: "U123:double", expectedErr: "[double] isn't a positive number"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			id, weight, err := parseWeight(tc.value)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedID, id)
+			assert.Equal(t, tc.expectedWeight, weight)
+		})
+	}
+}
+
+func TestParsePollFlagsWithWeightsOnRankedPoll(t *testing.T) {
+	_, err := parsePollFlags([]string{"--ranked", "--weight=U123:2"}, time.Now())
+
+	assert.EqualError(t, err, "Weights can't be used on ranked choice polls")
+}
+
+func TestParsePollFlagsWithWeightsOnAnonymousPoll(t *testing.T) {
+	for _, flags := range [][]string{{"--anonymous", "--weight=U123:2"}, {"--weight=U123:2", "--anonymous"}} {
+		_, err := parsePollFlags(flags, time.Now())
+
+		assert.EqualError(t, err, "Weights can't be used on anonymous polls")
+	}
+}
+
+func TestVoterWeights(t *testing.T) {
+	mp := &MarcoPoller{userFinder: groupUserFinder{members: map[string][]string{"S1": {"U1", "U2"}, "S2": {"U2", "U3"}}}}
+	poll := Poll{ID: "un", Features: PollFeatures{Weights: map[string]float64{"S1": 2, "S2": 3, "U3": 0.5, "S404": 10}}}
+
+	assert.Equal(t, map[string]float64{"U1": 2, "U2": 3, "U3": 0.5}, mp.voterWeights(poll))
+}
+
+func TestVoterWeightsWithoutUserGroupFinder(t *testing.T) {
+	mp := &MarcoPoller{userFinder: nil}
+	poll := Poll{ID: "un", Features: PollFeatures{Weights: map[string]float64{"S1": 2, "U3": 0.5}}}
+
+	assert.Equal(t, map[string]float64{"U3": 0.5}, mp.voterWeights(poll))
+}
+
+func TestRenderWeightedPoll(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Which design?", Options: []PollOption{{Text: "Monolith"}, {Text: "Services"}}, Creator: "marco", Features: PollFeatures{Anonymous: true, Weights: map[string]float64{"U1": 2, "S1": 1.5}}}
+	blocks := renderPoll(poll, map[string][]Voter{"0": []Voter{Voter{userID: "U2", weight: 1}, Voter{userID: "U3", weight: 1}}, "1": []Voter{Voter{userID: "U1", weight: 2}}}, false, defaultMaxVoterAvatars)
+	render, err := json.Marshal(blocks)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(render), "{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"Weighted votes: \\u003c!subteam^S1\\u003e `×1.5`, \\u003c@U1\\u003e `×2`\"}]}")
+	assert.Contains(t, string(render), "{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"`2 votes` · weighted `2`\"}]}")
+	assert.Contains(t, string(render), "{\"type\":\"context\",\"elements\":[{\"type\":\"mrkdwn\",\"text\":\"`1 vote` · weighted `2`\"}]}")
+}
+
+func TestRenderClosedWeightedPollRanksByWeightedScore(t *testing.T) {
+	poll := Poll{ID: "un", Question: "Which design?", Options: []PollOption{{Text: "Monolith"}, {Text: "Services"}, {Text: "Serverless"}}, Creator: "marco", Features: PollFeatures{Weights: map[string]float64{"U1": 2}}}
+	blocks := renderPoll(poll, map[string][]Voter{
+		"0": []Voter{Voter{userID: "U2", avatarURL: "https://avatar2.me", name: "User2", weight: 1}, Voter{userID: "U3", avatarURL: "https://avatar3.me", name: "User3", weight: 1}},
+		"1": []Voter{Voter{userID: "U1", avatarURL: "https://avatar1.me", name: "User1", weight: 2}, Voter{userID: "U4", avatarURL: "https://avatar4.me", name: "User4", weight: 1}}}, true, defaultMaxVoterAvatars)
+	render, err := json.Marshal(blocks)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(render), "{\"type\":\"section\",\"text\":{\"type\":\"mrkdwn\",\"text\":\"*Results by weighted score*\\n1. Services `2 votes` · weighted `3`\\n2. Monolith `2 votes` · weighted `2`\\n3. Serverless `0 votes` · weighted `0`\"}}")
+	assert.NotContains(t, string(render), "Weighted votes:")
+}